@@ -0,0 +1,110 @@
+// Shutdown and reload handling for the server process
+
+package main
+
+import (
+	"context"
+	"go_recipe_app/internal/config"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/handlers/account"
+	"go_recipe_app/internal/health"
+	"go_recipe_app/internal/jobs"
+	"go_recipe_app/internal/logging"
+	"go_recipe_app/internal/server"
+	"go_recipe_app/internal/storage/boltdb"
+	"log/slog"
+	"os"
+	"reflect"
+	"time"
+)
+
+// shutdown stops the server in order: fail readiness while still serving for drainDelay,
+// no new connections, finish in-flight requests, stop background jobs, then close the
+// database so nothing is left half-written
+func shutdown(srv *server.Server, checker *health.Checker, runner *jobs.Runner, store *boltdb.Store, drainDelay, timeout time.Duration, logger *slog.Logger) {
+	// Shutdown closes the listeners and idle connections straight away, so /readyz has to
+	// fail for a while first for load balancers to see it and stop routing here
+	checker.Drain(drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		// The deadline passed - cut off whoever is left so the store can close
+		logger.Error("server did not drain in time, closing remaining connections", "error", err)
+		srv.Close()
+	} else {
+		logger.Info("server stopped")
+	}
+
+	if err := runner.Stop(ctx); err != nil {
+		logger.Error("error stopping background jobs", "error", err)
+	}
+
+	if err := store.Close(); err != nil {
+		logger.Error("error closing database", "error", err)
+		return
+	}
+	logger.Info("database closed, shutdown complete")
+}
+
+// reload reopens the log files and re-reads the config, templates and TLS certificate after a SIGHUP
+// Settings the running server can't change are reported and keep their old values until a restart
+func reload(cfg *config.Config, tmpl *handlers.Renderer, accounts *account.Handler, srv *server.Server, logFiles *logging.Files, levels *logging.Levels, logger *slog.Logger) *config.Config {
+	// Reopen the log files first, in case logrotate just moved them and sent this signal
+	if err := logFiles.Reopen(); err != nil {
+		logger.Error("log file reopen failed", "error", err)
+	}
+	logger.Info("SIGHUP received, reloading configuration and templates")
+
+	if srv.TLS() {
+		if err := srv.Certs.Reload(); err != nil {
+			logger.Error("tls certificate reload failed, keeping the old certificate", "error", err)
+		} else {
+			logger.Info("tls certificate reloaded")
+		}
+	}
+
+	if err := tmpl.Reload(); err != nil {
+		logger.Error("template reload failed, keeping the old templates", "error", err)
+	} else {
+		logger.Info("templates reloaded")
+	}
+
+	// The same flags apply; the config file and environment are read again
+	next, err := config.Load(os.Args[1:])
+	if err != nil {
+		logger.Error("config reload failed, keeping the old config", "error", err)
+		return cfg
+	}
+
+	accounts.SetAllowSignup(next.AllowSignup)
+
+	// Config.Load already checked the levels; this also drops any changes made at runtime
+	if err := levels.Apply(next.LogLevel, next.LogLevels); err != nil {
+		logger.Error("log level reload failed", "error", err)
+	}
+
+	if fields := restartFields(cfg, next); len(fields) > 0 {
+		logger.Warn("some settings changed but only take effect after a restart", "settings", fields)
+	}
+	logger.Info("configuration reloaded", "allow_signup", next.AllowSignup,
+		"log_level", next.LogLevel, "log_levels", next.LogLevels)
+	return next
+}
+
+// restartFields lists the config fields that differ between old and next
+// Everything except the live-reloadable settings needs a restart to apply
+func restartFields(old, next *config.Config) []string {
+	live := map[string]bool{"AllowSignup": true, "LogLevel": true, "LogLevels": true}
+
+	var fields []string
+	a, b := reflect.ValueOf(*old), reflect.ValueOf(*next)
+	for i := 0; i < a.NumField(); i++ {
+		name := a.Type().Field(i).Name
+		if !live[name] && !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
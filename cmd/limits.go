@@ -0,0 +1,63 @@
+// Request size and rate limits for the routes
+
+package main
+
+import (
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/config"
+	"go_recipe_app/internal/server"
+	"net/http"
+)
+
+// bodyLimits are the per-route caps, keyed by route template
+// Forms that only carry a few short fields, like a username and password, get the small
+// form cap. Recipes can be long, so they get the configured RECIPE_APP_MAX_BODY_BYTES
+func bodyLimits(cfg *config.Config) map[string]int64 {
+	return map[string]int64{
+		"/login":                      cfg.MaxFormBytes,
+		"/logout":                     cfg.MaxFormBytes,
+		"/register":                   cfg.MaxFormBytes,
+		"/account/password":           cfg.MaxFormBytes,
+		"/account/tokens":             cfg.MaxFormBytes,
+		"/admin/users":                cfg.MaxFormBytes,
+		"/groups":                     cfg.MaxFormBytes,
+		"/groups/{id}/members":        cfg.MaxFormBytes,
+		"/recipes/{id}/cook/progress": cfg.MaxProgressBytes,
+	}
+}
+
+// loginRoutes get the stricter login limit, so passwords can't be guessed quickly
+var loginRoutes = []string{"POST /login", "POST /register"}
+
+// importRoutes are the bulk-write paths, where a script can add recipes as fast as it likes
+// They get the stricter import limit: restores per user on the main router, and API creates
+// per token on the API subrouter, where the token is known
+var (
+	importRoutes    = []string{"POST /admin/audit/{id}/restore"}
+	apiImportRoutes = []string{"POST " + apiPath + "/recipes"}
+)
+
+// clientKey buckets requests by user once they're known, and by IP address before that
+// Keying logged in users by account stops people sharing an office IP from slowing each other down
+func clientKey(trustProxy bool) func(*http.Request) string {
+	return func(r *http.Request) string {
+		if viewer := auth.CurrentViewer(r); viewer.UserID != "" {
+			return "user:" + viewer.UserID
+		}
+		return "ip:" + server.ClientIP(r, trustProxy)
+	}
+}
+
+// tokenKey buckets API requests by token, so each script gets its own allowance
+// It must run after TokenMiddleware, which rejects requests without a token
+func tokenKey(r *http.Request) string {
+	token, _ := auth.TokenFromContext(r.Context())
+	return "token:" + token.ID
+}
+
+// ipKey always buckets by IP address - used for login, where there's no user yet
+func ipKey(trustProxy bool) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return server.ClientIP(r, trustProxy)
+	}
+}
@@ -3,36 +3,104 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"go_recipe_app/internal/audit"
+	"go_recipe_app/internal/auth"
 	"go_recipe_app/internal/config"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/handlers/account"
+	"go_recipe_app/internal/handlers/api"
+	"go_recipe_app/internal/handlers/auditlog"
+	"go_recipe_app/internal/handlers/group"
 	"go_recipe_app/internal/handlers/recipe"
+	"go_recipe_app/internal/health"
+	"go_recipe_app/internal/jobs"
 	"go_recipe_app/internal/logging"
+	"go_recipe_app/internal/metrics"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/server"
 	"go_recipe_app/internal/storage/boltdb"
-	"html/template"
+	"go_recipe_app/web"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// apiPath is where the JSON API lives; it authenticates with bearer tokens only
+const apiPath = "/api"
+
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	// Load configuration from the config file, environment and flags
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return // -h printed the flags
+	}
+	if cfg != nil && cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nThe config has problems:\n%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		log.Fatalf("Failed to load configuration:\n%v", err)
+	}
+
+	// Log levels can be changed while running: SIGHUP re-reads them from the config,
+	// SIGUSR1 toggles debug, and admins can set them through /api/admin/log-levels
+	levels := logging.NewLevels(slog.LevelInfo)
+	if err := levels.Apply(cfg.LogLevel, cfg.LogLevels); err != nil {
+		log.Fatalf("Failed to set log levels: %v", err)
 	}
 
 	// Initialize new logger
-	logger, err := logging.NewLogger(logging.LogConfig{
-		Level:   cfg.LogLevel,
-		Format:  cfg.LogFormat,
-		LogPath: cfg.LogPath,
+	logger, logFiles, err := logging.NewLogger(logging.LogConfig{
+		Levels:       levels,
+		Format:       cfg.LogFormat,
+		LogPath:      cfg.LogPath,
+		ErrorLogPath: cfg.ErrorLogPath,
+		Rotate: logging.RotateConfig{
+			MaxSize:  cfg.LogMaxSize,
+			MaxAge:   cfg.LogMaxAge,
+			MaxFiles: cfg.LogMaxFiles,
+			Compress: cfg.LogCompress,
+		},
+		Redact: logging.RedactConfig{
+			Keys:        cfg.LogRedactKeys,
+			MaxValueLen: cfg.LogMaxValueLen,
+		},
 	})
 	if err != nil {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
+	defer logFiles.Close()  // waits for any log compression to finish
+	slog.SetDefault(logger) // logging.FromContext falls back to it outside requests
+
+	// Templates and static files are built into the binary; development mode reads them from disk
+	webDir := ""
+	if cfg.Dev {
+		webDir = cfg.WebDir
+		logger.Info("development mode: reading templates and static files from disk", "dir", webDir)
+	}
+	webFiles := web.Files(webDir)
+	assets, err := web.NewAssets(webFiles, cfg.Dev)
+	if err != nil {
+		logger.Error("failed to load static files", "error", err)
+		return
+	}
 
 	// Parse templates
 	logger.Info("parsing templates")
-	tmpl, err := template.ParseGlob("templates/*.html")
+	tmpl, err := handlers.NewRenderer(web.Templates(webFiles), assets.FuncMap())
 	if err != nil {
 		logger.Error("failed to parse templates", "error", err)
 		return
@@ -40,33 +108,228 @@ func main() {
 	logger.Info("templates parsed successfully")
 
 	// Initialize store
-	store, err := boltdb.New(cfg.DBPath)
+	store, err := boltdb.New(cfg.DBPath, boltdb.Options{
+		Timeout:       cfg.DBTimeout,
+		Logger:        logger,
+		SlowThreshold: cfg.DBSlowThreshold,
+	})
 	if err != nil {
 		logger.Error("failed to initialize database", "error", err)
 		return
 	}
-	defer store.Close()
 	logger.Info("database initialized", "path", cfg.DBPath)
 
-	// Create handler
+	// Set up accounts and sessions
+	authManager := auth.NewManager(store, store, store, store, auth.Options{
+		SessionTTL:    cfg.SessionTTL,
+		SecureCookies: cfg.Secure(),
+		APIPath:       apiPath,
+	}, logger)
+
+	// Metrics for Prometheus; recipe store calls are timed through a wrapper
+	registry := metrics.NewRegistry()
+	httpMetrics := metrics.NewHTTP(registry)
+	recipes := metrics.NewStore(registry, store)
+
+	// Every recipe change is recorded in the audit log, with who made it and from where
+	audited := audit.NewStore(recipes, store, logger)
+	registerStoreMetrics(registry, store, logger)
+	registry.Register(metrics.Runtime())
+
+	// Create handlers
 	logger.Info("initializing recipe handler")
-	recipeHandler := recipe.New(tmpl, store, logger)
+	recipeHandler := recipe.New(tmpl, audited, store, store, logger)
 	logger.Info("recipe handler initialized")
 
-	addr := fmt.Sprintf(":%d", cfg.Port)
+	accountHandler := account.New(tmpl, authManager, store, cfg.AllowSignup, logger)
+	accountHandler.RegisterRoutes(recipeHandler.Router)
+	group.New(tmpl, store, store, logger).RegisterRoutes(recipeHandler.Router)
+	auditlog.New(tmpl, store, audited, store, logger).RegisterRoutes(recipeHandler.Router)
+
+	// Every request is limited in size and rate, sees the logged in user, if any,
+	// and forms must carry a CSRF token. Bodies are capped before anything reads them
+	limiter := server.NewRateLimiter(cfg.RateLimit, cfg.RateBurst)
+	loginLimiter := server.NewRateLimiter(cfg.LoginRateLimit, cfg.LoginRateBurst)
+	importLimiter := server.NewRateLimiter(cfg.ImportRateLimit, cfg.ImportRateBurst)
+	recipeHandler.Router.Use(
+		server.BodyLimit(cfg.MaxBodyBytes, bodyLimits(cfg)),
+		audit.Middleware(func(r *http.Request) string { return server.ClientIP(r, cfg.TrustProxy) }),
+		authManager.Middleware,
+		server.RateLimit(loginLimiter, ipKey(cfg.TrustProxy), loginRoutes...),
+		server.RateLimit(importLimiter, clientKey(cfg.TrustProxy), importRoutes...),
+		server.RateLimit(limiter, clientKey(cfg.TrustProxy)),
+		authManager.CSRFMiddleware,
+	)
+
+	// CSS and JavaScript, cached for good under names that change with their contents
+	recipeHandler.Router.PathPrefix(web.StaticPrefix).Handler(http.StripPrefix(strings.TrimSuffix(web.StaticPrefix, "/"), assets)).Methods("GET", "HEAD")
+
+	// In development, open pages reload themselves when a template or static file changes
+	var liveReload *web.LiveReload
+	if cfg.Dev {
+		liveReload, err = web.NewLiveReload(webFiles, func() error {
+			logger.Info("web files changed, reloading templates")
+			return tmpl.Reload()
+		})
+		if err != nil {
+			logger.Error("failed to watch web files", "error", err)
+			store.Close()
+			return
+		}
+		recipeHandler.Router.Handle(web.LiveReloadPath, liveReload).Methods("GET")
+	}
+
+	// Prometheus scrapes this; set RECIPE_APP_METRICS_TOKEN to keep it private
+	recipeHandler.Router.Handle("/metrics", registry.Handler(cfg.MetricsToken)).Methods("GET")
+
+	// Liveness and readiness probes for systemd and load balancers
+	// Readiness checks the dependencies a request needs; the runner is set up below
+	checker := health.New()
+	recipeHandler.Router.HandleFunc("/healthz", checker.Healthz).Methods("GET")
+	recipeHandler.Router.HandleFunc("/readyz", checker.Readyz).Methods("GET")
+
+	// The JSON API authenticates with bearer tokens instead of the session cookie
+	apiRouter := recipeHandler.Router.PathPrefix(apiPath).Subrouter()
+	// Requests are limited by address above and per token here, once the token is known.
+	// Tokens have their own limiter so the same request isn't charged to one bucket twice
+	apiLimiter := server.NewRateLimiter(cfg.RateLimit, cfg.RateBurst)
+	apiRouter.Use(
+		authManager.TokenMiddleware,
+		server.RateLimit(apiLimiter, tokenKey),
+		server.RateLimit(importLimiter, tokenKey, apiImportRoutes...),
+	)
+	api.New(audited, store, store, store, logger).RegisterRoutes(apiRouter)
+	apiRouter.Handle("/admin/log-levels", auth.RequireScope(models.ScopeAdmin, levels.Handler(logger).ServeHTTP)).Methods("GET", "PUT")
+
+	// The server applies the timeouts, header limits, TLS, CORS and security headers from the config
+	srv, err := server.New(cfg, recipeHandler.Router, server.Options{
+		Logger:    logger,
+		Routes:    recipeHandler.Router,
+		ErrorPage: tmpl.ErrorPage(http.StatusInternalServerError, "Something went wrong on our side."),
+		Observe:   httpMetrics.Observe,
+	})
+	if err != nil {
+		logger.Error("failed to set up server", "error", err)
+		store.Close()
+		return
+	}
+
+	// Background work runs until shutdown
+	jobLogger := logger.With("component", "jobs")
+	runner := jobs.New(jobLogger)
+	runner.Add(jobs.Job{
+		Name:     "delete expired sessions",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			n, err := authManager.DeleteExpiredSessions()
+			if n > 0 {
+				jobLogger.Info("deleted expired sessions", "count", n)
+			}
+			return err
+		},
+	})
+
+	runner.Add(jobs.Job{
+		Name:     "prune rate limiter",
+		Interval: 10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			limiter.Prune()
+			loginLimiter.Prune()
+			apiLimiter.Prune()
+			importLimiter.Prune()
+			return nil
+		},
+	})
+
+	if srv.TLS() {
+		// Renewed certificates are picked up within a minute, or straight away on SIGHUP
+		runner.Add(jobs.Job{
+			Name:     "reload tls certificate",
+			Interval: time.Minute,
+			Run: func(ctx context.Context) error {
+				changed, err := srv.Certs.ReloadIfChanged()
+				if changed && err == nil {
+					jobLogger.Info("tls certificate reloaded")
+				}
+				return err
+			},
+		})
+	}
+
+	if liveReload != nil {
+		runner.Add(jobs.Job{
+			Name:     "watch web files",
+			Interval: time.Second,
+			Run: func(ctx context.Context) error {
+				_, err := liveReload.Check()
+				return err
+			},
+		})
+	}
+
+	checker.Add("store", func(ctx context.Context) error { return store.Ping() })
+	checker.Add("templates", func(ctx context.Context) error { return tmpl.Check() })
+	checker.Add("disk", health.DiskSpace(cfg.DBPath, cfg.MinFreeDisk))
+	checker.Add("jobs", func(ctx context.Context) error {
+		if !runner.Running() {
+			return fmt.Errorf("background jobs are not running")
+		}
+		return nil
+	})
+
+	// SIGINT (Ctrl+C) and SIGTERM (systemd stop) begin a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// SIGHUP reloads the config and templates without dropping connections
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		current := cfg
+		for range hup {
+			current = reload(current, tmpl, accountHandler, srv, logFiles, levels, logger)
+		}
+	}()
+
+	// SIGUSR1 switches debug logging on and off, where the platform has it
+	defer watchDebugSignal(levels, logger)()
+
+	runner.Start(ctx)
+
+	scheme := "http"
+	if srv.TLS() {
+		scheme = "https"
+	}
 	if cfg.Env == "development" || cfg.Env == "local" {
 		logger.Info("starting development server",
-			"url", fmt.Sprintf("http://localhost%s", addr),
+			"url", fmt.Sprintf("%s://localhost%s", scheme, srv.HTTP.Addr),
 			"env", cfg.Env,
 		)
 	} else {
 		logger.Info("starting production server",
 			"port", cfg.Port,
+			"scheme", scheme,
+			"http_redirect_port", cfg.HTTPRedirectPort,
 			"env", cfg.Env,
 		)
 	}
 
-	if err := http.ListenAndServe(addr, recipeHandler.Router); err != nil {
+	serverErr := make(chan error, 1)
+	go func() {
+		// ListenAndServe always returns an error; ErrServerClosed just means Shutdown was called
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		logger.Info("shutdown signal received, draining requests", "drain_delay", cfg.ShutdownDrainDelay, "timeout", cfg.ShutdownTimeout)
+	case err := <-serverErr:
 		logger.Error("server failed", "error", err)
 	}
+	stop() // a second Ctrl+C now kills the process straight away
+
+	shutdown(srv, checker, runner, store, cfg.ShutdownDrainDelay, cfg.ShutdownTimeout, logger)
 }
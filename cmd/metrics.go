@@ -0,0 +1,49 @@
+// Metrics that need to know about the concrete store
+
+package main
+
+import (
+	"go_recipe_app/internal/metrics"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"go_recipe_app/internal/storage/boltdb"
+	"log/slog"
+)
+
+// registerStoreMetrics adds the BoltDB statistics and recipe counts, both read at scrape time
+func registerStoreMetrics(registry *metrics.Registry, store *boltdb.Store, logger *slog.Logger) {
+	registry.Register(metrics.CollectorFunc(func(w *metrics.Writer) {
+		stats, err := store.Stats()
+		if err != nil {
+			logger.Error("error reading database stats", "error", err)
+		}
+		w.Counter("recipe_boltdb_read_tx_total", "Read transactions started since the database was opened.", float64(stats.ReadTxTotal))
+		w.Gauge("recipe_boltdb_open_read_tx", "Read transactions currently open.", float64(stats.OpenReadTx))
+		w.Counter("recipe_boltdb_page_allocs_total", "Pages allocated by write transactions.", float64(stats.PageAllocs))
+		w.Counter("recipe_boltdb_page_alloc_bytes_total", "Bytes allocated for pages by write transactions.", float64(stats.PageAllocSize))
+		w.Gauge("recipe_boltdb_free_pages", "Pages on the freelist ready for reuse.", float64(stats.FreePages))
+		w.Gauge("recipe_boltdb_pending_pages", "Freed pages still held by open read transactions.", float64(stats.PendingPages))
+		w.Gauge("recipe_boltdb_free_bytes", "Bytes in free pages.", float64(stats.FreeBytes))
+		w.Gauge("recipe_boltdb_freelist_bytes", "Bytes used by the freelist.", float64(stats.FreelistBytes))
+		w.Gauge("recipe_boltdb_file_size_bytes", "Size of the database file.", float64(stats.FileSize))
+	}))
+
+	// Scrapes have no request context to pass on; the store's own timeout still applies
+	recipes := storage.WithoutContext(store, 0)
+	registry.Register(metrics.CollectorFunc(func(w *metrics.Writer) {
+		recipes, err := recipes.List()
+		if err != nil {
+			logger.Error("error counting recipes", "error", err)
+			return
+		}
+		counts := make(map[models.Visibility]int)
+		for _, recipe := range recipes {
+			counts[recipe.Visibility]++
+		}
+
+		w.Family("recipe_recipes", "Stored recipes, by visibility.", "gauge")
+		for _, visibility := range models.Visibilities {
+			w.Sample("recipe_recipes", float64(counts[visibility]), metrics.Label{Name: "visibility", Value: string(visibility)})
+		}
+	}))
+}
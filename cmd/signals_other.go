@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"go_recipe_app/internal/logging"
+	"log/slog"
+)
+
+// watchDebugSignal does nothing where there's no SIGUSR1; use the admin endpoint instead
+func watchDebugSignal(levels *logging.Levels, logger *slog.Logger) func() {
+	return func() {}
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package main
+
+import (
+	"go_recipe_app/internal/logging"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchDebugSignal turns debug logging on for everything when SIGUSR1 arrives,
+// and back to the previous base level on the next one
+// Overrides set for single components are left alone. Call the returned function to stop watching
+func watchDebugSignal(levels *logging.Levels, logger *slog.Logger) func() {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+
+	go func() {
+		previous := levels.Base()
+		for range usr1 {
+			if levels.Base() != slog.LevelDebug {
+				previous = levels.Base()
+				levels.SetBase(slog.LevelDebug)
+				logger.Info("SIGUSR1 received, debug logging on")
+			} else {
+				levels.SetBase(previous)
+				logger.Info("SIGUSR1 received, debug logging off", "level", previous.String())
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(usr1)
+		close(usr1)
+	}
+}
@@ -4,9 +4,10 @@ go 1.23
 
 toolchain go1.23.7
 
-require github.com/gorilla/mux v1.8.1
-
 require (
-	go.etcd.io/bbolt v1.4.0 // indirect
-	golang.org/x/sys v0.29.0 // indirect
+	github.com/gorilla/mux v1.8.1
+	go.etcd.io/bbolt v1.4.0
+	golang.org/x/crypto v0.32.0
 )
+
+require golang.org/x/sys v0.29.0 // indirect
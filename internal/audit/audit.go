@@ -0,0 +1,149 @@
+// Package audit records who changed which recipe, when and from where
+package audit
+
+import (
+	"context"
+	"errors"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/logging"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Errors returned by Restore
+var (
+	ErrNotRestorable = errors.New("only deleted recipes can be restored")
+	ErrRecipeExists  = errors.New("a recipe with that ID exists again")
+)
+
+// Store wraps a ContextRecipeStore, adding an audit entry for every change that succeeds
+// Reads pass straight through. The acting user and their IP come from the context
+type Store struct {
+	next   storage.ContextRecipeStore
+	audit  storage.AuditStore
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewStore wraps next, writing the audit trail to audit
+func NewStore(next storage.ContextRecipeStore, audit storage.AuditStore, logger *slog.Logger) *Store {
+	return &Store{next: next, audit: audit, logger: logger, now: time.Now}
+}
+
+func (s *Store) List(ctx context.Context) ([]models.Recipe, error) {
+	return s.next.List(ctx)
+}
+
+func (s *Store) ListVisible(ctx context.Context, viewer models.Viewer) ([]models.Recipe, error) {
+	return s.next.ListVisible(ctx, viewer)
+}
+
+func (s *Store) Get(ctx context.Context, id string) (models.Recipe, error) {
+	return s.next.Get(ctx, id)
+}
+
+func (s *Store) Create(ctx context.Context, recipe models.Recipe) error {
+	if err := s.next.Create(ctx, recipe); err != nil {
+		return err
+	}
+	s.record(ctx, models.AuditEntry{Action: models.AuditCreate, RecipeID: recipe.ID, RecipeTitle: recipe.Title})
+	return nil
+}
+
+// Update reads the recipe first so the entry can say which fields changed
+func (s *Store) Update(ctx context.Context, recipe models.Recipe) error {
+	old, getErr := s.next.Get(ctx, recipe.ID)
+	if err := s.next.Update(ctx, recipe); err != nil {
+		return err
+	}
+
+	entry := models.AuditEntry{Action: models.AuditUpdate, RecipeID: recipe.ID, RecipeTitle: recipe.Title}
+	if getErr == nil {
+		entry.Changes = models.DiffRecipes(old, recipe)
+	}
+	s.record(ctx, entry)
+	return nil
+}
+
+// Delete keeps a copy of the recipe in the entry, which is what Restore brings back
+func (s *Store) Delete(ctx context.Context, id string) error {
+	old, getErr := s.next.Get(ctx, id)
+	if err := s.next.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	entry := models.AuditEntry{Action: models.AuditDelete, RecipeID: id}
+	if getErr == nil {
+		entry.RecipeTitle = old.Title
+		entry.Snapshot = &old
+	}
+	s.record(ctx, entry)
+	return nil
+}
+
+// Restore recreates the recipe saved in a delete entry
+func (s *Store) Restore(ctx context.Context, entryID string) (models.Recipe, error) {
+	entry, err := s.audit.GetAudit(entryID)
+	if err != nil {
+		return models.Recipe{}, err
+	}
+	if entry.Action != models.AuditDelete || entry.Snapshot == nil {
+		return models.Recipe{}, ErrNotRestorable
+	}
+
+	// Create refuses to overwrite a recipe that has since been recreated with the same ID
+	recipe := *entry.Snapshot
+	err = s.next.Create(ctx, recipe)
+	switch {
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return models.Recipe{}, ErrRecipeExists
+	case err != nil:
+		return models.Recipe{}, err
+	}
+	s.record(ctx, models.AuditEntry{Action: models.AuditRestore, RecipeID: recipe.ID, RecipeTitle: recipe.Title})
+	return recipe, nil
+}
+
+// record fills in who, when and where, and appends the entry
+// The change itself has already happened, so a failure here is logged rather than returned
+func (s *Store) record(ctx context.Context, entry models.AuditEntry) {
+	entry.Time = s.now().UTC()
+	if user, ok := auth.UserFromContext(ctx); ok {
+		entry.UserID = user.ID
+		entry.Username = user.Username
+	}
+	entry.IP = ClientIP(ctx)
+
+	if _, err := s.audit.AppendAudit(entry); err != nil {
+		logging.FromContextOr(ctx, s.logger).Error("could not write audit entry",
+			slog.String("action", string(entry.Action)),
+			slog.String("recipe_id", entry.RecipeID),
+			slog.Any("error", err))
+	}
+}
+
+type ipKey struct{}
+
+// WithClientIP returns a context carrying the address of the client making the request
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ipKey{}, ip)
+}
+
+// ClientIP returns the client address stored by WithClientIP, or ""
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(ipKey{}).(string)
+	return ip
+}
+
+// Middleware stores each request's client address for the audit entries it causes
+// clientIP decides where the address comes from, e.g. trusting a proxy's headers
+func Middleware(clientIP func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithClientIP(r.Context(), clientIP(r))))
+		})
+	}
+}
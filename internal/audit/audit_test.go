@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"go_recipe_app/internal/storage/memory"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestStoreRecordsChanges(t *testing.T) {
+	mem := memory.New()
+	s := NewStore(mem, mem, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx := auth.WithUser(context.Background(), models.User{ID: "user-1", Username: "sam"})
+	ctx = WithClientIP(ctx, "192.0.2.7")
+
+	recipe := models.Recipe{ID: "recipe-1", Title: "Lasagna", Servings: 4}
+	if err := s.Create(ctx, recipe); err != nil {
+		t.Fatal(err)
+	}
+	recipe.Title, recipe.Servings = "Lasagne", 6
+	if err := s.Update(ctx, recipe); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, recipe.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := mem.ListAudit(storage.AuditFilter{RecipeID: "recipe-1"})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("ListAudit = %d entries, %v; want 3", len(entries), err)
+	}
+	del, update := entries[0], entries[1]
+	if del.Action != models.AuditDelete || del.Snapshot == nil || del.Snapshot.Title != "Lasagne" {
+		t.Errorf("delete entry = %+v, want a snapshot of the recipe", del)
+	}
+	if update.Username != "sam" || update.IP != "192.0.2.7" {
+		t.Errorf("update entry by %q from %q, want sam from 192.0.2.7", update.Username, update.IP)
+	}
+	want := []models.FieldChange{{Field: "title", Old: "Lasagna", New: "Lasagne"}, {Field: "servings", Old: "4", New: "6"}}
+	if len(update.Changes) != len(want) || update.Changes[0] != want[0] || update.Changes[1] != want[1] {
+		t.Errorf("changes = %v, want %v", update.Changes, want)
+	}
+
+	// Restoring brings the recipe back once; a second restore would overwrite it
+	restored, err := s.Restore(ctx, del.ID)
+	if err != nil || restored.Title != "Lasagne" {
+		t.Fatalf("Restore = %v, %v", restored, err)
+	}
+	if _, err := mem.Get(ctx, "recipe-1"); err != nil {
+		t.Errorf("restored recipe not found: %v", err)
+	}
+	if _, err := s.Restore(ctx, del.ID); !errors.Is(err, ErrRecipeExists) {
+		t.Errorf("second Restore error = %v, want ErrRecipeExists", err)
+	}
+	if _, err := s.Restore(ctx, update.ID); !errors.Is(err, ErrNotRestorable) {
+		t.Errorf("Restore of an update error = %v, want ErrNotRestorable", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Package auth handles user accounts, passwords and cookie sessions
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// SessionCookieName is the cookie holding the session token
+const SessionCookieName = "recipe_session"
+
+// Options configures a Manager
+type Options struct {
+	SessionTTL    time.Duration // how long a login lasts
+	SecureCookies bool          // only send the cookie over HTTPS
+	APIPath       string        // where TokenMiddleware guards every route, e.g. "/api"; these skip the CSRF check
+}
+
+// Manager creates and checks users and sessions
+type Manager struct {
+	users    storage.UserStore
+	sessions storage.SessionStore
+	tokens   storage.TokenStore
+	groups   storage.GroupStore
+	opts     Options
+	logger   *slog.Logger
+}
+
+// NewManager creates a new Manager
+func NewManager(users storage.UserStore, sessions storage.SessionStore, tokens storage.TokenStore, groups storage.GroupStore, opts Options, logger *slog.Logger) *Manager {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = 30 * 24 * time.Hour
+	}
+	return &Manager{
+		users:    users,
+		sessions: sessions,
+		tokens:   tokens,
+		groups:   groups,
+		opts:     opts,
+		logger:   logger,
+	}
+}
+
+// HasUsers reports whether any account exists yet
+// The very first account is allowed to register and becomes an admin
+func (m *Manager) HasUsers() (bool, error) {
+	users, err := m.users.ListUsers()
+	if err != nil {
+		return false, err
+	}
+	return len(users) > 0, nil
+}
+
+// CreateUser validates and stores a new account
+func (m *Manager) CreateUser(username, password string, role models.Role) (models.User, error) {
+	if err := ValidateUsername(username); err != nil {
+		return models.User{}, err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	id, err := NewID("user")
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{
+		ID:           id,
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := m.users.CreateUser(user); err != nil {
+		return models.User{}, err
+	}
+
+	m.logger.Info("User created", slog.String("user_id", user.ID), slog.String("username", user.Username), slog.String("role", string(role)))
+	return user, nil
+}
+
+// ChangePassword sets a new password after checking the current one
+// Whoever might have known the old password is locked out: every other session
+// ends and the user's API tokens are revoked. keepSession is the session ID
+// making the change, which stays logged in
+func (m *Manager) ChangePassword(user models.User, current, next, keepSession string) error {
+	if !CheckPassword(user.PasswordHash, current) {
+		return ErrInvalidCredentials
+	}
+	hash, err := HashPassword(next)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	if err := m.users.UpdateUser(user); err != nil {
+		return err
+	}
+
+	sessions, err := m.sessions.DeleteUserSessions(user.ID, keepSession)
+	if err != nil {
+		return fmt.Errorf("could not end other sessions: %w", err)
+	}
+	tokens, err := m.tokens.ListTokens(user.ID)
+	if err != nil {
+		return fmt.Errorf("could not list tokens to revoke: %w", err)
+	}
+	for _, token := range tokens {
+		if err := m.tokens.DeleteToken(token.ID); err != nil {
+			return fmt.Errorf("could not revoke token: %w", err)
+		}
+	}
+
+	m.logger.Info("Password changed", slog.String("user_id", user.ID),
+		slog.Int("sessions_ended", sessions), slog.Int("tokens_revoked", len(tokens)))
+	return nil
+}
+
+// Authenticate returns the user for a username and password
+func (m *Manager) Authenticate(username, password string) (models.User, error) {
+	user, err := m.users.GetUserByUsername(username)
+	if errors.Is(err, storage.ErrNotFound) {
+		CheckPassword(string(dummyHash), password)
+		return models.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if !CheckPassword(user.PasswordHash, password) {
+		return models.User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// StartSession logs the user in by creating a session and setting its cookie
+func (m *Manager) StartSession(w http.ResponseWriter, user models.User) error {
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+	csrfToken, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	session := models.Session{
+		ID:        hashToken(token),
+		UserID:    user.ID,
+		CSRFToken: csrfToken,
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.opts.SessionTTL),
+	}
+	if err := m.sessions.CreateSession(session); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		MaxAge:   int(m.opts.SessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   m.opts.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// EndSession logs the browser out, deleting the session and clearing its cookie
+func (m *Manager) EndSession(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		if err := m.sessions.DeleteSession(hashToken(cookie.Value)); err != nil {
+			m.logger.Error("Error deleting session", slog.Any("error", err))
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   m.opts.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// DeleteExpiredSessions clears out sessions that can no longer be used
+func (m *Manager) DeleteExpiredSessions() (int, error) {
+	return m.sessions.DeleteExpiredSessions(time.Now().UTC())
+}
+
+// Middleware loads the session cookie and puts the logged in user on the request context
+// Requests without a valid session carry on as visitors
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(SessionCookieName)
+		if err != nil || cookie.Value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := m.sessions.GetSession(hashToken(cookie.Value))
+		if err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
+				m.logger.Error("Error loading session", slog.Any("error", err))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if session.Expired(time.Now()) {
+			if err := m.sessions.DeleteSession(session.ID); err != nil {
+				m.logger.Error("Error deleting expired session", slog.Any("error", err))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := m.users.GetUser(session.UserID)
+		if err != nil {
+			m.logger.Error("Error loading session user", slog.Any("error", err))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := m.withUser(r.Context(), user)
+		ctx = context.WithValue(ctx, sessionKey, session)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withUser puts the user and their group memberships on the context
+// If the groups can't be loaded the user still gets in, but only sees their own and public recipes
+func (m *Manager) withUser(ctx context.Context, user models.User) context.Context {
+	viewer := models.Viewer{UserID: user.ID, Admin: user.IsAdmin()}
+
+	groups, err := m.groups.ListGroupsForUser(user.ID)
+	if err != nil {
+		m.logger.Error("Error loading user groups", slog.Any("error", err))
+	}
+	if len(groups) > 0 {
+		viewer.Groups = make(map[string]models.GroupRole, len(groups))
+		for _, group := range groups {
+			viewer.Groups[group.ID], _ = group.RoleOf(user.ID)
+		}
+	}
+
+	ctx = WithUser(ctx, user)
+	return context.WithValue(ctx, viewerKey, viewer)
+}
+
+// NewID returns a random identifier such as "user-1f2e3d4c5b6a7988"
+func NewID(prefix string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate id: %v", err)
+	}
+	return prefix + "-" + hex.EncodeToString(b), nil
+}
+
+// randomToken returns a secret suitable for a cookie or bearer token
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how tokens are stored, so the database never holds a usable secret
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
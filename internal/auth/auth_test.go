@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"errors"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage/memory"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestManager returns a Manager backed by a fresh in-memory store
+func newTestManager(t *testing.T) (*Manager, *memory.Store) {
+	t.Helper()
+	store := memory.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewManager(store, store, store, store, Options{SessionTTL: time.Hour}, logger), store
+}
+
+// login starts a session for user and returns its cookie
+func login(t *testing.T, m *Manager, user models.User) *http.Cookie {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	if err := m.StartSession(rec, user); err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == SessionCookieName {
+			return cookie
+		}
+	}
+	t.Fatal("StartSession set no session cookie")
+	return nil
+}
+
+// whoAmI runs a request through Middleware and reports the user it found
+func whoAmI(m *Manager, cookie *http.Cookie) (models.User, bool) {
+	var user models.User
+	var ok bool
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok = UserFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/recipes", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	return user, ok
+}
+
+func TestPasswords(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "correct horse" {
+		t.Fatal("HashPassword returned the password")
+	}
+	if !CheckPassword(hash, "correct horse") {
+		t.Error("CheckPassword rejected the right password")
+	}
+	if CheckPassword(hash, "correct horsE") {
+		t.Error("CheckPassword accepted the wrong password")
+	}
+
+	for _, password := range []string{"short", string(make([]byte, MaxPasswordLength+1))} {
+		if _, err := HashPassword(password); !errors.Is(err, ErrInvalidPassword) {
+			t.Errorf("HashPassword(%d bytes) = %v, want ErrInvalidPassword", len(password), err)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	m, _ := newTestManager(t)
+	created, err := m.CreateUser("alice", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CreateUser("ALICE", "correct horse", models.RoleMember); err == nil {
+		t.Error("CreateUser allowed a username differing only in case")
+	}
+
+	tests := []struct {
+		username string
+		password string
+		wantErr  error
+	}{
+		{"alice", "correct horse", nil},
+		{"Alice", "correct horse", nil}, // usernames ignore case
+		{"alice", "wrong password", ErrInvalidCredentials},
+		{"bob", "correct horse", ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		user, err := m.Authenticate(tt.username, tt.password)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Authenticate(%q, %q) = %v, want %v", tt.username, tt.password, err, tt.wantErr)
+			continue
+		}
+		if err == nil && user.ID != created.ID {
+			t.Errorf("Authenticate(%q) = user %s, want %s", tt.username, user.ID, created.ID)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	m, store := newTestManager(t)
+	user, err := m.CreateUser("alice", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := login(t, m, user)
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("session cookie = %+v, want HttpOnly and SameSite=Lax", cookie)
+	}
+
+	// Only the hash of the cookie is stored, and the cookie is looked up by it
+	if _, err := store.GetSession(cookie.Value); err == nil {
+		t.Error("session stored under the raw cookie value")
+	}
+	session, err := store.GetSession(hashToken(cookie.Value))
+	if err != nil {
+		t.Fatalf("session not stored under the cookie's hash: %v", err)
+	}
+	if got, ok := whoAmI(m, cookie); !ok || got.ID != user.ID {
+		t.Errorf("Middleware found %+v, %v, want %s", got, ok, user.ID)
+	}
+
+	// Visitors and made-up cookies carry on as visitors
+	if _, ok := whoAmI(m, nil); ok {
+		t.Error("Middleware found a user without a cookie")
+	}
+	if _, ok := whoAmI(m, &http.Cookie{Name: SessionCookieName, Value: "made-up"}); ok {
+		t.Error("Middleware accepted an unknown session")
+	}
+	if _, ok := whoAmI(m, &http.Cookie{Name: SessionCookieName, Value: session.ID}); ok {
+		t.Error("Middleware accepted the stored hash as a cookie")
+	}
+
+	// An expired session is refused and cleared out
+	session.ExpiresAt = time.Now().Add(-time.Second)
+	if err := store.CreateSession(session); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := whoAmI(m, cookie); ok {
+		t.Error("Middleware accepted an expired session")
+	}
+	if _, err := store.GetSession(session.ID); err == nil {
+		t.Error("expired session was not deleted")
+	}
+
+	// Logging out deletes the session and clears the cookie
+	cookie = login(t, m, user)
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	m.EndSession(rec, req)
+	if cleared := rec.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Errorf("EndSession cookies = %+v, want the session cookie cleared", cleared)
+	}
+	if _, ok := whoAmI(m, cookie); ok {
+		t.Error("session still works after EndSession")
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	m, store := newTestManager(t)
+	alice, err := m.CreateUser("alice", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := m.CreateUser("bob", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	laptop, phone, bobs := login(t, m, alice), login(t, m, alice), login(t, m, bob)
+	secret, _, err := m.CreateToken(alice, "script", []models.Scope{models.ScopeRecipesRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSecret, _, err := m.CreateToken(bob, "script", []models.Scope{models.ScopeRecipesRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.ChangePassword(alice, "wrong horse", "battery staple", hashToken(laptop.Value)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("ChangePassword with the wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if _, ok := whoAmI(m, phone); !ok {
+		t.Fatal("a failed change ended the other session")
+	}
+
+	if err := m.ChangePassword(alice, "correct horse", "battery staple", hashToken(laptop.Value)); err != nil {
+		t.Fatalf("ChangePassword = %v", err)
+	}
+	if _, err := m.Authenticate("alice", "battery staple"); err != nil {
+		t.Errorf("new password rejected: %v", err)
+	}
+
+	// Only the session that made the change stays logged in, and alice's tokens stop working
+	if _, ok := whoAmI(m, laptop); !ok {
+		t.Error("the session that changed the password was logged out")
+	}
+	if _, ok := whoAmI(m, phone); ok {
+		t.Error("another session still works after the password changed")
+	}
+	if _, _, err := m.AuthenticateToken(secret); err == nil {
+		t.Error("API token still works after the password changed")
+	}
+	if tokens, _ := store.ListTokens(alice.ID); len(tokens) != 0 {
+		t.Errorf("tokens = %+v, want none", tokens)
+	}
+
+	// Other users are left alone
+	if _, ok := whoAmI(m, bobs); !ok {
+		t.Error("bob was logged out")
+	}
+	if _, _, err := m.AuthenticateToken(bobSecret); err != nil {
+		t.Errorf("bob's token was revoked: %v", err)
+	}
+}
+
+func TestDeleteExpiredSessions(t *testing.T) {
+	m, store := newTestManager(t)
+	now := time.Now().UTC()
+	for id, expires := range map[string]time.Time{
+		"old":     now.Add(-time.Hour),
+		"current": now.Add(time.Hour),
+	} {
+		if err := store.CreateSession(models.Session{ID: id, UserID: "user-1", ExpiresAt: expires}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := m.DeleteExpiredSessions()
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredSessions = %d, %v, want 1", deleted, err)
+	}
+	if _, err := store.GetSession("old"); err == nil {
+		t.Error("expired session kept")
+	}
+	if _, err := store.GetSession("current"); err != nil {
+		t.Errorf("current session deleted: %v", err)
+	}
+}
+
+func TestRequireLogin(t *testing.T) {
+	m, _ := newTestManager(t)
+	member, err := m.CreateUser("member", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := m.CreateUser("admin", "correct horse", models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	tests := []struct {
+		name         string
+		user         *models.User
+		method       string
+		guard        func(http.HandlerFunc) http.HandlerFunc
+		wantStatus   int
+		wantLocation string
+	}{
+		{"visitor page", nil, "GET", RequireLogin, http.StatusSeeOther, "/login?next=%2Frecipes%3Fq%3Dsoup"},
+		{"visitor post", nil, "POST", RequireLogin, http.StatusUnauthorized, ""},
+		{"member", &member, "GET", RequireLogin, http.StatusOK, ""},
+		{"visitor admin page", nil, "GET", RequireAdmin, http.StatusSeeOther, "/login?next=%2Frecipes%3Fq%3Dsoup"},
+		{"member admin page", &member, "GET", RequireAdmin, http.StatusForbidden, ""},
+		{"member admin post", &member, "POST", RequireAdmin, http.StatusForbidden, ""},
+		{"admin", &admin, "GET", RequireAdmin, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/recipes?q=soup", nil)
+			if tt.user != nil {
+				req.AddCookie(login(t, m, *tt.user))
+			}
+			rec := httptest.NewRecorder()
+			m.Middleware(tt.guard(ok)).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"go_recipe_app/internal/models"
+	"net/http"
+	"net/url"
+)
+
+// contextKey is unexported so no other package can collide with our context values
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	sessionKey
+	tokenKey
+	csrfKey
+	viewerKey
+)
+
+// WithUser returns a copy of ctx carrying the logged in user
+func WithUser(ctx context.Context, user models.User) context.Context {
+	return context.WithValue(ctx, userKey, user)
+}
+
+// UserFromContext returns the logged in user, if there is one
+func UserFromContext(ctx context.Context) (models.User, bool) {
+	user, ok := ctx.Value(userKey).(models.User)
+	return user, ok
+}
+
+// CurrentUser returns the logged in user for a request, or nil for visitors
+// Handy for templates, where nil reads as "not logged in"
+func CurrentUser(r *http.Request) *models.User {
+	user, ok := UserFromContext(r.Context())
+	if !ok {
+		return nil
+	}
+	return &user
+}
+
+// SessionFromContext returns the browser session behind the request, if there is one
+func SessionFromContext(ctx context.Context) (models.Session, bool) {
+	session, ok := ctx.Value(sessionKey).(models.Session)
+	return session, ok
+}
+
+// RequireLogin only lets logged in users through
+// Page views are redirected to the login form, anything else gets a 401
+func RequireLogin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := UserFromContext(r.Context()); !ok {
+			if r.Method == http.MethodGet {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+			http.Error(w, "Login required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireAdmin only lets admins through
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return RequireLogin(func(w http.ResponseWriter, r *http.Request) {
+		if user, _ := UserFromContext(r.Context()); !user.IsAdmin() {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// CurrentViewer describes who is making the request, including their group roles
+// Visitors get the zero Viewer, which only sees public recipes
+func CurrentViewer(r *http.Request) models.Viewer {
+	viewer, _ := r.Context().Value(viewerKey).(models.Viewer)
+	return viewer
+}
+
+// CanView reports whether the requester may see recipe
+func CanView(r *http.Request, recipe models.Recipe) bool {
+	return recipe.VisibleTo(CurrentViewer(r))
+}
+
+// CanEdit reports whether the requester may change recipe
+func CanEdit(r *http.Request, recipe models.Recipe) bool {
+	return recipe.EditableBy(CurrentViewer(r))
+}
+
+// CanManage reports whether the requester may change who can see recipe, or delete it
+func CanManage(r *http.Request, recipe models.Recipe) bool {
+	return recipe.ManagedBy(CurrentViewer(r))
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"go_recipe_app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	m, store := newTestManager(t)
+	m.opts.APIPath = "/api"
+	user, err := m.CreateUser("alice", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := login(t, m, user)
+	session, err := store.GetSession(hashToken(cookie.Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := m.CreateToken(user, "script", []models.Scope{models.ScopeRecipesWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen string
+	handler := m.Middleware(m.CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = CSRFToken(r)
+	})))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		field      string // sent as the form field
+		header     string // sent as the X-CSRF-Token header
+		bearer     string
+		wantStatus int
+	}{
+		{"GET isn't checked", "GET", "/recipes", "", "", "", http.StatusOK},
+		{"HEAD isn't checked", "HEAD", "/recipes", "", "", "", http.StatusOK},
+		{"missing token", "POST", "/recipes", "", "", "", http.StatusForbidden},
+		{"wrong token", "POST", "/recipes", "wrong", "", "", http.StatusForbidden},
+		{"wrong header", "DELETE", "/recipes/recipe-1", "", "wrong", "", http.StatusForbidden},
+		{"form field", "POST", "/recipes", session.CSRFToken, "", "", http.StatusOK},
+		{"header", "DELETE", "/recipes/recipe-1", "", session.CSRFToken, "", http.StatusOK},
+		// An Authorization header doesn't excuse a cookie-authenticated route, valid or not
+		{"garbage bearer on a web route", "DELETE", "/recipes/recipe-1", "", "", "garbage", http.StatusForbidden},
+		{"real bearer on a web route", "DELETE", "/recipes/recipe-1", "", "", secret, http.StatusForbidden},
+		{"API route", "DELETE", "/api/recipes/recipe-1", "", "", secret, http.StatusOK},
+		{"path that only starts like the API", "DELETE", "/apiary", "", "", secret, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body string
+			if tt.field != "" {
+				body = url.Values{CSRFFieldName: {tt.field}}.Encode()
+			}
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(cookie)
+			if tt.header != "" {
+				req.Header.Set(CSRFHeaderName, tt.header)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			seen = ""
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusOK && !strings.HasPrefix(tt.path, "/api/") && seen != session.CSRFToken {
+				t.Errorf("page sees token %q, want the session's", seen)
+			}
+		})
+	}
+}
+
+func TestCSRFVisitorCookie(t *testing.T) {
+	m, _ := newTestManager(t)
+	handler := m.Middleware(m.CSRFMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	// A visitor's first page view issues the token in a cookie
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/login", nil))
+	var issued *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			issued = c
+		}
+	}
+	if issued == nil || issued.Value == "" || !issued.HttpOnly {
+		t.Fatalf("visitor cookie = %+v, want an HttpOnly token", issued)
+	}
+
+	// The login form then has to send it back
+	post := func(token string) int {
+		form := url.Values{"username": {"alice"}}
+		if token != "" {
+			form.Set(CSRFFieldName, token)
+		}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(issued)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post(issued.Value); code != http.StatusOK {
+		t.Errorf("login with the cookie's token = %d, want 200", code)
+	}
+	if code := post(""); code != http.StatusForbidden {
+		t.Errorf("login without a token = %d, want 403", code)
+	}
+
+	// No cookie is issued again once the visitor has one
+	req := httptest.NewRequest("GET", "/login", nil)
+	req.AddCookie(issued)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if len(rec.Result().Cookies()) != 0 {
+		t.Errorf("cookies = %v, want none", rec.Result().Cookies())
+	}
+}
@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TokenPrefix starts every API token secret so they're easy to spot in config files and leaks
+const TokenPrefix = "rcp_"
+
+// lastUsedResolution limits how often a busy token's last-used time is written
+const lastUsedResolution = time.Minute
+
+var (
+	ErrInvalidToken     = errors.New("invalid or revoked API token")
+	ErrInvalidTokenName = errors.New("token name must be 1-64 characters")
+	ErrInvalidScope     = errors.New("unknown or forbidden scope")
+)
+
+// CreateToken mints a new API token for user
+// The returned secret is the only copy - only its hash is stored
+func (m *Manager) CreateToken(user models.User, name string, scopes []models.Scope) (string, models.APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 {
+		return "", models.APIToken{}, ErrInvalidTokenName
+	}
+	if len(scopes) == 0 {
+		return "", models.APIToken{}, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !validScope(scope) || (scope == models.ScopeAdmin && !user.IsAdmin()) {
+			return "", models.APIToken{}, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	random, err := randomToken()
+	if err != nil {
+		return "", models.APIToken{}, err
+	}
+	secret := TokenPrefix + random
+
+	id, err := NewID("tok")
+	if err != nil {
+		return "", models.APIToken{}, err
+	}
+
+	token := models.APIToken{
+		ID:        id,
+		UserID:    user.ID,
+		Name:      name,
+		Hash:      hashToken(secret),
+		Prefix:    secret[:len(TokenPrefix)+6],
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := m.tokens.CreateToken(token); err != nil {
+		return "", models.APIToken{}, err
+	}
+
+	m.logger.Info("API token created", slog.String("token_id", token.ID), slog.String("user_id", user.ID))
+	return secret, token, nil
+}
+
+// ListTokens returns the user's tokens, newest first
+func (m *Manager) ListTokens(user models.User) ([]models.APIToken, error) {
+	tokens, err := m.tokens.ListTokens(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// RevokeToken deletes one of the user's tokens
+// Admins may revoke anyone's token
+func (m *Manager) RevokeToken(user models.User, id string) error {
+	token, err := m.tokens.GetToken(id)
+	if err != nil {
+		return err
+	}
+	if token.UserID != user.ID && !user.IsAdmin() {
+		return fmt.Errorf("token %s: %w", id, storage.ErrNotFound)
+	}
+
+	if err := m.tokens.DeleteToken(id); err != nil {
+		return err
+	}
+	m.logger.Info("API token revoked", slog.String("token_id", id), slog.String("user_id", user.ID))
+	return nil
+}
+
+// AuthenticateToken returns the token and its owner for a bearer secret
+func (m *Manager) AuthenticateToken(secret string) (models.APIToken, models.User, error) {
+	if !strings.HasPrefix(secret, TokenPrefix) {
+		return models.APIToken{}, models.User{}, ErrInvalidToken
+	}
+
+	token, err := m.tokens.GetTokenByHash(hashToken(secret))
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.APIToken{}, models.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return models.APIToken{}, models.User{}, err
+	}
+
+	user, err := m.users.GetUser(token.UserID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.APIToken{}, models.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return models.APIToken{}, models.User{}, err
+	}
+
+	// Don't write on every request from a chatty script
+	now := time.Now().UTC()
+	if now.Sub(token.LastUsedAt) >= lastUsedResolution {
+		if err := m.tokens.TouchToken(token.ID, now); err != nil {
+			m.logger.Warn("Error updating token last used", slog.Any("error", err))
+		}
+		token.LastUsedAt = now
+	}
+	return token, user, nil
+}
+
+// TokenMiddleware requires a valid "Authorization: Bearer <token>" header
+// It puts the token and its owner on the request context, replacing any browser session
+func (m *Manager) TokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := bearerToken(r)
+		if !ok {
+			writeAuthError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		token, user, err := m.AuthenticateToken(secret)
+		if errors.Is(err, ErrInvalidToken) {
+			writeAuthError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			m.logger.Error("Error authenticating API token", slog.Any("error", err))
+			writeAuthError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		ctx := m.withUser(r.Context(), user)
+		ctx = context.WithValue(ctx, tokenKey, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// TokenFromContext returns the API token behind the request, if there is one
+func TokenFromContext(ctx context.Context) (models.APIToken, bool) {
+	token, ok := ctx.Value(tokenKey).(models.APIToken)
+	return token, ok
+}
+
+// RequireScope only lets through token requests that carry scope
+// The admin scope also needs the owner to still be an admin
+func RequireScope(scope models.Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := TokenFromContext(r.Context())
+		if !ok {
+			writeAuthError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		if !token.HasScope(scope) {
+			writeAuthError(w, http.StatusForbidden, fmt.Sprintf("token lacks the %s scope", scope))
+			return
+		}
+		if user, _ := UserFromContext(r.Context()); scope == models.ScopeAdmin && !user.IsAdmin() {
+			writeAuthError(w, http.StatusForbidden, "token owner is no longer an admin")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, secret, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(secret) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(secret), true
+}
+
+func writeAuthError(w http.ResponseWriter, status int, msg string) {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="recipes"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func validScope(scope models.Scope) bool {
+	for _, s := range models.AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
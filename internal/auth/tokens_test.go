@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"errors"
+	"go_recipe_app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateToken(t *testing.T) {
+	m, store := newTestManager(t)
+	user, err := m.CreateUser("alice", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, token, err := m.CreateToken(user, "script", []models.Scope{models.ScopeRecipesRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := store.GetToken(token.ID); stored.Hash == secret || stored.Hash != hashToken(secret) {
+		t.Errorf("token stored with hash %q, want the secret's hash", stored.Hash)
+	}
+
+	got, owner, err := m.AuthenticateToken(secret)
+	if err != nil || got.ID != token.ID || owner.ID != user.ID {
+		t.Fatalf("AuthenticateToken = %s, %s, %v; want %s owned by %s", got.ID, owner.ID, err, token.ID, user.ID)
+	}
+	if _, _, err := m.AuthenticateToken(TokenPrefix + "made-up"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unknown token: %v, want ErrInvalidToken", err)
+	}
+	if _, _, err := m.AuthenticateToken(token.Hash); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("stored hash used as a token: %v, want ErrInvalidToken", err)
+	}
+
+	if err := m.RevokeToken(user, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.AuthenticateToken(secret); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("revoked token: %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestTokenLastUsed(t *testing.T) {
+	m, store := newTestManager(t)
+	user, err := m.CreateUser("alice", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, token, err := m.CreateToken(user, "script", []models.Scope{models.ScopeRecipesRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastUsed := func() time.Time {
+		stored, err := store.GetToken(token.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored.LastUsedAt
+	}
+
+	if _, _, err := m.AuthenticateToken(secret); err != nil {
+		t.Fatal(err)
+	}
+	first := lastUsed()
+	if first.IsZero() {
+		t.Fatal("first use didn't record LastUsedAt")
+	}
+
+	// A busy token isn't written on every request
+	if _, _, err := m.AuthenticateToken(secret); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); !got.Equal(first) {
+		t.Errorf("LastUsedAt moved from %v to %v within %v", first, got, lastUsedResolution)
+	}
+
+	// Once the last write is old enough, the next use records again
+	if err := store.TouchToken(token.ID, first.Add(-2*lastUsedResolution)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.AuthenticateToken(secret); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); !got.After(first.Add(-lastUsedResolution)) {
+		t.Errorf("LastUsedAt = %v, want it refreshed", got)
+	}
+}
+
+func TestTokenMiddleware(t *testing.T) {
+	m, store := newTestManager(t)
+	member, err := m.CreateUser("member", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := m.CreateUser("admin", "correct horse", models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly, _, err := m.CreateToken(member, "reader", []models.Scope{models.ScopeRecipesRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readWrite, _, err := m.CreateToken(member, "writer", []models.Scope{models.ScopeRecipesRead, models.ScopeRecipesWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken, _, err := m.CreateToken(admin, "admin", []models.Scope{models.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.CreateToken(member, "sneaky", []models.Scope{models.ScopeAdmin}); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("member minted an admin token: %v", err)
+	}
+	revoked, revokedToken, err := m.CreateToken(member, "old", []models.Scope{models.ScopeRecipesRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RevokeToken(member, revokedToken.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	tests := []struct {
+		name       string
+		header     string
+		scope      models.Scope
+		wantStatus int
+	}{
+		{"no header", "", models.ScopeRecipesRead, http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + readOnly, models.ScopeRecipesRead, http.StatusUnauthorized},
+		{"empty token", "Bearer ", models.ScopeRecipesRead, http.StatusUnauthorized},
+		{"garbage", "Bearer garbage", models.ScopeRecipesRead, http.StatusUnauthorized},
+		{"revoked", "Bearer " + revoked, models.ScopeRecipesRead, http.StatusUnauthorized},
+		{"read", "Bearer " + readOnly, models.ScopeRecipesRead, http.StatusOK},
+		{"lower case scheme", "bearer " + readOnly, models.ScopeRecipesRead, http.StatusOK},
+		{"read-only token writing", "Bearer " + readOnly, models.ScopeRecipesWrite, http.StatusForbidden},
+		{"write", "Bearer " + readWrite, models.ScopeRecipesWrite, http.StatusOK},
+		{"member without admin scope", "Bearer " + readWrite, models.ScopeAdmin, http.StatusForbidden},
+		{"admin scope implies write", "Bearer " + adminToken, models.ScopeRecipesWrite, http.StatusOK},
+		{"admin", "Bearer " + adminToken, models.ScopeAdmin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/recipes", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			m.TokenMiddleware(RequireScope(tt.scope, ok)).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+
+	// An admin token stops granting admin once its owner is demoted
+	admin.Role = models.RoleMember
+	if err := store.UpdateUser(admin); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	rec := httptest.NewRecorder()
+	m.TokenMiddleware(RequireScope(models.ScopeAdmin, ok)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("demoted admin's token: status %d, want 403", rec.Code)
+	}
+}
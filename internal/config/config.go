@@ -1,97 +1,291 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"go_recipe_app/internal/logging"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
+	"strings"
 	"time"
 )
 
 type Config struct {
 	// Server settings
-	Port    int
-	Env     string
-	BaseURL string
+	Port               int
+	Env                string
+	BaseURL            string
+	ShutdownTimeout    time.Duration // how long in-flight requests get to finish on shutdown
+	ShutdownDrainDelay time.Duration // how long /readyz fails before the listeners close
+	Dev                bool          // read templates and static files from WebDir and reload pages when they change
+	WebDir             string        // the repository's web directory, used in development mode
+
+	// TLS settings - leave the cert and key empty to serve plain HTTP (e.g. behind nginx)
+	TLSCertFile      string
+	TLSKeyFile       string
+	TLSMinVersion    string // "1.2" or "1.3"
+	HTTPRedirectPort int    // when serving TLS, also listen here and redirect to HTTPS; 0 disables
 
 	// Database settings
-	DBPath    string
-	DBTimeout time.Duration
+	DBPath          string
+	DBTimeout       time.Duration // waiting for the database file lock, and the longest a recipe query may take
+	DBSlowThreshold time.Duration // recipe queries slower than this log a warning; 0 turns it off
+	MinFreeDisk     uint64        // readiness fails when the database's filesystem has less space than this, in bytes
 
 	// Logging settings
-	LogDir    string
-	LogLevel  string
-	LogFormat string // json or text
-	LogPath   string
+	LogDir         string
+	LogLevel       string   // debug, info, warn or error; can be changed at runtime
+	LogLevels      []string // per-component overrides like "storage=debug"
+	LogFormat      string   // json or text
+	LogPath        string
+	ErrorLogPath   string        // errors are written here as well as to LogPath
+	LogMaxSize     int64         // rotate log files before they pass this many bytes; 0 means no limit
+	LogMaxAge      time.Duration // rotate log files after this long; 0 means no limit
+	LogMaxFiles    int           // rotated log files to keep; 0 keeps them all
+	LogCompress    bool          // gzip rotated log files
+	LogRedactKeys  []string      // log attributes with these names are masked, e.g. password
+	LogMaxValueLen int           // longer string values are cut in logs; 0 means no limit
 
 	// Security settings
-	AllowedOrigins []string
-	ReadTimeout    time.Duration
-	WriteTimeout   time.Duration
+	AllowedOrigins    []string // origins allowed to make cross-site requests; "*" allows any
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration // how long a keep-alive connection may sit unused
+	ReadHeaderTimeout time.Duration // how long a client gets to send the request headers
+	MaxHeaderBytes    int
+	MaxBodyBytes      int64 // largest request body accepted; login and other small forms get less
+	MaxFormBytes      int64 // largest body for forms with a few short fields, like login
+	MaxProgressBytes  int64 // largest cook mode progress update
+	TrustProxy        bool  // take the client address from X-Real-IP / X-Forwarded-For set by nginx
+
+	// Rate limits, in requests per minute per client, with bursts of up to the burst size
+	RateLimit       int
+	RateBurst       int
+	LoginRateLimit  int // login and registration attempts, per IP address
+	LoginRateBurst  int
+	ImportRateLimit int // API recipe creates and audit log restores, per token or user
+	ImportRateBurst int
+
+	// Monitoring settings
+	MetricsToken string // if set, /metrics requires "Authorization: Bearer <token>"
+
+	// Account settings
+	SessionTTL  time.Duration
+	AllowSignup bool // let anyone register; the first account can always register
+
+	// How the config was loaded
+	ConfigFile  string // file the settings were read from, if any
+	PrintConfig bool   // --print-config was given: print the config and exit instead of serving
 }
 
-func Load() (*Config, error) {
-	port, err := strconv.Atoi(getEnvWithDefault("RECIPE_APP_PORT", "8080"))
-	if err != nil {
-		return nil, fmt.Errorf("invalid port: %v", err)
+// Load builds the config from, lowest precedence first:
+//
+//  1. the defaults in the settings table
+//  2. the config file named by --config or RECIPE_APP_CONFIG, if any
+//  3. environment variables, e.g. RECIPE_APP_PORT (empty ones are ignored)
+//  4. command line flags, e.g. --server-port
+//
+// so a flag beats the environment, which beats the file. args are the command line
+// arguments without the program name. Every problem found is reported together; the
+// returned config is still filled in when there are problems, for --print-config
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("recipe-app", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("RECIPE_APP_CONFIG"), "config file (TOML); env RECIPE_APP_CONFIG")
+	printConfig := fs.Bool("print-config", false, "print the effective config, with secrets masked, and exit")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.key] = fs.String(s.flagName(), "", fmt.Sprintf("%s (default %q, env %s)", s.usage, s.def, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
 	}
 
-	readTimeout, err := time.ParseDuration(getEnvWithDefault("RECIPE_APP_READ_TIMEOUT", "15s"))
-	if err != nil {
-		return nil, fmt.Errorf("invalid read timeout: %v", err)
+	var errs []error
+
+	// Layer each source over the one before
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.key] = s.def
+	}
+	if *configFile != "" {
+		fileValues, err := readFile(*configFile)
+		errs = append(errs, err)
+		for key, v := range fileValues {
+			values[key] = v
+		}
 	}
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			values[s.key] = v
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if f.Name == s.flagName() {
+				values[s.key] = *flagValues[s.key]
+			}
+		}
+	})
 
-	writeTimeout, err := time.ParseDuration(getEnvWithDefault("RECIPE_APP_WRITE_TIMEOUT", "15s"))
-	if err != nil {
-		return nil, fmt.Errorf("invalid write timeout: %v", err)
+	// Start from the defaults so a value that doesn't parse leaves a sensible one behind,
+	// and checking the rest of the config can carry on
+	config := &Config{ConfigFile: *configFile, PrintConfig: *printConfig}
+	for _, s := range settings {
+		s.set(config, s.def)
+		if err := s.set(config, values[s.key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s, --%s): %v", s.key, s.env, s.flagName(), err))
+		}
 	}
 
-	config := &Config{
-		// Server settings
-		Port:    port,
-		Env:     getEnvWithDefault("RECIPE_APP_ENV", "development"),
-		BaseURL: getEnvWithDefault("RECIPE_APP_BASE_URL", "http://localhost:8080"),
+	// Settings that follow from others
+	config.LogPath = filepath.Join(config.LogDir, "app.log")
+	config.ErrorLogPath = filepath.Join(config.LogDir, "error.log")
 
-		// Database settings
-		DBPath:    getEnvWithDefault("RECIPE_APP_DB_PATH", "data/recipes.db"),
-		DBTimeout: time.Second,
+	errs = append(errs, config.validate())
+	return config, errors.Join(errs...)
+}
 
-		// Logging settings
-		LogDir:    getEnvWithDefault("RECIPE_APP_LOG_DIR", "logs"),
-		LogLevel:  getEnvWithDefault("RECIPE_APP_LOG_LEVEL", "info"),
-		LogFormat: getEnvWithDefault("RECIPE_APP_LOG_FORMAT", "text"),
-		LogPath: filepath.Join(
-			getEnvWithDefault("RECIPE_APP_LOG_DIR", "logs"),
-			"app.log",
-		),
+// readFile reads a config file, rejecting keys that aren't settings so typos don't go unnoticed
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %v", err)
+	}
+	values, err := parseTOML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %v", path, err)
+	}
 
-		// Security settings
-		ReadTimeout:  readTimeout,
-		WriteTimeout: writeTimeout,
-		AllowedOrigins: []string{
-			getEnvWithDefault("RECIPE_APP_ALLOWED_ORIGIN", "*"),
-		},
+	var errs []error
+	for key := range values {
+		if _, ok := lookupSetting(key); !ok {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %s", path, key))
+			delete(values, key)
+		}
 	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return values, errors.Join(errs...)
+}
+
+// TLSEnabled reports whether the server should serve HTTPS itself
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
 
-	return config, config.validate()
+// Secure reports whether browsers reach the app over HTTPS, either directly or through a proxy
+// Cookies are only marked Secure, and HSTS only sent, when this is true
+func (c *Config) Secure() bool {
+	return c.TLSEnabled() || strings.HasPrefix(c.BaseURL, "https://")
 }
 
-func getEnvWithDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// splitList turns a comma separated value like "https://a.example, https://b.example" into a slice
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	return defaultValue
+	return items
 }
 
+// validate checks the settings make sense together, reporting every problem it finds
 func (c *Config) validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
 	if c.Port < 1 || c.Port > 65535 {
-		return fmt.Errorf("port must be between 1 and 65535")
+		fail("port must be between 1 and 65535")
+	}
+
+	// A zero timeout means "wait forever", which lets slow clients hold connections open
+	if c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 || c.ReadHeaderTimeout <= 0 {
+		fail("server timeouts must be positive")
+	}
+
+	if c.Dev {
+		if info, err := os.Stat(filepath.Join(c.WebDir, "templates")); err != nil || !info.IsDir() {
+			fail("development mode needs the web directory, but %s has no templates directory", c.WebDir)
+		}
+	}
+
+	if c.DBTimeout <= 0 {
+		fail("db timeout must be positive")
+	}
+
+	if err := logging.NewLevels(0).Apply(c.LogLevel, c.LogLevels); err != nil {
+		fail("invalid log level: %v", err)
+	}
+
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		fail("log format must be json or text")
+	}
+
+	if c.LogMaxValueLen < 0 {
+		fail("log max value bytes can't be negative")
+	}
+
+	if c.LogMaxSize < 0 || c.LogMaxAge < 0 || c.LogMaxFiles < 0 {
+		fail("log rotation limits can't be negative")
+	}
+
+	if c.DBSlowThreshold < 0 {
+		fail("db slow threshold can't be negative")
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		fail("shutdown timeout must be positive")
+	}
+	if c.ShutdownDrainDelay < 0 {
+		fail("shutdown drain delay can't be negative")
+	}
+
+	if c.MaxHeaderBytes < 4096 {
+		fail("max header bytes must be at least 4096")
+	}
+
+	if c.MaxBodyBytes < 1024 || c.MaxFormBytes < 1024 || c.MaxProgressBytes < 1024 {
+		fail("max body, form and progress bytes must be at least 1024")
+	}
+
+	if c.RateLimit < 1 || c.RateBurst < 1 || c.LoginRateLimit < 1 || c.LoginRateBurst < 1 ||
+		c.ImportRateLimit < 1 || c.ImportRateBurst < 1 {
+		fail("rate limits and bursts must be at least 1")
+	}
+
+	for _, origin := range c.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			fail("allowed origin %q must be \"*\" or start with http:// or https://", origin)
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		fail("tls cert and key must be set together")
+	}
+
+	if c.TLSMinVersion != "1.2" && c.TLSMinVersion != "1.3" {
+		fail("tls min version must be 1.2 or 1.3")
+	}
+
+	if c.HTTPRedirectPort != 0 {
+		if !c.TLSEnabled() {
+			fail("http redirect port needs tls to be enabled")
+		}
+		if c.HTTPRedirectPort < 1 || c.HTTPRedirectPort > 65535 || c.HTTPRedirectPort == c.Port {
+			fail("http redirect port must be between 1 and 65535 and differ from the port")
+		}
 	}
 
-	// Create log directory if it doesn't exist
-	if err := os.MkdirAll(c.LogDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %v", err)
+	if c.SessionTTL <= 0 {
+		fail("session ttl must be positive")
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	logDir := filepath.Join(t.TempDir(), "logs")
+	path := writeConfig(t, `
+# Values here lose to the environment, which loses to flags
+[server]
+port = 9000
+read_timeout = "30s"
+max_form_bytes = 8192
+allowed_origins = ["https://a.example", "https://b.example"]
+
+[log]
+dir = "`+logDir+`"
+level = "warn" # inline comments are fine
+
+[metrics]
+token = "s3cret#not-a-comment"
+`)
+	t.Setenv("RECIPE_APP_PORT", "9100")
+	t.Setenv("RECIPE_APP_LOG_LEVEL", "debug")
+
+	cfg, err := Load([]string{"--config", path, "--log-level", "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9100 {
+		t.Errorf("port = %d, want the environment's 9100", cfg.Port)
+	}
+	if cfg.LogLevel != "error" {
+		t.Errorf("log level = %q, want the flag's error", cfg.LogLevel)
+	}
+	if cfg.ReadTimeout != 30*time.Second || len(cfg.AllowedOrigins) != 2 || cfg.MetricsToken != "s3cret#not-a-comment" {
+		t.Errorf("file values not applied: %v %v %q", cfg.ReadTimeout, cfg.AllowedOrigins, cfg.MetricsToken)
+	}
+	if cfg.MaxFormBytes != 8192 || cfg.MaxProgressBytes != 64<<10 {
+		t.Errorf("form cap = %d, progress cap = %d, want the file's 8192 and the default", cfg.MaxFormBytes, cfg.MaxProgressBytes)
+	}
+	if cfg.WriteTimeout != 15*time.Second || cfg.LoginRateBurst != 5 || cfg.ImportRateLimit != 30 {
+		t.Errorf("write timeout = %v, login burst = %d, import limit = %d, want the defaults",
+			cfg.WriteTimeout, cfg.LoginRateBurst, cfg.ImportRateLimit)
+	}
+	if _, err := os.Stat(logDir); !os.IsNotExist(err) {
+		t.Errorf("Load touched the log directory (%v); creating it is left to the logger", err)
+	}
+
+	// The printed config reads back as the same config, with the secret masked
+	var out strings.Builder
+	if err := cfg.Print(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "s3cret") {
+		t.Error("Print shows the metrics token")
+	}
+	printed, err := parseTOML(out.String())
+	if err != nil {
+		t.Fatalf("printed config doesn't parse: %v\n%s", err, out.String())
+	}
+	if printed["server.port"] != "9100" || printed["server.allowed_origins"] != "https://a.example,https://b.example" {
+		t.Errorf("printed port %q origins %q", printed["server.port"], printed["server.allowed_origins"])
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, `
+[server]
+port = 70000
+prot = 8080
+
+[log]
+dir = "`+t.TempDir()+`"
+max_age = "a while"
+`)
+	_, err := Load([]string{"--config", path, "--tls-min-version", "1.1", "--rate-limit-login-burst", "0"})
+	if err == nil {
+		t.Fatal("Load succeeded, want errors")
+	}
+	for _, want := range []string{"unknown setting server.prot", "log.max_age", "port must be between", "tls min version", "bursts must be at least 1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error is missing %q:\n%v", want, err)
+		}
+	}
+}
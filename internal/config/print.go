@@ -0,0 +1,60 @@
+// Printing the effective config for --print-config
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// masked stands in for secrets when printing
+const masked = "********"
+
+// Print writes the config as a TOML file, with secrets masked
+// The output can be saved and used as the config file. Each setting notes the
+// environment variable and flag that can override it
+func (c *Config) Print(w io.Writer) error {
+	var b strings.Builder
+	if c.ConfigFile != "" {
+		fmt.Fprintf(&b, "# Effective config, read from %s, the environment and flags\n", c.ConfigFile)
+	} else {
+		fmt.Fprintf(&b, "# Effective config, read from the defaults, the environment and flags\n")
+	}
+
+	section := ""
+	for _, s := range settings {
+		name, key, _ := strings.Cut(s.key, ".")
+		if name != section {
+			section = name
+			fmt.Fprintf(&b, "\n[%s]\n", section)
+		}
+
+		value := s.get(c)
+		if s.secret && value != "" {
+			value = masked
+		}
+		fmt.Fprintf(&b, "%s = %s # %s; env %s, flag --%s\n", key, formatTOML(s.kind, value), s.usage, s.env, s.flagName())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatTOML writes a value the way parseTOML reads it back
+func formatTOML(k kind, value string) string {
+	switch k {
+	case kindNumber, kindBool:
+		return value
+	case kindList:
+		items := splitList(value)
+		quoted := make([]string, len(items))
+		for i, item := range items {
+			quoted[i] = strconv.Quote(item)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	default:
+		return strconv.Quote(value)
+	}
+}
@@ -0,0 +1,213 @@
+// The table of settings: where each one is read from, its default, and how it's parsed
+
+package config
+
+import (
+	"fmt"
+	"go_recipe_app/internal/logging"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kind says how a setting's value is written in the config file
+type kind int
+
+const (
+	kindString kind = iota
+	kindNumber
+	kindBool
+	kindList
+)
+
+// setting is one configurable value
+// Every setting can come from the config file, an environment variable or a flag.
+// The names follow from key: "log.max_age" is max_age under [log] in the file,
+// and --log-max-age on the command line
+type setting struct {
+	key    string // section.name in the config file
+	env    string
+	def    string // default, in the same form as an environment variable
+	usage  string
+	kind   kind
+	secret bool // masked by --print-config
+	set    func(c *Config, value string) error
+	get    func(c *Config) string
+}
+
+// flagName is the command line flag for the setting
+func (s setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+// settings lists every setting in the order --print-config shows them
+var settings = []setting{
+	// Server settings
+	intSetting("server.port", "RECIPE_APP_PORT", "8080", "port to listen on", func(c *Config) *int { return &c.Port }),
+	stringSetting("server.env", "RECIPE_APP_ENV", "development", "environment name, e.g. production", func(c *Config) *string { return &c.Env }),
+	stringSetting("server.base_url", "RECIPE_APP_BASE_URL", "http://localhost:8080", "URL browsers reach the app at", func(c *Config) *string { return &c.BaseURL }),
+	durationSetting("server.shutdown_timeout", "RECIPE_APP_SHUTDOWN_TIMEOUT", "20s", "time in-flight requests get to finish on shutdown", func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	durationSetting("server.shutdown_drain_delay", "RECIPE_APP_SHUTDOWN_DRAIN_DELAY", "5s", "time /readyz fails before shutdown stops taking connections, so load balancers notice", func(c *Config) *time.Duration { return &c.ShutdownDrainDelay }),
+	durationSetting("server.read_timeout", "RECIPE_APP_READ_TIMEOUT", "15s", "time allowed to read a request", func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationSetting("server.write_timeout", "RECIPE_APP_WRITE_TIMEOUT", "15s", "time allowed to write a response", func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	durationSetting("server.idle_timeout", "RECIPE_APP_IDLE_TIMEOUT", "60s", "how long a keep-alive connection may sit unused", func(c *Config) *time.Duration { return &c.IdleTimeout }),
+	durationSetting("server.read_header_timeout", "RECIPE_APP_READ_HEADER_TIMEOUT", "5s", "time allowed to send the request headers", func(c *Config) *time.Duration { return &c.ReadHeaderTimeout }),
+	intSetting("server.max_header_bytes", "RECIPE_APP_MAX_HEADER_BYTES", "65536", "largest request headers accepted", func(c *Config) *int { return &c.MaxHeaderBytes }),
+	int64Setting("server.max_body_bytes", "RECIPE_APP_MAX_BODY_BYTES", "1048576", "largest request body accepted", func(c *Config) *int64 { return &c.MaxBodyBytes }),
+	int64Setting("server.max_form_bytes", "RECIPE_APP_MAX_FORM_BYTES", "16384", "largest login, account and group form accepted", func(c *Config) *int64 { return &c.MaxFormBytes }),
+	int64Setting("server.max_progress_bytes", "RECIPE_APP_MAX_PROGRESS_BYTES", "65536", "largest cook mode progress update accepted", func(c *Config) *int64 { return &c.MaxProgressBytes }),
+	boolSetting("server.trust_proxy", "RECIPE_APP_TRUST_PROXY", "false", "take the client address from X-Real-IP / X-Forwarded-For", func(c *Config) *bool { return &c.TrustProxy }),
+	boolSetting("server.dev", "RECIPE_APP_DEV", "false", "read templates and static files from web_dir and live reload pages", func(c *Config) *bool { return &c.Dev }),
+	stringSetting("server.web_dir", "RECIPE_APP_WEB_DIR", "web", "templates and static files directory for development mode", func(c *Config) *string { return &c.WebDir }),
+	listSetting("server.allowed_origins", "RECIPE_APP_ALLOWED_ORIGIN", "*", "origins allowed to make cross-site requests", func(c *Config) *[]string { return &c.AllowedOrigins }),
+
+	// TLS settings
+	stringSetting("tls.cert", "RECIPE_APP_TLS_CERT", "", "certificate file; serves HTTPS when set with tls.key", func(c *Config) *string { return &c.TLSCertFile }),
+	stringSetting("tls.key", "RECIPE_APP_TLS_KEY", "", "private key file", func(c *Config) *string { return &c.TLSKeyFile }),
+	stringSetting("tls.min_version", "RECIPE_APP_TLS_MIN_VERSION", "1.2", "oldest TLS version accepted, 1.2 or 1.3", func(c *Config) *string { return &c.TLSMinVersion }),
+	intSetting("tls.http_redirect_port", "RECIPE_APP_HTTP_REDIRECT_PORT", "0", "also listen here and redirect to HTTPS; 0 disables", func(c *Config) *int { return &c.HTTPRedirectPort }),
+
+	// Database settings
+	stringSetting("database.path", "RECIPE_APP_DB_PATH", "data/recipes.db", "database file", func(c *Config) *string { return &c.DBPath }),
+	durationSetting("database.timeout", "RECIPE_APP_DB_TIMEOUT", "1s", "longest wait for the database lock or a recipe query", func(c *Config) *time.Duration { return &c.DBTimeout }),
+	durationSetting("database.slow_threshold", "RECIPE_APP_DB_SLOW_THRESHOLD", "100ms", "recipe queries slower than this log a warning; 0 disables", func(c *Config) *time.Duration { return &c.DBSlowThreshold }),
+	megabytesSetting("database.min_free_disk_mb", "RECIPE_APP_MIN_FREE_DISK_MB", "100", "free space readiness needs next to the database, in MB",
+		func(c *Config) uint64 { return c.MinFreeDisk >> 20 }, func(c *Config, mb uint64) { c.MinFreeDisk = mb << 20 }),
+
+	// Logging settings
+	stringSetting("log.dir", "RECIPE_APP_LOG_DIR", "logs", "directory for app.log and error.log", func(c *Config) *string { return &c.LogDir }),
+	stringSetting("log.level", "RECIPE_APP_LOG_LEVEL", "info", "debug, info, warn or error", func(c *Config) *string { return &c.LogLevel }),
+	listSetting("log.levels", "RECIPE_APP_LOG_LEVELS", "", "per-component levels, e.g. storage=debug", func(c *Config) *[]string { return &c.LogLevels }),
+	stringSetting("log.format", "RECIPE_APP_LOG_FORMAT", "text", "json or text", func(c *Config) *string { return &c.LogFormat }),
+	megabytesSetting("log.max_size_mb", "RECIPE_APP_LOG_MAX_SIZE_MB", "100", "rotate log files at this size in MB; 0 disables",
+		func(c *Config) uint64 { return uint64(c.LogMaxSize >> 20) }, func(c *Config, mb uint64) { c.LogMaxSize = int64(mb << 20) }),
+	durationSetting("log.max_age", "RECIPE_APP_LOG_MAX_AGE", "24h", "rotate log files after this long; 0 disables", func(c *Config) *time.Duration { return &c.LogMaxAge }),
+	intSetting("log.max_files", "RECIPE_APP_LOG_MAX_FILES", "7", "rotated files to keep per log; 0 keeps all", func(c *Config) *int { return &c.LogMaxFiles }),
+	boolSetting("log.compress", "RECIPE_APP_LOG_COMPRESS", "true", "gzip rotated log files", func(c *Config) *bool { return &c.LogCompress }),
+	listSetting("log.redact_keys", "RECIPE_APP_LOG_REDACT_KEYS", strings.Join(logging.DefaultRedactKeys, ","), "log fields to mask", func(c *Config) *[]string { return &c.LogRedactKeys }),
+	intSetting("log.max_value_bytes", "RECIPE_APP_LOG_MAX_VALUE_BYTES", "2048", "longer log values are cut short; 0 disables", func(c *Config) *int { return &c.LogMaxValueLen }),
+
+	// Rate limits
+	intSetting("rate_limit.per_minute", "RECIPE_APP_RATE_LIMIT", "300", "requests per minute per user, or per IP when logged out", func(c *Config) *int { return &c.RateLimit }),
+	intSetting("rate_limit.burst", "RECIPE_APP_RATE_BURST", "60", "requests allowed at once before the limit applies", func(c *Config) *int { return &c.RateBurst }),
+	intSetting("rate_limit.login_per_minute", "RECIPE_APP_LOGIN_RATE_LIMIT", "10", "login and registration attempts per minute per IP", func(c *Config) *int { return &c.LoginRateLimit }),
+	intSetting("rate_limit.login_burst", "RECIPE_APP_LOGIN_RATE_BURST", "5", "login attempts allowed at once before the login limit applies", func(c *Config) *int { return &c.LoginRateBurst }),
+	intSetting("rate_limit.import_per_minute", "RECIPE_APP_IMPORT_RATE_LIMIT", "30", "recipes created or restored in bulk per minute, per API token or admin", func(c *Config) *int { return &c.ImportRateLimit }),
+	intSetting("rate_limit.import_burst", "RECIPE_APP_IMPORT_RATE_BURST", "10", "bulk creates or restores allowed at once before the import limit applies", func(c *Config) *int { return &c.ImportRateBurst }),
+
+	// Monitoring settings
+	secretSetting("metrics.token", "RECIPE_APP_METRICS_TOKEN", "bearer token /metrics requires; empty leaves it open", func(c *Config) *string { return &c.MetricsToken }),
+
+	// Account settings
+	durationSetting("accounts.session_ttl", "RECIPE_APP_SESSION_TTL", "720h", "how long a login lasts", func(c *Config) *time.Duration { return &c.SessionTTL }),
+	boolSetting("accounts.allow_signup", "RECIPE_APP_ALLOW_SIGNUP", "false", "let anyone register; the first account always can", func(c *Config) *bool { return &c.AllowSignup }),
+}
+
+// lookupSetting finds a setting by its config file key
+func lookupSetting(key string) (setting, bool) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+func stringSetting(key, env, def, usage string, field func(*Config) *string) setting {
+	return setting{
+		key: key, env: env, def: def, usage: usage, kind: kindString,
+		set: func(c *Config, v string) error { *field(c) = v; return nil },
+		get: func(c *Config) string { return *field(c) },
+	}
+}
+
+func secretSetting(key, env, usage string, field func(*Config) *string) setting {
+	s := stringSetting(key, env, "", usage, field)
+	s.secret = true
+	return s
+}
+
+func intSetting(key, env, def, usage string, field func(*Config) *int) setting {
+	return setting{
+		key: key, env: env, def: def, usage: usage, kind: kindNumber,
+		set: func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%q is not a whole number", v)
+			}
+			*field(c) = n
+			return nil
+		},
+		get: func(c *Config) string { return strconv.Itoa(*field(c)) },
+	}
+}
+
+func int64Setting(key, env, def, usage string, field func(*Config) *int64) setting {
+	return setting{
+		key: key, env: env, def: def, usage: usage, kind: kindNumber,
+		set: func(c *Config, v string) error {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%q is not a whole number", v)
+			}
+			*field(c) = n
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatInt(*field(c), 10) },
+	}
+}
+
+// megabytesSetting is a size given in MB but kept in bytes
+func megabytesSetting(key, env, def, usage string, get func(*Config) uint64, set func(*Config, uint64)) setting {
+	return setting{
+		key: key, env: env, def: def, usage: usage, kind: kindNumber,
+		set: func(c *Config, v string) error {
+			mb, err := strconv.ParseUint(v, 10, 64)
+			if err != nil || mb > 1<<43 {
+				return fmt.Errorf("%q is not a number of megabytes", v)
+			}
+			set(c, mb)
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatUint(get(c), 10) },
+	}
+}
+
+func boolSetting(key, env, def, usage string, field func(*Config) *bool) setting {
+	return setting{
+		key: key, env: env, def: def, usage: usage, kind: kindBool,
+		set: func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%q is not true or false", v)
+			}
+			*field(c) = b
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatBool(*field(c)) },
+	}
+}
+
+func durationSetting(key, env, def, usage string, field func(*Config) *time.Duration) setting {
+	return setting{
+		key: key, env: env, def: def, usage: usage, kind: kindString,
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%q is not a duration like 30s or 5m", v)
+			}
+			*field(c) = d
+			return nil
+		},
+		get: func(c *Config) string { return field(c).String() },
+	}
+}
+
+// listSetting is comma separated in the environment and on the command line, and an array in the file
+func listSetting(key, env, def, usage string, field func(*Config) *[]string) setting {
+	return setting{
+		key: key, env: env, def: def, usage: usage, kind: kindList,
+		set: func(c *Config, v string) error { *field(c) = splitList(v); return nil },
+		get: func(c *Config) string { return strings.Join(*field(c), ",") },
+	}
+}
@@ -0,0 +1,438 @@
+// internal/handlers/account/handler.go
+
+package account
+
+import (
+	"errors"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/logging"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler serves login, logout, registration and user management pages
+type Handler struct {
+	tmpl        *handlers.Renderer
+	logger      *slog.Logger
+	auth        *auth.Manager
+	users       storage.UserStore
+	allowSignup atomic.Bool // changed by a config reload while requests are running
+
+	// registerMu is held from checking for the first user until their account exists,
+	// so two registrations on a fresh instance can't both become admin
+	registerMu sync.Mutex
+}
+
+// loginPage is the template data for the login form
+type loginPage struct {
+	Username string
+	Next     string
+	Error    string
+}
+
+// registerPage is the template data for the registration form
+type registerPage struct {
+	Username  string
+	FirstUser bool
+	Error     string
+}
+
+// accountPage is the template data for the account settings page
+type accountPage struct {
+	Message string
+	Error   string
+}
+
+// usersPage is the template data for the admin user list
+type usersPage struct {
+	Users   []models.User
+	Message string
+	Error   string
+}
+
+// tokensPage is the template data for the API token page
+// NewSecret is only set straight after minting, the one time it can be shown
+type tokensPage struct {
+	Tokens    []models.APIToken
+	Scopes    []models.Scope
+	NewSecret string
+	Error     string
+}
+
+// New creates a new account Handler
+// allowSignup opens registration to anyone; otherwise only the first account can self-register
+func New(tmpl *handlers.Renderer, manager *auth.Manager, users storage.UserStore, allowSignup bool, logger *slog.Logger) *Handler {
+	h := &Handler{
+		tmpl:   tmpl,
+		logger: logger,
+		auth:   manager,
+		users:  users,
+	}
+	h.allowSignup.Store(allowSignup)
+	return h
+}
+
+// SetAllowSignup opens or closes registration, e.g. after the config is reloaded
+func (h *Handler) SetAllowSignup(allow bool) {
+	h.allowSignup.Store(allow)
+}
+
+// log returns the logger for a request, tagged with its request ID
+func (h *Handler) log(r *http.Request) *slog.Logger {
+	return logging.FromContextOr(r.Context(), h.logger)
+}
+
+// RegisterRoutes adds the account routes to a router
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/login", h.loginForm).Methods("GET")
+	r.HandleFunc("/login", h.login).Methods("POST")
+	r.HandleFunc("/logout", h.logout).Methods("POST")
+	r.HandleFunc("/register", h.registerForm).Methods("GET")
+	r.HandleFunc("/register", h.register).Methods("POST")
+	r.HandleFunc("/account", auth.RequireLogin(h.accountForm)).Methods("GET")
+	r.HandleFunc("/account/password", auth.RequireLogin(h.changePassword)).Methods("POST")
+	r.HandleFunc("/account/tokens", auth.RequireLogin(h.listTokens)).Methods("GET")
+	r.HandleFunc("/account/tokens", auth.RequireLogin(h.createToken)).Methods("POST")
+	r.HandleFunc("/account/tokens/{id}/revoke", auth.RequireLogin(h.revokeToken)).Methods("POST")
+	r.HandleFunc("/admin/users", auth.RequireAdmin(h.listUsers)).Methods("GET")
+	r.HandleFunc("/admin/users", auth.RequireAdmin(h.createUser)).Methods("POST")
+}
+
+// render executes the layout with the given page, logging any failure
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := h.tmpl.Render(w, handlers.NewTemplateData(r, name, data)); err != nil {
+		h.log(r).Error("Error executing template", slog.Any("error", err))
+	}
+}
+
+// Show the login form
+func (h *Handler) loginForm(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, http.StatusOK, "login", loginPage{Next: safeNext(r.URL.Query().Get("next"))})
+}
+
+// Check the username and password and start a session
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.log(r).Error("Error parsing form", slog.Any("error", err))
+		http.Error(w, "Error processing form", http.StatusBadRequest)
+		return
+	}
+
+	page := loginPage{
+		Username: strings.TrimSpace(r.FormValue("username")),
+		Next:     safeNext(r.FormValue("next")),
+	}
+
+	user, err := h.auth.Authenticate(page.Username, r.FormValue("password"))
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		h.log(r).Warn("Failed login", slog.String("username", page.Username))
+		page.Error = "Invalid username or password"
+		h.render(w, r, http.StatusUnauthorized, "login", page)
+		return
+	}
+	if err != nil {
+		h.log(r).Error("Error authenticating user", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.auth.StartSession(w, user); err != nil {
+		h.log(r).Error("Error starting session", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.log(r).Info("User logged in", slog.String("user_id", user.ID))
+	http.Redirect(w, r, page.Next, http.StatusSeeOther)
+}
+
+// End the session
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) {
+	h.auth.EndSession(w, r)
+	http.Redirect(w, r, "/recipes", http.StatusSeeOther)
+}
+
+// signupOpen reports whether a visitor may register, and whether they'd be the first user
+func (h *Handler) signupOpen() (open bool, first bool, err error) {
+	hasUsers, err := h.auth.HasUsers()
+	if err != nil {
+		return false, false, err
+	}
+	return h.allowSignup.Load() || !hasUsers, !hasUsers, nil
+}
+
+// Show the registration form
+func (h *Handler) registerForm(w http.ResponseWriter, r *http.Request) {
+	open, first, err := h.signupOpen()
+	if err != nil {
+		h.log(r).Error("Error checking for users", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !open {
+		http.Error(w, "Registration is closed - ask an admin for an account", http.StatusForbidden)
+		return
+	}
+
+	h.render(w, r, http.StatusOK, "register", registerPage{FirstUser: first})
+}
+
+// Create an account and log it in
+// The first account ever created becomes an admin
+func (h *Handler) register(w http.ResponseWriter, r *http.Request) {
+	// Read the form before taking the lock, so a slow client can't hold up everyone else
+	if err := r.ParseForm(); err != nil {
+		h.log(r).Error("Error parsing form", slog.Any("error", err))
+		http.Error(w, "Error processing form", http.StatusBadRequest)
+		return
+	}
+
+	h.registerMu.Lock()
+	defer h.registerMu.Unlock()
+
+	open, first, err := h.signupOpen()
+	if err != nil {
+		h.log(r).Error("Error checking for users", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !open {
+		http.Error(w, "Registration is closed - ask an admin for an account", http.StatusForbidden)
+		return
+	}
+
+	page := registerPage{
+		Username:  strings.TrimSpace(r.FormValue("username")),
+		FirstUser: first,
+	}
+	if r.FormValue("password") != r.FormValue("confirm_password") {
+		page.Error = "Passwords do not match"
+		h.render(w, r, http.StatusBadRequest, "register", page)
+		return
+	}
+
+	role := models.RoleMember
+	if first {
+		role = models.RoleAdmin
+	}
+
+	user, err := h.auth.CreateUser(page.Username, r.FormValue("password"), role)
+	if err != nil {
+		page.Error = userError(err)
+		if page.Error == "" {
+			h.log(r).Error("Error creating user", slog.Any("error", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.render(w, r, http.StatusBadRequest, "register", page)
+		return
+	}
+
+	if err := h.auth.StartSession(w, user); err != nil {
+		h.log(r).Error("Error starting session", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/recipes", http.StatusSeeOther)
+}
+
+// Show the account settings page
+func (h *Handler) accountForm(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, http.StatusOK, "account", accountPage{})
+}
+
+// Change the logged in user's password
+func (h *Handler) changePassword(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.log(r).Error("Error parsing form", slog.Any("error", err))
+		http.Error(w, "Error processing form", http.StatusBadRequest)
+		return
+	}
+
+	user := auth.CurrentUser(r)
+	if r.FormValue("new_password") != r.FormValue("confirm_password") {
+		h.render(w, r, http.StatusBadRequest, "account", accountPage{Error: "Passwords do not match"})
+		return
+	}
+
+	session, _ := auth.SessionFromContext(r.Context())
+	err := h.auth.ChangePassword(*user, r.FormValue("current_password"), r.FormValue("new_password"), session.ID)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		h.render(w, r, http.StatusBadRequest, "account", accountPage{Error: "Current password is wrong"})
+		return
+	}
+	if errors.Is(err, auth.ErrInvalidPassword) {
+		h.render(w, r, http.StatusBadRequest, "account", accountPage{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		h.log(r).Error("Error changing password", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.render(w, r, http.StatusOK, "account", accountPage{
+		Message: "Password changed. You've been logged out everywhere else and your API tokens were revoked",
+	})
+}
+
+// Show every user to an admin
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	h.renderUsers(w, r, http.StatusOK, usersPage{})
+}
+
+// Let an admin create an account for someone else
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.log(r).Error("Error parsing form", slog.Any("error", err))
+		http.Error(w, "Error processing form", http.StatusBadRequest)
+		return
+	}
+
+	role := models.RoleMember
+	if r.FormValue("role") == string(models.RoleAdmin) {
+		role = models.RoleAdmin
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	if _, err := h.auth.CreateUser(username, r.FormValue("password"), role); err != nil {
+		msg := userError(err)
+		if msg == "" {
+			h.log(r).Error("Error creating user", slog.Any("error", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.renderUsers(w, r, http.StatusBadRequest, usersPage{Error: msg})
+		return
+	}
+
+	h.renderUsers(w, r, http.StatusOK, usersPage{Message: "Created user " + username})
+}
+
+func (h *Handler) renderUsers(w http.ResponseWriter, r *http.Request, status int, page usersPage) {
+	users, err := h.users.ListUsers()
+	if err != nil {
+		h.log(r).Error("Error listing users", slog.Any("error", err))
+		http.Error(w, "Error getting users", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return strings.ToLower(users[i].Username) < strings.ToLower(users[j].Username)
+	})
+	page.Users = users
+	h.render(w, r, status, "users", page)
+}
+
+// Show the user's API tokens and the form to mint one
+func (h *Handler) listTokens(w http.ResponseWriter, r *http.Request) {
+	h.renderTokens(w, r, http.StatusOK, tokensPage{})
+}
+
+// Mint a new API token
+func (h *Handler) createToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.log(r).Error("Error parsing form", slog.Any("error", err))
+		http.Error(w, "Error processing form", http.StatusBadRequest)
+		return
+	}
+
+	var scopes []models.Scope
+	for _, scope := range r.Form["scopes[]"] {
+		scopes = append(scopes, models.Scope(scope))
+	}
+
+	secret, _, err := h.auth.CreateToken(*auth.CurrentUser(r), r.FormValue("name"), scopes)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidScope) || errors.Is(err, auth.ErrInvalidTokenName) {
+			h.renderTokens(w, r, http.StatusBadRequest, tokensPage{Error: err.Error()})
+			return
+		}
+		h.log(r).Error("Error creating token", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderTokens(w, r, http.StatusOK, tokensPage{NewSecret: secret})
+}
+
+// Revoke one of the user's API tokens
+func (h *Handler) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	err := h.auth.RevokeToken(*auth.CurrentUser(r), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.log(r).Error("Error revoking token", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+func (h *Handler) renderTokens(w http.ResponseWriter, r *http.Request, status int, page tokensPage) {
+	user := auth.CurrentUser(r)
+
+	tokens, err := h.auth.ListTokens(*user)
+	if err != nil {
+		h.log(r).Error("Error listing tokens", slog.Any("error", err))
+		http.Error(w, "Error getting tokens", http.StatusInternalServerError)
+		return
+	}
+	page.Tokens = tokens
+
+	// Only admins may hand the admin scope to a token
+	for _, scope := range models.AllScopes {
+		if scope != models.ScopeAdmin || user.IsAdmin() {
+			page.Scopes = append(page.Scopes, scope)
+		}
+	}
+	h.render(w, r, status, "tokens", page)
+}
+
+// userError turns account creation errors into a message for the form
+// It returns "" for errors the user can't fix
+func userError(err error) string {
+	switch {
+	case errors.Is(err, auth.ErrInvalidUsername), errors.Is(err, auth.ErrInvalidPassword):
+		return err.Error()
+	case errors.Is(err, storage.ErrAlreadyExists):
+		return "That username is taken"
+	default:
+		return ""
+	}
+}
+
+// safeNext only allows redirects to paths on this site, defaulting to the recipe list
+// Browsers drop tabs and newlines from a Location and treat a backslash as a slash,
+// so "/\t/evil.com" would leave the site as "//evil.com" - any of them is refused
+func safeNext(next string) string {
+	const fallback = "/recipes"
+	if strings.ContainsFunc(next, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) || r == '\\' }) {
+		return fallback
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil {
+		return fallback
+	}
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		return fallback
+	}
+	return next
+}
@@ -0,0 +1,173 @@
+package account
+
+import (
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage/memory"
+	"go_recipe_app/web"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestSafeNext(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{"/recipes/recipe-1", "/recipes/recipe-1"},
+		{"/recipes?q=soup#top", "/recipes?q=soup#top"},
+		{"/", "/"},
+		{"", "/recipes"},
+		{"recipes", "/recipes"},
+		{"//evil.com", "/recipes"},
+		{"///evil.com", "/recipes"},
+		{"https://evil.com", "/recipes"},
+		{"javascript:alert(1)", "/recipes"},
+		{`/\evil.com`, "/recipes"},
+		{`\\evil.com`, "/recipes"},
+		{"/\t/evil.com", "/recipes"},
+		{"/\r\n/evil.com", "/recipes"},
+		{"/\u00a0/evil.com", "/recipes"},
+		{"/\x00/evil.com", "/recipes"},
+		{"/ /evil.com", "/recipes"},
+	}
+	for _, tt := range tests {
+		if got := safeNext(tt.next); got != tt.want {
+			t.Errorf("safeNext(%q) = %q, want %q", tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestLoginRedirect(t *testing.T) {
+	store := memory.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager := auth.NewManager(store, store, store, store, auth.Options{}, logger)
+	if _, err := manager.CreateUser("alice", "correct horse", models.RoleMember); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	New(nil, manager, store, false, logger).RegisterRoutes(router)
+
+	// The form posts next back as the browser decoded it from the query string
+	for next, want := range map[string]string{
+		"/recipes/recipe-1": "/recipes/recipe-1",
+		"/%09/evil.com":     "/recipes",
+		"//evil.com":        "/recipes",
+	} {
+		decoded, _ := url.QueryUnescape(next)
+		form := url.Values{"username": {"alice"}, "password": {"correct horse"}, "next": {decoded}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != want {
+			t.Errorf("login with next=%s: %d to %q, want %q", next, rec.Code, rec.Header().Get("Location"), want)
+		}
+	}
+}
+
+func TestFirstUserIsOnlyAdmin(t *testing.T) {
+	store := memory.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager := auth.NewManager(store, store, store, store, auth.Options{}, logger)
+	router := mux.NewRouter()
+	New(nil, manager, store, true, logger).RegisterRoutes(router)
+
+	// Several people register on a fresh instance at once
+	var wg sync.WaitGroup
+	for _, username := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			form := url.Values{"username": {username}, "password": {"correct horse"}, "confirm_password": {"correct horse"}}
+			req := httptest.NewRequest("POST", "/register", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusSeeOther {
+				t.Errorf("%s registering = %d, want 303", username, rec.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	users, err := store.ListUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	admins := 0
+	for _, u := range users {
+		if u.IsAdmin() {
+			admins++
+		}
+	}
+	if len(users) != 5 || admins != 1 {
+		t.Errorf("%d users with %d admins, want 5 with exactly 1", len(users), admins)
+	}
+}
+
+func TestChangePasswordKeepsCurrentSession(t *testing.T) {
+	store := memory.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	files := web.Files("")
+	assets, err := web.NewAssets(files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := handlers.NewRenderer(web.Templates(files), assets.FuncMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := auth.NewManager(store, store, store, store, auth.Options{}, logger)
+	router := mux.NewRouter()
+	New(tmpl, manager, store, false, logger).RegisterRoutes(router)
+	handler := manager.Middleware(router)
+
+	alice, err := manager.CreateUser("alice", "correct horse", models.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cookies []*http.Cookie
+	for range 2 {
+		rec := httptest.NewRecorder()
+		if err := manager.StartSession(rec, alice); err != nil {
+			t.Fatal(err)
+		}
+		cookies = append(cookies, rec.Result().Cookies()[0])
+	}
+	get := func(cookie *http.Cookie) int {
+		req := httptest.NewRequest("GET", "/account", nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	form := url.Values{"current_password": {"correct horse"}, "new_password": {"battery staple"}, "confirm_password": {"battery staple"}}
+	req := httptest.NewRequest("POST", "/account/password", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookies[0])
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("change password = %d, want 200", rec.Code)
+	}
+
+	// The browser that changed the password carries on; the other one has to log in again
+	if code := get(cookies[0]); code != http.StatusOK {
+		t.Errorf("changing session = %d, want 200", code)
+	}
+	if code := get(cookies[1]); code != http.StatusSeeOther {
+		t.Errorf("other session = %d, want a redirect to log in", code)
+	}
+}
@@ -0,0 +1,307 @@
+// internal/handlers/api/handler.go
+
+// Package api serves the JSON API used by scripts and bots
+// Every route expects a bearer token - see auth.TokenMiddleware
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/logging"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"go_recipe_app/internal/timeline"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler holds the dependencies for the JSON API
+type Handler struct {
+	logger   *slog.Logger
+	store    storage.ContextRecipeStore
+	progress storage.CookProgressStore
+	users    storage.UserStore
+	groups   storage.GroupStore
+}
+
+// apiUser is a user as the API shows it - never with the password hash
+type apiUser struct {
+	ID        string      `json:"id"`
+	Username  string      `json:"username"`
+	Role      models.Role `json:"role"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// New creates a new API Handler
+func New(store storage.ContextRecipeStore, progress storage.CookProgressStore, users storage.UserStore, groups storage.GroupStore, logger *slog.Logger) *Handler {
+	return &Handler{
+		logger:   logger,
+		store:    store,
+		progress: progress,
+		users:    users,
+		groups:   groups,
+	}
+}
+
+// log returns the logger for a request, tagged with its request ID
+func (h *Handler) log(r *http.Request) *slog.Logger {
+	return logging.FromContextOr(r.Context(), h.logger)
+}
+
+// RegisterRoutes adds the API routes to r, which should be the /api subrouter
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	read := func(next http.HandlerFunc) http.HandlerFunc { return auth.RequireScope(models.ScopeRecipesRead, next) }
+	write := func(next http.HandlerFunc) http.HandlerFunc { return auth.RequireScope(models.ScopeRecipesWrite, next) }
+
+	r.HandleFunc("/recipes", read(h.listRecipes)).Methods("GET")
+	r.HandleFunc("/recipes", write(h.createRecipe)).Methods("POST")
+	r.HandleFunc("/recipes/{id}", read(h.getRecipe)).Methods("GET")
+	r.HandleFunc("/recipes/{id}", write(h.updateRecipe)).Methods("PUT")
+	r.HandleFunc("/recipes/{id}", write(h.deleteRecipe)).Methods("DELETE")
+	r.HandleFunc("/timeline", read(h.timeline)).Methods("GET")
+	r.HandleFunc("/users", auth.RequireScope(models.ScopeAdmin, h.listUsers)).Methods("GET")
+}
+
+func (h *Handler) listRecipes(w http.ResponseWriter, r *http.Request) {
+	recipes, err := h.store.ListVisible(r.Context(), auth.CurrentViewer(r))
+	if errors.Is(err, context.Canceled) {
+		h.log(r).Info("Client went away while listing recipes")
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		h.log(r).Warn("Listing recipes timed out")
+		writeError(w, http.StatusServiceUnavailable, "the database is busy, try again shortly")
+		return
+	}
+	if err != nil {
+		h.log(r).Error("Error listing recipes", slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, "error getting recipes")
+		return
+	}
+	if recipes == nil {
+		recipes = []models.Recipe{}
+	}
+	writeJSON(w, http.StatusOK, recipes)
+}
+
+func (h *Handler) getRecipe(w http.ResponseWriter, r *http.Request) {
+	recipe, err := h.getVisible(r, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeGetError(w, r, err, "recipe not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, recipe)
+}
+
+func (h *Handler) createRecipe(w http.ResponseWriter, r *http.Request) {
+	recipe, ok := h.decodeRecipe(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := auth.NewID("recipe")
+	if err != nil {
+		h.log(r).Error("Error generating recipe id", slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, "error saving recipe")
+		return
+	}
+	recipe.ID = id
+	recipe.OwnerID = auth.CurrentUser(r).ID
+	if recipe.Visibility == "" {
+		recipe.Visibility = models.VisibilityPrivate
+	}
+	if !h.checkSharing(w, r, recipe) {
+		return
+	}
+
+	if err := h.store.Create(r.Context(), recipe); err != nil {
+		h.log(r).Error("Error creating recipe", slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, "error saving recipe")
+		return
+	}
+	writeJSON(w, http.StatusCreated, recipe)
+}
+
+func (h *Handler) updateRecipe(w http.ResponseWriter, r *http.Request) {
+	existing, err := h.getVisible(r, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeGetError(w, r, err, "recipe not found")
+		return
+	}
+	if !auth.CanEdit(r, existing) {
+		writeError(w, http.StatusForbidden, "you can only edit your own recipes")
+		return
+	}
+
+	recipe, ok := h.decodeRecipe(w, r)
+	if !ok {
+		return
+	}
+	recipe.ID = existing.ID
+	recipe.OwnerID = existing.OwnerID
+
+	// Leaving visibility out keeps the current sharing; group editors can't change it at all
+	if recipe.Visibility == "" || !auth.CanManage(r, existing) {
+		recipe.Visibility, recipe.GroupID = existing.Visibility, existing.GroupID
+	} else if !h.checkSharing(w, r, recipe) {
+		return
+	}
+
+	if err := h.store.Update(r.Context(), recipe); err != nil {
+		h.log(r).Error("Error updating recipe", slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, "error updating recipe")
+		return
+	}
+	writeJSON(w, http.StatusOK, recipe)
+}
+
+func (h *Handler) deleteRecipe(w http.ResponseWriter, r *http.Request) {
+	existing, err := h.getVisible(r, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeGetError(w, r, err, "recipe not found")
+		return
+	}
+	if !auth.CanManage(r, existing) {
+		writeError(w, http.StatusForbidden, "you can only delete your own recipes")
+		return
+	}
+
+	if err := handlers.DeleteRecipe(r.Context(), h.store, h.progress, existing.ID, h.log(r)); err != nil {
+		h.log(r).Error("Error deleting recipe", slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, "error deleting recipe")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// timeline builds a cooking plan; serve is an RFC 3339 time
+func (h *Handler) timeline(w http.ResponseWriter, r *http.Request) {
+	ids, err := timeline.RecipeIDs(r.URL.Query()["recipe"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(ids) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one recipe is required")
+		return
+	}
+	serveAt, err := time.Parse(time.RFC3339, r.URL.Query().Get("serve"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "serve must be an RFC 3339 time")
+		return
+	}
+
+	recipes := make([]models.Recipe, 0, len(ids))
+	for _, id := range ids {
+		recipe, err := h.getVisible(r, id)
+		if err != nil {
+			h.writeGetError(w, r, err, "recipe not found: "+id)
+			return
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	writeJSON(w, http.StatusOK, timeline.Build(recipes, serveAt, time.Now()))
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.users.ListUsers()
+	if err != nil {
+		h.log(r).Error("Error listing users", slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, "error getting users")
+		return
+	}
+
+	out := make([]apiUser, len(users))
+	for i, u := range users {
+		out[i] = apiUser{ID: u.ID, Username: u.Username, Role: u.Role, CreatedAt: u.CreatedAt}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// decodeRecipe reads a recipe from the request body, writing an error response if it's unusable
+// It's held to the same rules as the web form; problems come back per field, like
+// {"error": "...", "fields": {"servings": "..."}}
+func (h *Handler) decodeRecipe(w http.ResponseWriter, r *http.Request) (models.Recipe, bool) {
+	var recipe models.Recipe
+	if err := json.NewDecoder(r.Body).Decode(&recipe); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid recipe JSON")
+		return models.Recipe{}, false
+	}
+
+	recipe.Title = strings.TrimSpace(recipe.Title)
+	if errs := recipe.Validate(); errs != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  errs.Error(),
+			"fields": errs,
+		})
+		return models.Recipe{}, false
+	}
+	recipe.Normalize()
+	return recipe, true
+}
+
+// getVisible loads a recipe the caller is allowed to see
+// Hidden recipes are reported as not found so their IDs don't leak
+func (h *Handler) getVisible(r *http.Request, id string) (models.Recipe, error) {
+	recipe, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		return models.Recipe{}, err
+	}
+	if !auth.CanView(r, recipe) {
+		return models.Recipe{}, fmt.Errorf("recipe %s: %w", id, storage.ErrNotFound)
+	}
+	return recipe, nil
+}
+
+// writeGetError reports a failed getVisible: 404 with notFound if the recipe doesn't exist
+// or is hidden, 503 if the database timed out, and 500 for anything else
+func (h *Handler) writeGetError(w http.ResponseWriter, r *http.Request, err error, notFound string) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeError(w, http.StatusNotFound, notFound)
+	case errors.Is(err, context.Canceled):
+		h.log(r).Info("Client went away while getting a recipe")
+	case errors.Is(err, context.DeadlineExceeded):
+		h.log(r).Warn("Getting a recipe timed out")
+		writeError(w, http.StatusServiceUnavailable, "the database is busy, try again shortly")
+	default:
+		h.log(r).Error("Error getting recipe", slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, "error getting recipe")
+	}
+}
+
+// checkSharing validates the recipe's visibility and group, writing an error response if they're unusable
+func (h *Handler) checkSharing(w http.ResponseWriter, r *http.Request, recipe models.Recipe) bool {
+	if err := auth.CurrentViewer(r).CheckSharing(recipe.Visibility, recipe.GroupID); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if recipe.GroupID != "" {
+		if _, err := h.groups.GetGroup(recipe.GroupID); err != nil {
+			writeError(w, http.StatusBadRequest, "group not found: "+recipe.GroupID)
+			return false
+		}
+	}
+	return true
+}
+
+// writeJSON sends v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends a JSON error body like {"error": "recipe not found"}
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"go_recipe_app/internal/storage/memory"
+	"go_recipe_app/internal/timeline"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// testAPI is the API wired up as main does it, backed by memory
+type testAPI struct {
+	router *mux.Router
+	auth   *auth.Manager
+	store  *memory.Store
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	return newTestAPIWith(t, func(store *memory.Store) storage.ContextRecipeStore { return store })
+}
+
+// newTestAPIWith serves recipes from whatever recipes returns, wrapping the memory store
+func newTestAPIWith(t *testing.T, recipes func(*memory.Store) storage.ContextRecipeStore) *testAPI {
+	t.Helper()
+	store := memory.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	manager := auth.NewManager(store, store, store, store, auth.Options{}, logger)
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(manager.TokenMiddleware)
+	New(recipes(store), store, store, store, logger).RegisterRoutes(api)
+	return &testAPI{router: router, auth: manager, store: store}
+}
+
+// token creates a user and returns a token for them with the given scopes
+func (a *testAPI) token(t *testing.T, username string, role models.Role, scopes ...models.Scope) string {
+	t.Helper()
+	user, err := a.auth.CreateUser(username, "correct horse", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := a.auth.CreateToken(user, "test", scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// do sends a request with token and decodes the JSON response into out, if given
+func (a *testAPI) do(t *testing.T, method, path, token, body string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	if out != nil && rec.Code < 300 {
+		if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return rec.Code
+}
+
+// recipeJSON is a valid recipe body with the given title and visibility
+func recipeJSON(title string, visibility models.Visibility) string {
+	return `{"title": "` + title + `", "visibility": "` + string(visibility) + `", "prep_time": 300000000000, "cook_time": 600000000000, "servings": 2,
+		"ingredients": [{"name": "Water", "amount": 1, "unit": "l"}],
+		"instructions": [{"step": "Boil"}]}`
+}
+
+func TestRecipeCRUD(t *testing.T) {
+	a := newTestAPI(t)
+	alice := a.token(t, "alice", models.RoleMember, models.ScopeRecipesRead, models.ScopeRecipesWrite)
+	bob := a.token(t, "bob", models.RoleMember, models.ScopeRecipesRead, models.ScopeRecipesWrite)
+	bobReader := a.token(t, "bob-reader", models.RoleMember, models.ScopeRecipesRead)
+
+	var private, public models.Recipe
+	if code := a.do(t, "POST", "/api/recipes", alice, recipeJSON("Secret Soup", models.VisibilityPrivate), &private); code != http.StatusCreated {
+		t.Fatalf("create private = %d", code)
+	}
+	if code := a.do(t, "POST", "/api/recipes", alice, recipeJSON("Soup", models.VisibilityPublic), &public); code != http.StatusCreated {
+		t.Fatalf("create public = %d", code)
+	}
+	if private.ID == "" || private.OwnerID == "" || private.Ingredients[0].Section != models.DefaultSection {
+		t.Errorf("created recipe = %+v, want an ID, owner and normalized sections", private)
+	}
+
+	// Bob sees the public recipe but can't change it, and can't see the private one at all
+	var listed []models.Recipe
+	if code := a.do(t, "GET", "/api/recipes", bob, "", &listed); code != http.StatusOK || len(listed) != 1 || listed[0].ID != public.ID {
+		t.Errorf("bob's list = %d %v, want just the public recipe", code, listed)
+	}
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"owner reads private", "GET", "/api/recipes/" + private.ID, alice, "", http.StatusOK},
+		{"other reads private", "GET", "/api/recipes/" + private.ID, bob, "", http.StatusNotFound},
+		{"other updates private", "PUT", "/api/recipes/" + private.ID, bob, recipeJSON("Mine", ""), http.StatusNotFound},
+		{"other reads public", "GET", "/api/recipes/" + public.ID, bob, "", http.StatusOK},
+		{"other updates public", "PUT", "/api/recipes/" + public.ID, bob, recipeJSON("Mine", ""), http.StatusForbidden},
+		{"other deletes public", "DELETE", "/api/recipes/" + public.ID, bob, "", http.StatusForbidden},
+		{"read-only token creates", "POST", "/api/recipes", bobReader, recipeJSON("Soup", ""), http.StatusForbidden},
+		{"read-only token deletes", "DELETE", "/api/recipes/" + public.ID, bobReader, "", http.StatusForbidden},
+		{"member lists users", "GET", "/api/users", alice, "", http.StatusForbidden},
+		{"bad JSON", "POST", "/api/recipes", alice, "{", http.StatusBadRequest},
+		{"missing recipe", "GET", "/api/recipes/recipe-missing", alice, "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := a.do(t, tt.method, tt.path, tt.token, tt.body, nil); code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", code, tt.wantStatus)
+			}
+		})
+	}
+
+	// Leaving visibility out of an update keeps the recipe's sharing
+	var updated models.Recipe
+	if code := a.do(t, "PUT", "/api/recipes/"+public.ID, alice, recipeJSON("Better Soup", ""), &updated); code != http.StatusOK {
+		t.Fatalf("owner update = %d", code)
+	}
+	if updated.Title != "Better Soup" || updated.Visibility != models.VisibilityPublic || updated.OwnerID != public.OwnerID {
+		t.Errorf("updated recipe = %+v", updated)
+	}
+
+	if code := a.do(t, "DELETE", "/api/recipes/"+public.ID, alice, "", nil); code != http.StatusNoContent {
+		t.Errorf("owner delete = %d, want 204", code)
+	}
+	if code := a.do(t, "GET", "/api/recipes/"+public.ID, alice, "", nil); code != http.StatusNotFound {
+		t.Errorf("deleted recipe = %d, want 404", code)
+	}
+}
+
+func TestGroupEditorCannotDelete(t *testing.T) {
+	a := newTestAPI(t)
+	alice := a.token(t, "alice", models.RoleMember, models.ScopeRecipesRead, models.ScopeRecipesWrite)
+	dave := a.token(t, "dave", models.RoleMember, models.ScopeRecipesRead, models.ScopeRecipesWrite)
+	aliceUser, _ := a.store.GetUserByUsername("alice")
+	daveUser, _ := a.store.GetUserByUsername("dave")
+	group := models.Group{ID: "group-family", Name: "Family", OwnerID: aliceUser.ID,
+		Members: []models.GroupMember{{UserID: daveUser.ID, Role: models.GroupEditor}}}
+	if err := a.store.CreateGroup(group); err != nil {
+		t.Fatal(err)
+	}
+
+	var recipe models.Recipe
+	body := strings.Replace(recipeJSON("Soup", models.VisibilityGroup), `"visibility"`, `"group_id": "group-family", "visibility"`, 1)
+	if code := a.do(t, "POST", "/api/recipes", alice, body, &recipe); code != http.StatusCreated {
+		t.Fatalf("create = %d", code)
+	}
+	path := "/api/recipes/" + recipe.ID
+	if code := a.do(t, "PUT", path, dave, recipeJSON("Better Soup", ""), nil); code != http.StatusOK {
+		t.Errorf("group editor update = %d, want 200", code)
+	}
+	if code := a.do(t, "DELETE", path, dave, "", nil); code != http.StatusForbidden {
+		t.Errorf("group editor delete = %d, want 403", code)
+	}
+	if code := a.do(t, "DELETE", path, alice, "", nil); code != http.StatusNoContent {
+		t.Errorf("owner delete = %d, want 204", code)
+	}
+}
+
+func TestDeleteClearsCookProgress(t *testing.T) {
+	a := newTestAPI(t)
+	alice := a.token(t, "alice", models.RoleMember, models.ScopeRecipesRead, models.ScopeRecipesWrite)
+	var recipe models.Recipe
+	if code := a.do(t, "POST", "/api/recipes", alice, recipeJSON("Soup", models.VisibilityPublic), &recipe); code != http.StatusCreated {
+		t.Fatalf("create = %d", code)
+	}
+	for _, user := range []string{recipe.OwnerID, "someone-else"} {
+		if err := a.store.SaveCookProgress(models.CookProgress{UserID: user, RecipeID: recipe.ID, Step: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if code := a.do(t, "DELETE", "/api/recipes/"+recipe.ID, alice, "", nil); code != http.StatusNoContent {
+		t.Fatalf("delete = %d, want 204", code)
+	}
+	for _, user := range []string{recipe.OwnerID, "someone-else"} {
+		if _, err := a.store.GetCookProgress(user, recipe.ID); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("%s's progress after delete: %v, want it gone", user, err)
+		}
+	}
+}
+
+// failingGets is a recipe store whose Get always fails with err
+type failingGets struct {
+	*memory.Store
+	err error
+}
+
+func (s failingGets) Get(ctx context.Context, id string) (models.Recipe, error) {
+	return models.Recipe{}, s.err
+}
+
+func TestGetErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"not found", fmt.Errorf("recipe x: %w", storage.ErrNotFound), http.StatusNotFound},
+		{"timed out", fmt.Errorf("reading recipe: %w", context.DeadlineExceeded), http.StatusServiceUnavailable},
+		{"store failure", errors.New("disk on fire"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPIWith(t, func(store *memory.Store) storage.ContextRecipeStore {
+				return failingGets{Store: store, err: tt.err}
+			})
+			alice := a.token(t, "alice", models.RoleMember, models.ScopeRecipesRead, models.ScopeRecipesWrite)
+			for _, req := range []struct{ method, path, body string }{
+				{"GET", "/api/recipes/recipe-1", ""},
+				{"PUT", "/api/recipes/recipe-1", recipeJSON("Soup", "")},
+				{"DELETE", "/api/recipes/recipe-1", ""},
+				{"GET", "/api/timeline?recipe=recipe-1&serve=2024-03-01T18:00:00Z", ""},
+			} {
+				if code := a.do(t, req.method, req.path, alice, req.body, nil); code != tt.wantStatus {
+					t.Errorf("%s %s = %d, want %d", req.method, req.path, code, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestTimelineRecipeLimit(t *testing.T) {
+	a := newTestAPI(t)
+	alice := a.token(t, "alice", models.RoleMember, models.ScopeRecipesRead, models.ScopeRecipesWrite)
+	var soup models.Recipe
+	if code := a.do(t, "POST", "/api/recipes", alice, recipeJSON("Soup", models.VisibilityPrivate), &soup); code != http.StatusCreated {
+		t.Fatalf("create = %d", code)
+	}
+	path := func(ids []string) string {
+		return "/api/timeline?" + url.Values{"recipe": ids, "serve": {"2024-03-01T18:00:00Z"}}.Encode()
+	}
+
+	// Asking for the same recipe again and again schedules it once
+	repeated := make([]string, timeline.MaxRecipes+5)
+	for i := range repeated {
+		repeated[i] = soup.ID
+	}
+	var plan timeline.Plan
+	if code := a.do(t, "GET", path(repeated), alice, "", &plan); code != http.StatusOK {
+		t.Fatalf("repeated recipe = %d, want 200", code)
+	}
+	if len(plan.Entries) != len(soup.Instructions) {
+		t.Errorf("plan has %d steps, want the recipe's %d once", len(plan.Entries), len(soup.Instructions))
+	}
+
+	// Too many different recipes is refused before any are looked up
+	distinct := make([]string, timeline.MaxRecipes+1)
+	for i := range distinct {
+		distinct[i] = fmt.Sprintf("recipe-%d", i)
+	}
+	if code := a.do(t, "GET", path(distinct), alice, "", nil); code != http.StatusBadRequest {
+		t.Errorf("%d recipes = %d, want 400", len(distinct), code)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	a := newTestAPI(t)
+	admin := a.token(t, "admin", models.RoleAdmin, models.ScopeAdmin)
+
+	var users []map[string]interface{}
+	if code := a.do(t, "GET", "/api/users", admin, "", &users); code != http.StatusOK || len(users) != 1 {
+		t.Fatalf("users = %d %v", code, users)
+	}
+	if _, ok := users[0]["password_hash"]; ok {
+		t.Error("user list includes the password hash")
+	}
+}
+
+func TestRecipeValidation(t *testing.T) {
+	a := newTestAPI(t)
+	alice := a.token(t, "alice", models.RoleMember, models.ScopeRecipesRead, models.ScopeRecipesWrite)
+	var existing models.Recipe
+	if code := a.do(t, "POST", "/api/recipes", alice, recipeJSON("Soup", models.VisibilityPrivate), &existing); code != http.StatusCreated {
+		t.Fatalf("create = %d", code)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantField string
+	}{
+		{"blank title", `{"title": " ", "servings": 2, "ingredients": [{"name": "Water"}]}`, "title"},
+		{"negative prep time", `{"title": "Soup", "prep_time": -1, "servings": 2, "ingredients": [{"name": "Water"}]}`, "prep_time"},
+		{"no servings", `{"title": "Soup", "ingredients": [{"name": "Water"}]}`, "servings"},
+		{"huge servings", `{"title": "Soup", "servings": 100000, "ingredients": [{"name": "Water"}]}`, "servings"},
+		{"no ingredients", `{"title": "Soup", "servings": 2}`, "ingredients"},
+		{"negative amount", `{"title": "Soup", "servings": 2, "ingredients": [{"name": "Water", "amount": -1}]}`, "ingredients"},
+		{"blank step", `{"title": "Soup", "servings": 2, "ingredients": [{"name": "Water"}], "instructions": [{"step": ""}]}`, "instructions"},
+	}
+	for _, tt := range tests {
+		for _, target := range []struct{ method, path string }{{"POST", "/api/recipes"}, {"PUT", "/api/recipes/" + existing.ID}} {
+			t.Run(tt.name+" "+target.method, func(t *testing.T) {
+				req := httptest.NewRequest(target.method, target.path, strings.NewReader(tt.body))
+				req.Header.Set("Authorization", "Bearer "+alice)
+				rec := httptest.NewRecorder()
+				a.router.ServeHTTP(rec, req)
+
+				if rec.Code != http.StatusBadRequest {
+					t.Fatalf("status = %d, want 400", rec.Code)
+				}
+				var body struct {
+					Error  string            `json:"error"`
+					Fields map[string]string `json:"fields"`
+				}
+				if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+					t.Fatal(err)
+				}
+				if body.Error == "" || body.Fields[tt.wantField] == "" {
+					t.Errorf("body = %+v, want an error for %s", body, tt.wantField)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// internal/handlers/auditlog/handler.go
+
+// Package auditlog serves the admin pages for browsing, exporting and undoing recipe changes
+package auditlog
+
+import (
+	"encoding/json"
+	"errors"
+	"go_recipe_app/internal/audit"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/logging"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// pageSize is how many entries the audit page shows at a time
+const pageSize = 50
+
+// dateFormat is what <input type="date"> sends
+const dateFormat = "2006-01-02"
+
+// Handler serves the audit log pages
+type Handler struct {
+	tmpl    *handlers.Renderer
+	logger  *slog.Logger
+	entries storage.AuditStore
+	recipes *audit.Store
+	users   storage.UserStore
+}
+
+// filterForm is the filter form as submitted, so it can be shown again as typed
+type filterForm struct {
+	Recipe string
+	User   string
+	Action string
+	From   string
+	To     string
+}
+
+// auditPage is the template data for the audit log page
+type auditPage struct {
+	Entries []models.AuditEntry
+	Filter  filterForm
+	Users   []models.User
+	Actions []models.AuditAction
+	Older   string // link to the next page, empty on the last one
+	Export  string // link for exporting what's shown
+	Message string
+	Error   string
+}
+
+// New creates a new audit log Handler
+// recipes is the audited recipe store, which restores go through so they're audited too
+func New(tmpl *handlers.Renderer, entries storage.AuditStore, recipes *audit.Store, users storage.UserStore, logger *slog.Logger) *Handler {
+	return &Handler{
+		tmpl:    tmpl,
+		logger:  logger,
+		entries: entries,
+		recipes: recipes,
+		users:   users,
+	}
+}
+
+// log returns the logger for a request, tagged with its request ID
+func (h *Handler) log(r *http.Request) *slog.Logger {
+	return logging.FromContextOr(r.Context(), h.logger)
+}
+
+// RegisterRoutes adds the audit log routes to a router
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/admin/audit", auth.RequireAdmin(h.listEntries)).Methods("GET")
+	r.HandleFunc("/admin/audit/export", auth.RequireAdmin(h.exportEntries)).Methods("GET")
+	r.HandleFunc("/admin/audit/{id}/restore", auth.RequireAdmin(h.restoreRecipe)).Methods("POST")
+}
+
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := h.tmpl.Render(w, handlers.NewTemplateData(r, name, data)); err != nil {
+		h.log(r).Error("Error executing template", slog.Any("error", err))
+	}
+}
+
+// Show the audit log, newest first, a page at a time
+func (h *Handler) listEntries(w http.ResponseWriter, r *http.Request) {
+	h.renderEntries(w, r, http.StatusOK, auditPage{})
+}
+
+// Download every entry matching the filters as JSON Lines, one entry per line
+func (h *Handler) exportEntries(w http.ResponseWriter, r *http.Request) {
+	form, filter, err := h.parseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Before, filter.Limit = "", 0
+
+	entries, err := h.entries.ListAudit(filter)
+	if err != nil {
+		h.log(r).Error("Error listing audit entries", slog.Any("error", err))
+		http.Error(w, "Error getting audit log", http.StatusInternalServerError)
+		return
+	}
+
+	// Oldest first reads more naturally in a file, and matches the order the entries were written
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition",
+		`attachment; filename="recipe-audit-`+time.Now().Format(dateFormat)+`.jsonl"`)
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			// The client went away mid-download; nothing more to do
+			h.log(r).Info("Audit export stopped", slog.Any("error", err))
+			return
+		}
+	}
+	h.log(r).Info("Audit log exported", slog.Int("entries", len(entries)),
+		slog.String("recipe", form.Recipe), slog.String("user", form.User), slog.String("action", form.Action))
+}
+
+// Bring back a deleted recipe from the copy kept in its delete entry
+func (h *Handler) restoreRecipe(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	recipe, err := h.recipes.Restore(r.Context(), id)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, "Audit entry not found", http.StatusNotFound)
+		return
+	case errors.Is(err, audit.ErrNotRestorable), errors.Is(err, audit.ErrRecipeExists):
+		h.renderEntries(w, r, http.StatusConflict, auditPage{Error: err.Error()})
+		return
+	case err != nil:
+		h.log(r).Error("Error restoring recipe", slog.String("entry_id", id), slog.Any("error", err))
+		http.Error(w, "Error restoring recipe", http.StatusInternalServerError)
+		return
+	}
+
+	h.log(r).Info("Recipe restored", slog.String("recipe_id", recipe.ID), slog.String("entry_id", id))
+	http.Redirect(w, r, "/recipes/"+recipe.ID, http.StatusSeeOther)
+}
+
+func (h *Handler) renderEntries(w http.ResponseWriter, r *http.Request, status int, page auditPage) {
+	form, filter, err := h.parseFilter(r.URL.Query())
+	if err != nil {
+		page.Error = err.Error()
+		status = http.StatusBadRequest
+	}
+	page.Filter = form
+	page.Actions = models.AuditActions
+
+	users, err := h.users.ListUsers()
+	if err != nil {
+		h.log(r).Error("Error listing users", slog.Any("error", err))
+		http.Error(w, "Error getting users", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return strings.ToLower(users[i].Username) < strings.ToLower(users[j].Username)
+	})
+	page.Users = users
+
+	// Ask for one extra entry to find out whether there's an older page
+	filter.Limit = pageSize + 1
+	entries, err := h.entries.ListAudit(filter)
+	if err != nil {
+		h.log(r).Error("Error listing audit entries", slog.Any("error", err))
+		http.Error(w, "Error getting audit log", http.StatusInternalServerError)
+		return
+	}
+	// Whole URLs rather than query strings: the template would escape a query
+	// string's = and & after the ?
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		older := form.query()
+		older.Set("before", entries[len(entries)-1].ID)
+		page.Older = "/admin/audit?" + older.Encode()
+	}
+	page.Entries = entries
+	page.Export = "/admin/audit/export?" + form.query().Encode()
+
+	h.render(w, r, status, "audit", page)
+}
+
+// parseFilter reads the filters from the query string
+// Dates are whole days in the server's time zone; "to" includes the day it names
+func (h *Handler) parseFilter(q url.Values) (filterForm, storage.AuditFilter, error) {
+	form := filterForm{
+		Recipe: strings.TrimSpace(q.Get("recipe")),
+		User:   q.Get("user"),
+		Action: q.Get("action"),
+		From:   q.Get("from"),
+		To:     q.Get("to"),
+	}
+	filter := storage.AuditFilter{
+		RecipeID: form.Recipe,
+		UserID:   form.User,
+		Before:   q.Get("before"),
+	}
+
+	if form.Action != "" {
+		valid := false
+		for _, a := range models.AuditActions {
+			valid = valid || string(a) == form.Action
+		}
+		if !valid {
+			return form, filter, errors.New("Unknown action " + form.Action)
+		}
+		filter.Action = models.AuditAction(form.Action)
+	}
+	if form.From != "" {
+		from, err := time.ParseInLocation(dateFormat, form.From, time.Local)
+		if err != nil {
+			return form, filter, errors.New("From must be a date like 2024-01-31")
+		}
+		filter.Since = from
+	}
+	if form.To != "" {
+		to, err := time.ParseInLocation(dateFormat, form.To, time.Local)
+		if err != nil {
+			return form, filter, errors.New("To must be a date like 2024-01-31")
+		}
+		filter.Until = to.AddDate(0, 0, 1)
+	}
+	return form, filter, nil
+}
+
+// query turns the filters back into a query string, leaving out empty ones
+func (f filterForm) query() url.Values {
+	q := url.Values{}
+	for key, value := range map[string]string{
+		"recipe": f.Recipe, "user": f.User, "action": f.Action, "from": f.From, "to": f.To,
+	} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	return q
+}
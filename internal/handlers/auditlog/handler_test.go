@@ -0,0 +1,299 @@
+package auditlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"go_recipe_app/internal/audit"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"go_recipe_app/internal/storage/memory"
+	"go_recipe_app/web"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// testApp is the audit log pages behind the session middleware, backed by memory
+type testApp struct {
+	handler http.Handler
+	auth    *auth.Manager
+	store   *memory.Store
+	audited *audit.Store
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	files := web.Files("")
+	assets, err := web.NewAssets(files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := handlers.NewRenderer(web.Templates(files), assets.FuncMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.New()
+	manager := auth.NewManager(store, store, store, store, auth.Options{}, logger)
+	audited := audit.NewStore(store, store, logger)
+	router := mux.NewRouter()
+	New(tmpl, store, audited, store, logger).RegisterRoutes(router)
+	return &testApp{handler: manager.Middleware(router), auth: manager, store: store, audited: audited}
+}
+
+// user creates an account and returns a logged in session cookie for it
+func (a *testApp) user(t *testing.T, username string, role models.Role) *http.Cookie {
+	t.Helper()
+	user, err := a.auth.CreateUser(username, "correct horse", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	if err := a.auth.StartSession(rec, user); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Result().Cookies()[0]
+}
+
+// do sends a request as the holder of cookie, or as a visitor when it's nil
+func (a *testApp) do(method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// entry appends an audit entry straight to the log
+func (a *testApp) entry(t *testing.T, entry models.AuditEntry) models.AuditEntry {
+	t.Helper()
+	entry, err := a.store.AppendAudit(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+// export downloads the entries matching query and returns their IDs in file order
+func (a *testApp) export(t *testing.T, query string, cookie *http.Cookie) []string {
+	t.Helper()
+	rec := a.do("GET", "/admin/audit/export?"+query, cookie)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export %q = %d, want 200", query, rec.Code)
+	}
+	ids := []string{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("export line %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestParseFilter(t *testing.T) {
+	var h Handler
+	march1 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name         string
+		query        string
+		since, until time.Time
+		wantErr      bool
+	}{
+		{"no dates", "", time.Time{}, time.Time{}, false},
+		{"from is the start of its day", "from=2024-03-01", march1, time.Time{}, false},
+		{"to includes its own day", "to=2024-03-01", time.Time{}, march1.AddDate(0, 0, 1), false},
+		{"one day", "from=2024-03-01&to=2024-03-01", march1, march1.AddDate(0, 0, 1), false},
+		{"bad from", "from=01/03/2024", time.Time{}, time.Time{}, true},
+		{"bad to", "to=yesterday", time.Time{}, time.Time{}, true},
+		{"unknown action", "action=explode", time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			_, filter, err := h.parseFilter(q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (!filter.Since.Equal(tt.since) || !filter.Until.Equal(tt.until)) {
+				t.Errorf("since, until = %v, %v; want %v, %v", filter.Since, filter.Until, tt.since, tt.until)
+			}
+		})
+	}
+
+	// Everything else passes straight through
+	q := url.Values{"recipe": {" recipe-1 "}, "user": {"user-1"}, "action": {"delete"}, "before": {"000000000009"}}
+	form, filter, err := h.parseFilter(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.RecipeID != "recipe-1" || filter.UserID != "user-1" || filter.Action != models.AuditDelete || filter.Before != "000000000009" {
+		t.Errorf("filter = %+v", filter)
+	}
+	if form.Recipe != "recipe-1" {
+		t.Errorf("form recipe = %q, want it trimmed", form.Recipe)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.user(t, "admin", models.RoleAdmin)
+
+	day := func(d, h, m int) time.Time { return time.Date(2024, 3, d, h, m, 0, 0, time.Local) }
+	before := app.entry(t, models.AuditEntry{Time: day(1, 0, 0).Add(-time.Minute), Action: models.AuditCreate, RecipeID: "recipe-1", UserID: "alice"})
+	start := app.entry(t, models.AuditEntry{Time: day(1, 0, 0), Action: models.AuditUpdate, RecipeID: "recipe-1", UserID: "alice"})
+	end := app.entry(t, models.AuditEntry{Time: day(1, 23, 59), Action: models.AuditUpdate, RecipeID: "recipe-2", UserID: "bob"})
+	after := app.entry(t, models.AuditEntry{Time: day(2, 0, 0), Action: models.AuditDelete, RecipeID: "recipe-2", UserID: "bob"})
+
+	tests := []struct {
+		query string
+		want  []*models.AuditEntry
+	}{
+		{"", []*models.AuditEntry{&before, &start, &end, &after}},
+		{"from=2024-03-01&to=2024-03-01", []*models.AuditEntry{&start, &end}},
+		{"from=2024-03-02", []*models.AuditEntry{&after}},
+		{"to=2024-02-29", []*models.AuditEntry{&before}},
+		{"recipe=recipe-2", []*models.AuditEntry{&end, &after}},
+		{"user=alice", []*models.AuditEntry{&before, &start}},
+		{"action=update", []*models.AuditEntry{&start, &end}},
+		{"user=bob&action=delete", []*models.AuditEntry{&after}},
+		{"user=carol", nil},
+		// Export ignores paging and always gives everything that matches
+		{"before=" + after.ID, []*models.AuditEntry{&before, &start, &end, &after}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			want := []string{}
+			for _, e := range tt.want {
+				want = append(want, e.ID)
+			}
+			if got := app.export(t, tt.query, admin); !reflect.DeepEqual(got, want) {
+				t.Errorf("exported %v, want %v oldest first", got, want)
+			}
+		})
+	}
+
+	rec := app.do("GET", "/admin/audit/export", admin)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, ".jsonl") {
+		t.Errorf("Content-Disposition = %q, want a .jsonl attachment", cd)
+	}
+	for _, path := range []string{"/admin/audit?from=March", "/admin/audit/export?action=explode"} {
+		if rec := app.do("GET", path, admin); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s = %d, want 400", path, rec.Code)
+		}
+	}
+}
+
+func TestPaging(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.user(t, "admin", models.RoleAdmin)
+	var entries []models.AuditEntry
+	for i := 0; i < pageSize+10; i++ {
+		entries = append(entries, app.entry(t, models.AuditEntry{Time: time.Now(), Action: models.AuditCreate, RecipeID: "recipe-1", RecipeTitle: "Soup"}))
+	}
+	rows := func(body string) int { return strings.Count(body, `class="tag audit-`) }
+
+	// The first page is the newest pageSize entries, with a link to the rest
+	rec := app.do("GET", "/admin/audit?recipe=recipe-1", admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	oldestShown := entries[len(entries)-pageSize].ID
+	body := rec.Body.String()
+	if got := rows(body); got != pageSize {
+		t.Errorf("first page has %d entries, want %d", got, pageSize)
+	}
+	if older := `href="/admin/audit?before=` + oldestShown + `&amp;recipe=recipe-1"`; !strings.Contains(body, older) {
+		t.Errorf("first page doesn't link to the entries before %s with the same filter", oldestShown)
+	}
+	if export := `href="/admin/audit/export?recipe=recipe-1"`; !strings.Contains(body, export) {
+		t.Error("first page doesn't link to an export with the same filter")
+	}
+
+	// The next page picks up after it and is the last
+	rec = app.do("GET", "/admin/audit?recipe=recipe-1&before="+oldestShown, admin)
+	body = rec.Body.String()
+	if got := rows(body); got != 10 {
+		t.Errorf("second page has %d entries, want 10", got)
+	}
+	if strings.Contains(body, "Older entries") {
+		t.Error("last page links to older entries")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	app := newTestApp(t)
+	admin := app.user(t, "admin", models.RoleAdmin)
+	member := app.user(t, "member", models.RoleMember)
+
+	ctx := context.Background()
+	recipe := models.Recipe{ID: "recipe-1", Title: "Soup", Servings: 2, Visibility: models.VisibilityPublic}
+	if err := app.audited.Create(ctx, recipe); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.audited.Delete(ctx, recipe.ID); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := app.store.ListAudit(storage.AuditFilter{Action: models.AuditDelete})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("delete entries = %v, %v", entries, err)
+	}
+	deleted := entries[0]
+	created, err := app.store.ListAudit(storage.AuditFilter{Action: models.AuditCreate})
+	if err != nil || len(created) != 1 {
+		t.Fatalf("create entries = %v, %v", created, err)
+	}
+
+	restore := func(id string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		return app.do("POST", "/admin/audit/"+id+"/restore", cookie)
+	}
+	if rec := restore(deleted.ID, member); rec.Code != http.StatusForbidden {
+		t.Errorf("member restore = %d, want 403", rec.Code)
+	}
+	if rec := restore("000000000999", admin); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown entry = %d, want 404", rec.Code)
+	}
+	if rec := restore(created[0].ID, admin); rec.Code != http.StatusConflict {
+		t.Errorf("restoring a create entry = %d, want 409", rec.Code)
+	}
+
+	rec := restore(deleted.ID, admin)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/recipes/"+recipe.ID {
+		t.Fatalf("restore = %d to %q, want a redirect to the recipe", rec.Code, rec.Header().Get("Location"))
+	}
+	if got, err := app.store.Get(ctx, recipe.ID); err != nil || got.Title != recipe.Title {
+		t.Errorf("restored recipe = %+v, %v", got, err)
+	}
+	if restored, _ := app.store.ListAudit(storage.AuditFilter{Action: models.AuditRestore}); len(restored) != 1 {
+		t.Errorf("restore entries = %v, want the restore audited", restored)
+	}
+
+	// The recipe is back, so the same entry can't restore it again
+	rec = restore(deleted.ID, admin)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("restoring over an existing recipe = %d, want 409", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), audit.ErrRecipeExists.Error()) {
+		t.Error("409 page doesn't say the recipe exists")
+	}
+}
@@ -0,0 +1,100 @@
+// Helper functions the page templates can call
+
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Funcs are the helpers every template can use:
+//
+//	{{duration .CookTime}}                     "1 hr 30 min"
+//	{{fraction .Amount}}                       "1½"
+//	{{plural .Servings "serving" "servings"}}  "4 servings"
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"duration": formatDuration,
+		"fraction": formatFraction,
+		"plural":   plural,
+	}
+}
+
+// formatDuration writes a cooking time the way a recipe would, to the nearest minute
+// Anything under a minute is shown in seconds, so a short timer doesn't read as "0 min"
+func formatDuration(d time.Duration) string {
+	if d > 0 && d < time.Minute {
+		return fmt.Sprintf("%d sec", int(d.Round(time.Second)/time.Second))
+	}
+
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	hours, minutes := minutes/60, minutes%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%d min", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%d hr", hours)
+	default:
+		return fmt.Sprintf("%d hr %d min", hours, minutes)
+	}
+}
+
+// fractions are the ones measuring cups and spoons come in
+var fractions = []struct {
+	value float64
+	glyph string
+}{
+	{1.0 / 8, "⅛"}, {1.0 / 4, "¼"}, {1.0 / 3, "⅓"}, {3.0 / 8, "⅜"}, {1.0 / 2, "½"},
+	{5.0 / 8, "⅝"}, {2.0 / 3, "⅔"}, {3.0 / 4, "¾"}, {7.0 / 8, "⅞"},
+}
+
+// fractionTolerance is how close an amount must be to a fraction to be shown as one
+// 0.33 and 0.67 are what people type for a third and two thirds
+const fractionTolerance = 0.01
+
+// formatFraction writes an amount like 1.5 as "1½"
+// Amounts that aren't near a common fraction keep up to two decimal places
+func formatFraction(amount float64) string {
+	whole, part := math.Modf(amount)
+	if math.Abs(part) < fractionTolerance {
+		return strconv.FormatFloat(whole, 'f', -1, 64)
+	}
+	if 1-math.Abs(part) < fractionTolerance {
+		return strconv.FormatFloat(whole+math.Copysign(1, amount), 'f', -1, 64)
+	}
+
+	if amount > 0 {
+		for _, f := range fractions {
+			if math.Abs(part-f.value) < fractionTolerance {
+				if whole == 0 {
+					return f.glyph
+				}
+				return strconv.FormatFloat(whole, 'f', -1, 64) + f.glyph
+			}
+		}
+	}
+	return strconv.FormatFloat(math.Round(amount*100)/100, 'f', -1, 64)
+}
+
+// plural writes a count with the right form of the word after it, like "1 serving" or "2 servings"
+// It takes any integer type, since the templates pass fields like Servings (int32) and len (int)
+func plural(count any, singular, pluralForm string) (string, error) {
+	var n int64
+	switch c := count.(type) {
+	case int:
+		n = int64(c)
+	case int32:
+		n = int64(c)
+	case int64:
+		n = c
+	default:
+		return "", fmt.Errorf("plural needs a whole number, got %T", count)
+	}
+	if n == 1 {
+		return "1 " + singular, nil
+	}
+	return strconv.FormatInt(n, 10) + " " + pluralForm, nil
+}
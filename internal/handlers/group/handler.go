@@ -0,0 +1,335 @@
+// internal/handlers/group/handler.go
+
+// Package group serves the pages for managing groups (households) and their members
+package group
+
+import (
+	"errors"
+	"fmt"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/logging"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// maxNameLength keeps group names short enough for the recipe form's picker
+const maxNameLength = 60
+
+// Handler serves the group pages
+type Handler struct {
+	tmpl   *handlers.Renderer
+	logger *slog.Logger
+	groups storage.GroupStore
+	users  storage.UserStore
+}
+
+// groupsPage is the template data for the group list
+type groupsPage struct {
+	Groups []models.Group
+	Error  string
+}
+
+// memberRow is one member as the group page shows it
+type memberRow struct {
+	UserID   string
+	Username string
+	Role     models.GroupRole
+	Owner    bool
+}
+
+// groupPage is the template data for a single group
+type groupPage struct {
+	Group     models.Group
+	Members   []memberRow
+	UserID    string // the current user, who may always leave
+	CanManage bool   // owner or admin - may add and remove members
+	Roles     []models.GroupRole
+	Message   string
+	Error     string
+}
+
+// New creates a new group Handler
+func New(tmpl *handlers.Renderer, groups storage.GroupStore, users storage.UserStore, logger *slog.Logger) *Handler {
+	return &Handler{
+		tmpl:   tmpl,
+		logger: logger,
+		groups: groups,
+		users:  users,
+	}
+}
+
+// log returns the logger for a request, tagged with its request ID
+func (h *Handler) log(r *http.Request) *slog.Logger {
+	return logging.FromContextOr(r.Context(), h.logger)
+}
+
+// RegisterRoutes adds the group routes to a router
+func (h *Handler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/groups", auth.RequireLogin(h.listGroups)).Methods("GET")
+	r.HandleFunc("/groups", auth.RequireLogin(h.createGroup)).Methods("POST")
+	r.HandleFunc("/groups/{id}", auth.RequireLogin(h.showGroup)).Methods("GET")
+	r.HandleFunc("/groups/{id}/members", auth.RequireLogin(h.addMember)).Methods("POST")
+	r.HandleFunc("/groups/{id}/members/{user}/remove", auth.RequireLogin(h.removeMember)).Methods("POST")
+	r.HandleFunc("/groups/{id}/delete", auth.RequireLogin(h.deleteGroup)).Methods("POST")
+}
+
+// render executes the layout with the given page, logging any failure
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, status int, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := h.tmpl.Render(w, handlers.NewTemplateData(r, name, data)); err != nil {
+		h.log(r).Error("Error executing template", slog.Any("error", err))
+	}
+}
+
+// Show the groups the user belongs to (every group for admins)
+func (h *Handler) listGroups(w http.ResponseWriter, r *http.Request) {
+	h.renderGroups(w, r, http.StatusOK, groupsPage{})
+}
+
+func (h *Handler) renderGroups(w http.ResponseWriter, r *http.Request, status int, page groupsPage) {
+	user := auth.CurrentUser(r)
+
+	var groups []models.Group
+	var err error
+	if user.IsAdmin() {
+		groups, err = h.groups.ListGroups()
+	} else {
+		groups, err = h.groups.ListGroupsForUser(user.ID)
+	}
+	if err != nil {
+		h.log(r).Error("Error listing groups", slog.Any("error", err))
+		http.Error(w, "Error getting groups", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.ToLower(groups[i].Name) < strings.ToLower(groups[j].Name)
+	})
+	page.Groups = groups
+	h.render(w, r, status, "groups", page)
+}
+
+// Create a group owned by the current user
+func (h *Handler) createGroup(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.log(r).Error("Error parsing form", slog.Any("error", err))
+		http.Error(w, "Error processing form", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		h.renderGroups(w, r, http.StatusBadRequest, groupsPage{
+			Error: fmt.Sprintf("Group name must be 1-%d characters", maxNameLength),
+		})
+		return
+	}
+
+	id, err := auth.NewID("group")
+	if err != nil {
+		h.log(r).Error("Error generating group id", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	group := models.Group{
+		ID:        id,
+		Name:      name,
+		OwnerID:   auth.CurrentUser(r).ID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := h.groups.CreateGroup(group); err != nil {
+		h.log(r).Error("Error creating group", slog.Any("error", err))
+		http.Error(w, "Error saving group", http.StatusInternalServerError)
+		return
+	}
+
+	h.log(r).Info("Group created", slog.String("group_id", group.ID))
+	http.Redirect(w, r, "/groups/"+group.ID, http.StatusSeeOther)
+}
+
+// loadGroup finds the group in the URL, writing a 404 if the user isn't allowed to see it
+func (h *Handler) loadGroup(w http.ResponseWriter, r *http.Request) (models.Group, bool) {
+	group, err := h.groups.GetGroup(mux.Vars(r)["id"])
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			h.log(r).Error("Error getting group", slog.Any("error", err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return models.Group{}, false
+		}
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return models.Group{}, false
+	}
+
+	user := auth.CurrentUser(r)
+	if _, member := group.RoleOf(user.ID); !member && !user.IsAdmin() {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return models.Group{}, false
+	}
+	return group, true
+}
+
+// canManage reports whether the user may change the group's membership
+func canManage(user *models.User, group models.Group) bool {
+	return user.IsAdmin() || group.OwnerID == user.ID
+}
+
+// Show a group and its members
+func (h *Handler) showGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	h.renderGroup(w, r, http.StatusOK, group, groupPage{})
+}
+
+func (h *Handler) renderGroup(w http.ResponseWriter, r *http.Request, status int, group models.Group, page groupPage) {
+	page.Group = group
+	page.UserID = auth.CurrentUser(r).ID
+	page.CanManage = canManage(auth.CurrentUser(r), group)
+	page.Roles = []models.GroupRole{models.GroupViewer, models.GroupEditor}
+
+	// The owner is listed first, then members in the order they joined
+	ids := []string{group.OwnerID}
+	for _, m := range group.Members {
+		ids = append(ids, m.UserID)
+	}
+	for _, id := range ids {
+		row := memberRow{UserID: id, Username: id, Owner: id == group.OwnerID}
+		row.Role, _ = group.RoleOf(id)
+		if user, err := h.users.GetUser(id); err == nil {
+			row.Username = user.Username
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			h.log(r).Error("Error getting group member", slog.Any("error", err))
+		}
+		page.Members = append(page.Members, row)
+	}
+
+	h.render(w, r, status, "group", page)
+}
+
+// Add a member by username, or change an existing member's role
+func (h *Handler) addMember(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	if !canManage(auth.CurrentUser(r), group) {
+		http.Error(w, "Only the group owner can change members", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.log(r).Error("Error parsing form", slog.Any("error", err))
+		http.Error(w, "Error processing form", http.StatusBadRequest)
+		return
+	}
+
+	role := models.GroupRole(r.FormValue("role"))
+	if role != models.GroupViewer && role != models.GroupEditor {
+		h.renderGroup(w, r, http.StatusBadRequest, group, groupPage{Error: "Choose viewer or editor"})
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	member, err := h.users.GetUserByUsername(username)
+	if errors.Is(err, storage.ErrNotFound) {
+		h.renderGroup(w, r, http.StatusBadRequest, group, groupPage{Error: "No user called " + username})
+		return
+	}
+	if err != nil {
+		h.log(r).Error("Error looking up user", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if member.ID == group.OwnerID {
+		h.renderGroup(w, r, http.StatusBadRequest, group, groupPage{Error: "The owner is always an editor"})
+		return
+	}
+
+	found := false
+	for i := range group.Members {
+		if group.Members[i].UserID == member.ID {
+			group.Members[i].Role = role
+			found = true
+		}
+	}
+	if !found {
+		group.Members = append(group.Members, models.GroupMember{UserID: member.ID, Role: role})
+	}
+
+	if err := h.groups.UpdateGroup(group); err != nil {
+		h.log(r).Error("Error updating group", slog.Any("error", err))
+		http.Error(w, "Error saving group", http.StatusInternalServerError)
+		return
+	}
+	h.renderGroup(w, r, http.StatusOK, group, groupPage{Message: fmt.Sprintf("%s is now a %s", member.Username, role)})
+}
+
+// Remove a member; members may also remove themselves to leave the group
+func (h *Handler) removeMember(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.loadGroup(w, r)
+	if !ok {
+		return
+	}
+
+	user := auth.CurrentUser(r)
+	memberID := mux.Vars(r)["user"]
+	if !canManage(user, group) && memberID != user.ID {
+		http.Error(w, "Only the group owner can change members", http.StatusForbidden)
+		return
+	}
+	if memberID == group.OwnerID {
+		h.renderGroup(w, r, http.StatusBadRequest, group, groupPage{Error: "The owner can't leave the group - delete it instead"})
+		return
+	}
+
+	members := group.Members[:0]
+	for _, m := range group.Members {
+		if m.UserID != memberID {
+			members = append(members, m)
+		}
+	}
+	group.Members = members
+
+	if err := h.groups.UpdateGroup(group); err != nil {
+		h.log(r).Error("Error updating group", slog.Any("error", err))
+		http.Error(w, "Error saving group", http.StatusInternalServerError)
+		return
+	}
+
+	if memberID == user.ID {
+		http.Redirect(w, r, "/groups", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/groups/"+group.ID, http.StatusSeeOther)
+}
+
+// Delete a group; recipes shared with it become visible to their owners only
+func (h *Handler) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.loadGroup(w, r)
+	if !ok {
+		return
+	}
+	if !canManage(auth.CurrentUser(r), group) {
+		http.Error(w, "Only the group owner can delete it", http.StatusForbidden)
+		return
+	}
+
+	if err := h.groups.DeleteGroup(group.ID); err != nil {
+		h.log(r).Error("Error deleting group", slog.Any("error", err))
+		http.Error(w, "Error deleting group", http.StatusInternalServerError)
+		return
+	}
+
+	h.log(r).Info("Group deleted", slog.String("group_id", group.ID))
+	http.Redirect(w, r, "/groups", http.StatusSeeOther)
+}
@@ -0,0 +1,295 @@
+package group
+
+import (
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage/memory"
+	"go_recipe_app/web"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// testApp is the group pages behind the session middleware, backed by memory
+type testApp struct {
+	handler http.Handler
+	auth    *auth.Manager
+	store   *memory.Store
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	files := web.Files("")
+	assets, err := web.NewAssets(files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := handlers.NewRenderer(web.Templates(files), assets.FuncMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.New()
+	manager := auth.NewManager(store, store, store, store, auth.Options{}, logger)
+	router := mux.NewRouter()
+	New(tmpl, store, store, logger).RegisterRoutes(router)
+	return &testApp{handler: manager.Middleware(router), auth: manager, store: store}
+}
+
+// user creates an account and returns it with a logged in session cookie
+func (a *testApp) user(t *testing.T, username string, role models.Role) (models.User, *http.Cookie) {
+	t.Helper()
+	user, err := a.auth.CreateUser(username, "correct horse", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	if err := a.auth.StartSession(rec, user); err != nil {
+		t.Fatal(err)
+	}
+	return user, rec.Result().Cookies()[0]
+}
+
+// post sends a form as the holder of cookie
+func (a *testApp) post(path string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// group reads a group back from the store
+func (a *testApp) group(t *testing.T, id string) models.Group {
+	t.Helper()
+	group, err := a.store.GetGroup(id)
+	if err != nil {
+		t.Fatalf("group %s: %v", id, err)
+	}
+	return group
+}
+
+// family sets up a group owned by alice, with carol as a viewer and dave as an editor
+type family struct {
+	app                            *testApp
+	group                          models.Group
+	alice, bob, carol, dave, admin models.User
+	cookies                        map[string]*http.Cookie
+}
+
+func newFamily(t *testing.T) *family {
+	t.Helper()
+	f := &family{app: newTestApp(t), cookies: map[string]*http.Cookie{}}
+	for _, u := range []struct {
+		user *models.User
+		name string
+		role models.Role
+	}{
+		{&f.alice, "alice", models.RoleMember},
+		{&f.bob, "bob", models.RoleMember},
+		{&f.carol, "carol", models.RoleMember},
+		{&f.dave, "dave", models.RoleMember},
+		{&f.admin, "admin", models.RoleAdmin},
+	} {
+		*u.user, f.cookies[u.name] = f.app.user(t, u.name, u.role)
+	}
+
+	f.group = models.Group{ID: "group-family", Name: "Family", OwnerID: f.alice.ID, Members: []models.GroupMember{
+		{UserID: f.carol.ID, Role: models.GroupViewer},
+		{UserID: f.dave.ID, Role: models.GroupEditor},
+	}}
+	if err := f.app.store.CreateGroup(f.group); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestCreateGroup(t *testing.T) {
+	app := newTestApp(t)
+	alice, cookie := app.user(t, "alice", models.RoleMember)
+
+	rec := app.post("/groups", url.Values{"name": {"  Family  "}}, cookie)
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("create = %d, want 303", rec.Code)
+	}
+	id := strings.TrimPrefix(rec.Header().Get("Location"), "/groups/")
+	if group := app.group(t, id); group.Name != "Family" || group.OwnerID != alice.ID || len(group.Members) != 0 {
+		t.Errorf("group = %+v, want Family owned by alice with no other members", group)
+	}
+
+	for _, name := range []string{"", "   ", strings.Repeat("x", maxNameLength+1)} {
+		if rec := app.post("/groups", url.Values{"name": {name}}, cookie); rec.Code != http.StatusBadRequest {
+			t.Errorf("name %q = %d, want 400", name, rec.Code)
+		}
+	}
+}
+
+func TestGroupAccess(t *testing.T) {
+	f := newFamily(t)
+	base := "/groups/" + f.group.ID
+	add := url.Values{"username": {"bob"}, "role": {"viewer"}}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		form       url.Values
+		user       string
+		wantStatus int
+	}{
+		// Only members and admins can see a group; to anyone else it doesn't exist
+		{"owner views", "GET", base, nil, "alice", http.StatusOK},
+		{"viewer views", "GET", base, nil, "carol", http.StatusOK},
+		{"admin views", "GET", base, nil, "admin", http.StatusOK},
+		{"non-member views", "GET", base, nil, "bob", http.StatusNotFound},
+		{"non-member adds", "POST", base + "/members", add, "bob", http.StatusNotFound},
+		{"non-member removes", "POST", base + "/members/" + f.carol.ID + "/remove", nil, "bob", http.StatusNotFound},
+		{"non-member deletes", "POST", base + "/delete", nil, "bob", http.StatusNotFound},
+		{"missing group", "GET", "/groups/group-missing", nil, "alice", http.StatusNotFound},
+
+		// Members who don't own the group can't change it, editors included
+		{"viewer adds", "POST", base + "/members", add, "carol", http.StatusForbidden},
+		{"editor adds", "POST", base + "/members", add, "dave", http.StatusForbidden},
+		{"editor removes someone else", "POST", base + "/members/" + f.carol.ID + "/remove", nil, "dave", http.StatusForbidden},
+		{"viewer deletes", "POST", base + "/delete", nil, "carol", http.StatusForbidden},
+		{"editor deletes", "POST", base + "/delete", nil, "dave", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec *httptest.ResponseRecorder
+			if tt.method == "GET" {
+				req := httptest.NewRequest("GET", tt.path, nil)
+				req.AddCookie(f.cookies[tt.user])
+				rec = httptest.NewRecorder()
+				f.app.handler.ServeHTTP(rec, req)
+			} else {
+				rec = f.app.post(tt.path, tt.form, f.cookies[tt.user])
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	// None of the refused requests changed anything
+	if group := f.app.group(t, f.group.ID); len(group.Members) != 2 {
+		t.Errorf("members = %+v, want carol and dave still", group.Members)
+	}
+}
+
+func TestManageMembers(t *testing.T) {
+	f := newFamily(t)
+	base := "/groups/" + f.group.ID
+	roleOf := func(user models.User) models.GroupRole {
+		role, _ := f.app.group(t, f.group.ID).RoleOf(user.ID)
+		return role
+	}
+
+	// The owner adds bob, then changes bob's role without adding a second entry
+	if rec := f.app.post(base+"/members", url.Values{"username": {"bob"}, "role": {"viewer"}}, f.cookies["alice"]); rec.Code != http.StatusOK {
+		t.Fatalf("add bob = %d, want 200", rec.Code)
+	}
+	if rec := f.app.post(base+"/members", url.Values{"username": {"bob"}, "role": {"editor"}}, f.cookies["alice"]); rec.Code != http.StatusOK {
+		t.Fatalf("promote bob = %d, want 200", rec.Code)
+	}
+	if role := roleOf(f.bob); role != models.GroupEditor || len(f.app.group(t, f.group.ID).Members) != 3 {
+		t.Errorf("bob's role = %q among %d members, want one editor entry", role, len(f.app.group(t, f.group.ID).Members))
+	}
+
+	tests := []struct {
+		name string
+		form url.Values
+	}{
+		{"the owner", url.Values{"username": {"alice"}, "role": {"viewer"}}},
+		{"an unknown user", url.Values{"username": {"nobody"}, "role": {"viewer"}}},
+		{"an unknown role", url.Values{"username": {"bob"}, "role": {"owner"}}},
+	}
+	for _, tt := range tests {
+		t.Run("adding "+tt.name, func(t *testing.T) {
+			if rec := f.app.post(base+"/members", tt.form, f.cookies["alice"]); rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+	if role := roleOf(f.alice); role != models.GroupEditor {
+		t.Errorf("owner's role = %q, want editor", role)
+	}
+	if role := roleOf(f.bob); role != models.GroupEditor {
+		t.Errorf("bob's role = %q after a rejected change, want editor", role)
+	}
+
+	// The owner removes a member and stays on the group page
+	rec := f.app.post(base+"/members/"+f.bob.ID+"/remove", nil, f.cookies["alice"])
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != base {
+		t.Errorf("remove bob = %d to %q, want a redirect to the group", rec.Code, rec.Header().Get("Location"))
+	}
+	if _, member := f.app.group(t, f.group.ID).RoleOf(f.bob.ID); member {
+		t.Error("bob is still a member")
+	}
+
+	// An admin manages a group they're not in
+	if rec := f.app.post(base+"/members/"+f.dave.ID+"/remove", nil, f.cookies["admin"]); rec.Code != http.StatusSeeOther {
+		t.Errorf("admin removes dave = %d, want 303", rec.Code)
+	}
+	if _, member := f.app.group(t, f.group.ID).RoleOf(f.dave.ID); member {
+		t.Error("dave is still a member")
+	}
+}
+
+func TestLeaveGroup(t *testing.T) {
+	f := newFamily(t)
+	base := "/groups/" + f.group.ID
+
+	// A member can remove themselves, and lands back on their group list
+	rec := f.app.post(base+"/members/"+f.carol.ID+"/remove", nil, f.cookies["carol"])
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/groups" {
+		t.Errorf("carol leaves = %d to %q, want a redirect to /groups", rec.Code, rec.Header().Get("Location"))
+	}
+	group := f.app.group(t, f.group.ID)
+	if _, member := group.RoleOf(f.carol.ID); member {
+		t.Error("carol is still a member")
+	}
+	if _, member := group.RoleOf(f.dave.ID); !member {
+		t.Error("dave was removed along with carol")
+	}
+
+	// Nobody can remove the owner, not even an admin; the group stays theirs
+	for _, user := range []string{"alice", "admin"} {
+		if rec := f.app.post(base+"/members/"+f.alice.ID+"/remove", nil, f.cookies[user]); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s removes the owner = %d, want 400", user, rec.Code)
+		}
+	}
+	if group := f.app.group(t, f.group.ID); group.OwnerID != f.alice.ID {
+		t.Errorf("owner = %q after the owner tried to leave, want alice", group.OwnerID)
+	}
+}
+
+func TestDeleteGroup(t *testing.T) {
+	f := newFamily(t)
+
+	rec := f.app.post("/groups/"+f.group.ID+"/delete", nil, f.cookies["alice"])
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("owner deletes = %d, want 303", rec.Code)
+	}
+	if _, err := f.app.store.GetGroup(f.group.ID); err == nil {
+		t.Error("group still exists")
+	}
+
+	// Admins can delete any group
+	other := models.Group{ID: "group-other", Name: "Other", OwnerID: f.bob.ID}
+	if err := f.app.store.CreateGroup(other); err != nil {
+		t.Fatal(err)
+	}
+	if rec := f.app.post("/groups/"+other.ID+"/delete", nil, f.cookies["admin"]); rec.Code != http.StatusSeeOther {
+		t.Errorf("admin deletes = %d, want 303", rec.Code)
+	}
+}
@@ -0,0 +1,161 @@
+// Cook mode: one large step at a time with timers, progress kept server-side
+
+package recipe
+
+import (
+	"encoding/json"
+	"errors"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// cookStep is what the cook mode page needs to know about each step
+type cookStep struct {
+	Number  int    `json:"number"`
+	Section string `json:"section"`
+	Text    string `json:"text"`
+	Timer   int64  `json:"timer"` // seconds, 0 when the step has no timer
+}
+
+// cookPage is the template data for the cook mode view
+type cookPage struct {
+	Recipe       models.Recipe
+	Steps        []cookStep
+	KeepProgress bool // only logged in users have progress saved
+}
+
+// Show the cook mode view
+func (h *RecipeHandler) cookRecipe(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	recipe, err := h.getVisible(r, id)
+	if err != nil {
+		h.log(r).Error("Error getting recipe to cook", slog.Any("error", err))
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
+
+	instructions := recipe.OrderedInstructions()
+	steps := make([]cookStep, len(instructions))
+	for i, step := range instructions {
+		steps[i] = cookStep{
+			Number:  i + 1,
+			Section: step.Section,
+			Text:    step.Step,
+			Timer:   int64(step.Timer() / time.Second),
+		}
+	}
+
+	data := handlers.NewTemplateData(r, "cook", cookPage{
+		Recipe:       recipe,
+		Steps:        steps,
+		KeepProgress: auth.CurrentUser(r) != nil,
+	})
+
+	err = h.tmpl.Render(w, data)
+	if err != nil {
+		h.log(r).Error("Error executing template", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Return the user's saved cook progress, or a fresh start if there is none
+func (h *RecipeHandler) getCookProgress(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	user := auth.CurrentUser(r)
+
+	if _, err := h.getVisible(r, id); err != nil {
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
+
+	progress, err := h.progress.GetCookProgress(user.ID, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		progress = models.CookProgress{UserID: user.ID, RecipeID: id, Timers: []models.StepTimer{}}
+	} else if err != nil {
+		h.log(r).Error("Error getting cook progress", slog.Any("error", err))
+		http.Error(w, "Error getting cook progress", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, progress)
+}
+
+// Save the user's cook progress sent by the cook mode page
+func (h *RecipeHandler) saveCookProgress(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	recipe, err := h.getVisible(r, id)
+	if err != nil {
+		h.log(r).Error("Error getting recipe for cook progress", slog.Any("error", err))
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
+
+	var progress models.CookProgress
+	if err := json.NewDecoder(r.Body).Decode(&progress); err != nil {
+		h.log(r).Error("Error decoding cook progress", slog.Any("error", err))
+		http.Error(w, "Invalid cook progress", http.StatusBadRequest)
+		return
+	}
+
+	// Steps are indexes into the ordered instructions, so keep them in range
+	stepCount := len(recipe.Instructions)
+	if progress.Step < 0 || progress.Step >= max(stepCount, 1) {
+		http.Error(w, "Step out of range", http.StatusBadRequest)
+		return
+	}
+	for _, timer := range progress.Timers {
+		if timer.Step < 0 || timer.Step >= stepCount {
+			http.Error(w, "Timer step out of range", http.StatusBadRequest)
+			return
+		}
+	}
+
+	progress.UserID = auth.CurrentUser(r).ID
+	progress.RecipeID = id
+	progress.UpdatedAt = time.Now().UTC()
+	if progress.Timers == nil {
+		progress.Timers = []models.StepTimer{}
+	}
+
+	if err := h.progress.SaveCookProgress(progress); err != nil {
+		h.log(r).Error("Error saving cook progress", slog.Any("error", err))
+		http.Error(w, "Error saving cook progress", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, progress)
+}
+
+// Reset the user's cook progress so their next session starts from the first step
+func (h *RecipeHandler) resetCookProgress(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	if _, err := h.getVisible(r, id); err != nil {
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.progress.DeleteCookProgress(auth.CurrentUser(r).ID, id); err != nil {
+		h.log(r).Error("Error resetting cook progress", slog.Any("error", err))
+		http.Error(w, "Error resetting cook progress", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON sends v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
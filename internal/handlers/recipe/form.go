@@ -0,0 +1,325 @@
+// Parsing and validating the recipe form
+
+package recipe
+
+import (
+	"fmt"
+	"go_recipe_app/internal/models"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldErrors maps a form field to what's wrong with it
+// Templates look errors up by field name, e.g. {{.Errors.title}}
+type fieldErrors map[string]string
+
+// recipeInput is the recipe form as the user filled it in
+// Everything stays a string so a form with mistakes can be shown again exactly as typed
+type recipeInput struct {
+	Title        string
+	Description  string
+	PrepTime     string // minutes
+	CookTime     string // minutes
+	Servings     string
+	Visibility   string
+	GroupID      string
+	Ingredients  []ingredientInput
+	Instructions []instructionInput
+}
+
+type ingredientInput struct {
+	Section string
+	Name    string
+	Amount  string
+	Unit    string
+	Error   string
+}
+
+type instructionInput struct {
+	Section string
+	Step    string
+	Minutes string
+	Error   string
+}
+
+// ingredientInputGroup and instructionInputGroup mirror the model's section groups
+// so the form templates can lay out the rows the same way
+type ingredientInputGroup struct {
+	Name        string
+	Ingredients []ingredientInput
+}
+
+type instructionInputGroup struct {
+	Name         string
+	Instructions []instructionInput
+}
+
+// decodeRecipeForm reads the recipe fields from a parsed form
+// The ingredient and step inputs are parallel arrays; if the browser sent arrays of
+// different lengths the rows can't be matched up, which is reported as a form error
+func decodeRecipeForm(r *http.Request) (recipeInput, fieldErrors) {
+	errs := fieldErrors{}
+	in := recipeInput{
+		Title:       strings.TrimSpace(r.FormValue("title")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+		PrepTime:    strings.TrimSpace(r.FormValue("prep_time")),
+		CookTime:    strings.TrimSpace(r.FormValue("cook_time")),
+		Servings:    strings.TrimSpace(r.FormValue("servings")),
+		Visibility:  r.FormValue("visibility"),
+		GroupID:     r.FormValue("group_id"),
+	}
+
+	names := r.Form["ingredient_names[]"]
+	amounts := r.Form["ingredient_amounts[]"]
+	units := r.Form["ingredient_units[]"]
+	sections := r.Form["ingredient_sections[]"]
+	if len(amounts) != len(names) || len(units) != len(names) || len(sections) > len(names) {
+		errs["ingredients"] = "The ingredient list was incomplete - reload the page and try again"
+	} else {
+		for i, name := range names {
+			row := ingredientInput{
+				Section: formSection(sections, i),
+				Name:    strings.TrimSpace(name),
+				Amount:  strings.TrimSpace(amounts[i]),
+				Unit:    strings.TrimSpace(units[i]),
+			}
+			if row.Name == "" && row.Amount == "" && row.Unit == "" {
+				continue // an empty row the user added and never filled in
+			}
+			in.Ingredients = append(in.Ingredients, row)
+		}
+	}
+
+	steps := r.Form["instructions[]"]
+	minutes := r.Form["instruction_minutes[]"]
+	stepSections := r.Form["instruction_sections[]"]
+	if len(minutes) > len(steps) || len(stepSections) > len(steps) {
+		errs["instructions"] = "The instruction list was incomplete - reload the page and try again"
+	} else {
+		for i, step := range steps {
+			row := instructionInput{
+				Section: formSection(stepSections, i),
+				Step:    strings.TrimSpace(step),
+			}
+			if i < len(minutes) {
+				row.Minutes = strings.TrimSpace(minutes[i])
+			}
+			if row.Step == "" && row.Minutes == "" {
+				continue
+			}
+			in.Instructions = append(in.Instructions, row)
+		}
+	}
+
+	return in, errs
+}
+
+// inputFromRecipe fills the form from a saved recipe, for the edit page
+func inputFromRecipe(recipe models.Recipe) recipeInput {
+	in := recipeInput{
+		Title:       recipe.Title,
+		Description: recipe.Description,
+		PrepTime:    formatMinutes(recipe.PrepTime),
+		CookTime:    formatMinutes(recipe.CookTime),
+		Servings:    strconv.Itoa(int(recipe.Servings)),
+		Visibility:  string(recipe.Visibility),
+		GroupID:     recipe.GroupID,
+	}
+	for _, group := range recipe.IngredientGroups() {
+		for _, ing := range group.Ingredients {
+			in.Ingredients = append(in.Ingredients, ingredientInput{
+				Section: group.Name,
+				Name:    ing.Name,
+				Amount:  strconv.FormatFloat(ing.Amount, 'f', -1, 64),
+				Unit:    ing.Unit,
+			})
+		}
+	}
+	for _, group := range recipe.InstructionGroups() {
+		for _, step := range group.Instructions {
+			row := instructionInput{Section: group.Name, Step: step.Step}
+			if step.Duration > 0 {
+				row.Minutes = formatMinutes(step.Duration)
+			}
+			in.Instructions = append(in.Instructions, row)
+		}
+	}
+	return in
+}
+
+// blankInput is the create form: one empty ingredient and step to start from
+func blankInput() recipeInput {
+	return recipeInput{
+		Visibility:   string(models.VisibilityPrivate),
+		Ingredients:  []ingredientInput{{Section: models.DefaultSection}},
+		Instructions: []instructionInput{{Section: models.DefaultSection}},
+	}
+}
+
+// recipe checks the input and converts it to a Recipe
+// Problems are recorded per field, and per row on the rows themselves, so they can all
+// be shown at once. The rows are checked here so the form can highlight them; the rest
+// goes through Recipe.Validate, the same as the API. The recipe is only usable when no
+// errors were added
+func (in *recipeInput) recipe(errs fieldErrors) models.Recipe {
+	recipe := models.Recipe{
+		Title:       in.Title,
+		Description: in.Description,
+	}
+
+	var err error
+	if recipe.PrepTime, err = parseMinutes(in.PrepTime, true); err != nil {
+		errs["prep_time"] = "Prep time " + err.Error()
+	}
+	if recipe.CookTime, err = parseMinutes(in.CookTime, true); err != nil {
+		errs["cook_time"] = "Cook time " + err.Error()
+	}
+
+	// A number that doesn't parse is left as 0 servings, which Validate reports
+	if servings, err := strconv.Atoi(in.Servings); err == nil && servings <= models.MaxServings {
+		recipe.Servings = int32(servings)
+	}
+
+	for i := range in.Ingredients {
+		row := &in.Ingredients[i]
+		amount, err := strconv.ParseFloat(row.Amount, 64)
+		switch {
+		case row.Name == "":
+			row.Error = "Name the ingredient"
+		case err != nil || math.IsNaN(amount) || math.IsInf(amount, 0):
+			row.Error = "Amount must be a number"
+		case amount < 0:
+			row.Error = "Amount can't be negative"
+		}
+		if row.Error != "" {
+			errs["ingredients"] = "Check the highlighted ingredients"
+			continue
+		}
+		recipe.Ingredients = append(recipe.Ingredients, models.Ingredient{
+			ID:       fmt.Sprintf("ing-%d", i),
+			Name:     row.Name,
+			Amount:   amount,
+			Unit:     row.Unit,
+			Section:  row.Section,
+			Position: i,
+		})
+	}
+
+	for i := range in.Instructions {
+		row := &in.Instructions[i]
+		duration, err := parseMinutes(row.Minutes, false)
+		switch {
+		case row.Step == "":
+			row.Error = "Describe the step"
+		case err != nil:
+			row.Error = "Timer " + err.Error()
+		}
+		if row.Error != "" {
+			errs["instructions"] = "Check the highlighted steps"
+			continue
+		}
+		recipe.Instructions = append(recipe.Instructions, models.Instruction{
+			ID:       fmt.Sprintf("step-%d", i),
+			Step:     row.Step,
+			Section:  row.Section,
+			Duration: duration,
+			Position: i,
+		})
+	}
+
+	// Rows with errors were left out, so only take Validate's word where the form
+	// hasn't already said what's wrong
+	for field, msg := range recipe.Validate() {
+		if errs[field] == "" {
+			errs[field] = msg
+		}
+	}
+
+	recipe.Normalize()
+	return recipe
+}
+
+// IngredientGroups groups the ingredient rows by section, in order of first appearance
+func (in recipeInput) IngredientGroups() []ingredientInputGroup {
+	var groups []ingredientInputGroup
+	index := make(map[string]int)
+	for _, row := range in.Ingredients {
+		name := row.Section
+		if name == "" {
+			name = models.DefaultSection
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, ingredientInputGroup{Name: name})
+		}
+		groups[i].Ingredients = append(groups[i].Ingredients, row)
+	}
+	if len(groups) == 0 {
+		groups = append(groups, ingredientInputGroup{Name: models.DefaultSection, Ingredients: []ingredientInput{{}}})
+	}
+	return groups
+}
+
+// InstructionGroups groups the step rows by section, in order of first appearance
+func (in recipeInput) InstructionGroups() []instructionInputGroup {
+	var groups []instructionInputGroup
+	index := make(map[string]int)
+	for _, row := range in.Instructions {
+		name := row.Section
+		if name == "" {
+			name = models.DefaultSection
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, instructionInputGroup{Name: name})
+		}
+		groups[i].Instructions = append(groups[i].Instructions, row)
+	}
+	if len(groups) == 0 {
+		groups = append(groups, instructionInputGroup{Name: models.DefaultSection, Instructions: []instructionInput{{}}})
+	}
+	return groups
+}
+
+// formSection returns the section name submitted for the i-th row
+// The section inputs are parallel to the item inputs, so a missing one means the default section
+func formSection(sections []string, i int) string {
+	if i < len(sections) {
+		return strings.TrimSpace(sections[i])
+	}
+	return ""
+}
+
+// parseMinutes reads a number of minutes, which may have a fraction like 1.5
+// A blank value is zero unless required is set
+func parseMinutes(s string, required bool) (time.Duration, error) {
+	if s == "" {
+		if required {
+			return 0, fmt.Errorf("is required")
+		}
+		return 0, nil
+	}
+	m, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(m) {
+		return 0, fmt.Errorf("must be a number of minutes")
+	}
+	if m < 0 {
+		return 0, fmt.Errorf("can't be negative")
+	}
+	if d := time.Duration(m * float64(time.Minute)); d > models.MaxRecipeTime {
+		return 0, fmt.Errorf("must be at most %d minutes", int(models.MaxRecipeTime.Minutes()))
+	}
+	return time.Duration(m * float64(time.Minute)), nil
+}
+
+// formatMinutes shows a duration as minutes, without a trailing ".0"
+func formatMinutes(d time.Duration) string {
+	return strconv.FormatFloat(d.Minutes(), 'f', -1, 64)
+}
@@ -0,0 +1,84 @@
+package recipe
+
+import (
+	"go_recipe_app/internal/models"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeRecipeForm(t *testing.T) {
+	valid := func() url.Values {
+		return url.Values{
+			"title":                 {"Pancakes"},
+			"prep_time":             {"10"},
+			"cook_time":             {"1.5"},
+			"servings":              {"4"},
+			"ingredient_names[]":    {"Flour", ""},
+			"ingredient_amounts[]":  {"200", ""},
+			"ingredient_units[]":    {"g", ""},
+			"ingredient_sections[]": {"Batter", ""},
+			"instructions[]":        {"Mix", "Fry"},
+			"instruction_minutes[]": {"", "2"},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		change     func(url.Values)
+		wantFields []string
+	}{
+		{"valid", func(url.Values) {}, nil},
+		{"missing title", func(v url.Values) { v.Set("title", " ") }, []string{"title"}},
+		{"long title", func(v url.Values) { v.Set("title", strings.Repeat("a", models.MaxTitleLength+1)) }, []string{"title"}},
+		{"negative prep time", func(v url.Values) { v.Set("prep_time", "-5") }, []string{"prep_time"}},
+		{"non-numeric cook time", func(v url.Values) { v.Set("cook_time", "soon") }, []string{"cook_time"}},
+		{"servings out of range", func(v url.Values) { v.Set("servings", "0") }, []string{"servings"}},
+		{"no ingredients", func(v url.Values) {
+			v["ingredient_names[]"], v["ingredient_amounts[]"], v["ingredient_units[]"], v["ingredient_sections[]"] = nil, nil, nil, nil
+		}, []string{"ingredients"}},
+		{"mismatched ingredient arrays", func(v url.Values) { v["ingredient_amounts[]"] = []string{"200"} }, []string{"ingredients"}},
+		{"bad amount", func(v url.Values) { v["ingredient_amounts[]"] = []string{"lots", ""} }, []string{"ingredients"}},
+		{"negative timer", func(v url.Values) { v["instruction_minutes[]"] = []string{"", "-1"} }, []string{"instructions"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := valid()
+			tt.change(form)
+			req := httptest.NewRequest("POST", "/recipes", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if err := req.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+
+			input, errs := decodeRecipeForm(req)
+			recipe := input.recipe(errs)
+
+			if len(errs) != len(tt.wantFields) {
+				t.Fatalf("errors = %v, want errors for %v", errs, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if errs[field] == "" {
+					t.Errorf("no error for %s, got %v", field, errs)
+				}
+			}
+			if len(errs) > 0 {
+				return
+			}
+
+			// The blank ingredient row is dropped and everything else carried over
+			if len(recipe.Ingredients) != 1 || recipe.Ingredients[0].Section != "Batter" {
+				t.Errorf("ingredients = %+v", recipe.Ingredients)
+			}
+			if recipe.CookTime != 90*time.Second {
+				t.Errorf("cook time = %v, want 1m30s", recipe.CookTime)
+			}
+			if len(recipe.Instructions) != 2 || recipe.Instructions[1].Duration != 2*time.Minute {
+				t.Errorf("instructions = %+v", recipe.Instructions)
+			}
+		})
+	}
+}
@@ -3,223 +3,216 @@
 package recipe
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/logging"
 	"go_recipe_app/internal/models"
 	"go_recipe_app/internal/storage"
-	"html/template"
 	"log/slog"
 	"net/http"
-	"strconv"
-	"time"
 
 	"github.com/gorilla/mux"
 )
 
-// TemplateData is a struct that holds the data for the template
-// This is a common design pattern in Go to pass data to templates
-type TemplateData struct {
-	Template string
-	Data     interface{}
-}
-
 // RecipeHandler holds all dependencies for recipe handling
 // Struct is like a class in OOP
 type RecipeHandler struct {
-	tmpl   *template.Template
-	logger *slog.Logger
-	Router *mux.Router // capitalize the first letter to export it
-	store  storage.RecipeStore
+	tmpl     *handlers.Renderer
+	logger   *slog.Logger
+	Router   *mux.Router // capitalize the first letter to export it
+	store    storage.ContextRecipeStore
+	progress storage.CookProgressStore
+	groups   storage.GroupStore
 }
 
 // new creates a new RecipeHandler
 // This is a constructor function that initializes the RecipeHandler struct with the necessary dependencies
-func New(tmpl *template.Template, store storage.RecipeStore, logger *slog.Logger) *RecipeHandler {
+func New(tmpl *handlers.Renderer, store storage.ContextRecipeStore, progress storage.CookProgressStore, groups storage.GroupStore, logger *slog.Logger) *RecipeHandler {
 	h := &RecipeHandler{
-		tmpl:   tmpl,
-		logger: logger,
-		Router: mux.NewRouter(),
-		store:  store,
+		tmpl:     tmpl,
+		logger:   logger,
+		Router:   mux.NewRouter(),
+		store:    store,
+		progress: progress,
+		groups:   groups,
 	}
 	h.setupRoutes()
 	return h
 }
 
+// recipePage is the template data for the recipe view
+// Embedding the recipe keeps {{.Title}} and friends working in the template
+type recipePage struct {
+	models.Recipe
+	CanEdit   bool
+	CanManage bool   // owners and admins may also delete
+	GroupName string // the group it's shared with, if the viewer can see it
+}
+
+// recipeForm is the template data for the create and edit forms
+// It holds the user's input rather than a Recipe so a rejected form comes back as typed
+type recipeForm struct {
+	recipeInput
+	ID        string         // empty on the create form
+	Groups    []models.Group // groups the user can share the recipe with
+	CanManage bool           // whether to show the sharing controls
+	Errors    fieldErrors
+}
+
+// log returns the logger for a request, tagged with its request ID
+func (h *RecipeHandler) log(r *http.Request) *slog.Logger {
+	return logging.FromContextOr(r.Context(), h.logger)
+}
+
 // setupRoutes registers all routes with the recipe handler
 func (h *RecipeHandler) setupRoutes() {
 	// Root route currently redirects to /recipes
 	h.Router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/recipes", http.StatusSeeOther)
 	}).Methods("GET")
-	h.Router.HandleFunc("/recipes", h.listRecipes).Methods("GET")          // list all recipes
-	h.Router.HandleFunc("/recipes/new", h.createRecipeForm).Methods("GET") // Show create form
-	h.Router.HandleFunc("/recipes", h.createRecipe).Methods("POST")        // Handle form submission
+	h.Router.HandleFunc("/recipes", h.listRecipes).Methods("GET")                             // list all recipes
+	h.Router.HandleFunc("/recipes/new", auth.RequireLogin(h.createRecipeForm)).Methods("GET") // Show create form
+	h.Router.HandleFunc("/recipes", auth.RequireLogin(h.createRecipe)).Methods("POST")        // Handle form submission
 	h.Router.HandleFunc("/recipes/{id}", h.getRecipe).Methods("GET")
-	h.Router.HandleFunc("/recipes/{id}/edit", h.editRecipeForm).Methods("GET")
-	h.Router.HandleFunc("/recipes/{id}", h.updateRecipe).Methods("PUT")
-	h.Router.HandleFunc("/recipes/{id}", h.deleteRecipe).Methods("DELETE")
+	h.Router.HandleFunc("/recipes/{id}/edit", auth.RequireLogin(h.editRecipeForm)).Methods("GET")
+	h.Router.HandleFunc("/recipes/{id}", auth.RequireLogin(h.updateRecipe)).Methods("PUT")
+	h.Router.HandleFunc("/recipes/{id}", auth.RequireLogin(h.deleteRecipe)).Methods("DELETE")
+
+	// Cook mode
+	h.Router.HandleFunc("/recipes/{id}/cook", h.cookRecipe).Methods("GET")
+	h.Router.HandleFunc("/recipes/{id}/cook/progress", auth.RequireLogin(h.getCookProgress)).Methods("GET")
+	h.Router.HandleFunc("/recipes/{id}/cook/progress", auth.RequireLogin(h.saveCookProgress)).Methods("PUT")
+	h.Router.HandleFunc("/recipes/{id}/cook/progress", auth.RequireLogin(h.resetCookProgress)).Methods("DELETE")
+
+	// Timeline for cooking several recipes at once
+	h.Router.HandleFunc("/timeline", h.showTimeline).Methods("GET")
 }
 
 // Basic handler for listing recipes
 func (h *RecipeHandler) listRecipes(w http.ResponseWriter, r *http.Request) {
-	h.logger.Info("Handling list recipes request")
+	h.log(r).Info("Handling list recipes request")
 
-	recipes, err := h.store.List()
+	recipes, err := h.store.ListVisible(r.Context(), auth.CurrentViewer(r))
+	if errors.Is(err, context.Canceled) {
+		// The browser gave up, so there's nobody to send the page to
+		h.log(r).Info("Client went away while listing recipes")
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		h.log(r).Warn("Listing recipes timed out")
+		http.Error(w, "The database is busy - try again shortly", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
-		h.logger.Error("Error listing recipes", slog.Any("error", err))
+		h.log(r).Error("Error listing recipes", slog.Any("error", err))
 		http.Error(w, "Error getting recipes", http.StatusInternalServerError)
 		return
 	}
-	// h.logger.Printf("Found %d recipe", len(recipes))
+	// h.log(r).Printf("Found %d recipe", len(recipes))
 
-	data := TemplateData{
-		Template: "list",
-		Data:     recipes,
-	}
+	data := handlers.NewTemplateData(r, "list", recipes)
 
-	err = h.tmpl.ExecuteTemplate(w, "layout.html", data)
+	err = h.tmpl.Render(w, data)
 	if err != nil {
-		h.logger.Error("Error executing template", slog.Any("error", err))
+		h.log(r).Error("Error executing template", slog.Any("error", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	h.logger.Info("Successfully rendered list of test recipes")
+	h.log(r).Info("Successfully rendered list of test recipes")
 }
 
 // Basic handler for getting a single recipe
 func (h *RecipeHandler) getRecipe(w http.ResponseWriter, r *http.Request) {
-	h.logger.Info("Handling get recipe request")
+	h.log(r).Info("Handling get recipe request")
 
 	// Get recipe ID from URL parameters
 	vars := mux.Vars(r)
 	id := vars["id"]
-	h.logger.Info("Looking for recipe with ID", slog.String("id", id))
+	h.log(r).Info("Looking for recipe with ID", slog.String("id", id))
 
 	// Get recipe from store
-	recipe, err := h.store.Get(id)
+	recipe, err := h.getVisible(r, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Recipe not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		h.logger.Error("Error getting recipe", slog.Any("error", err))
+		h.log(r).Error("Error getting recipe", slog.Any("error", err))
 		http.Error(w, "Error getting recipe", http.StatusInternalServerError)
 		return
 	}
 
 	// Render recipe
-	data := TemplateData{
-		Template: "view",
-		Data:     recipe,
-	}
+	data := handlers.NewTemplateData(r, "view", recipePage{
+		Recipe:    recipe,
+		CanEdit:   auth.CanEdit(r, recipe),
+		CanManage: auth.CanManage(r, recipe),
+		GroupName: h.groupName(recipe.GroupID),
+	})
 
 	// Execute template
-	err = h.tmpl.ExecuteTemplate(w, "layout.html", data)
+	err = h.tmpl.Render(w, data)
 	if err != nil {
-		h.logger.Error("Error executing template", slog.Any("error", err))
+		h.log(r).Error("Error executing template", slog.Any("error", err))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
-	h.logger.Info("Successfully rendered recipe", slog.String("title", recipe.Title))
+	h.log(r).Info("Successfully rendered recipe", slog.String("title", recipe.Title))
 }
 
 // Show the create recipe form
 func (h *RecipeHandler) createRecipeForm(w http.ResponseWriter, r *http.Request) {
-	data := TemplateData{
-		Template: "create",
-		Data:     nil,
-	}
-
-	err := h.tmpl.ExecuteTemplate(w, "layout.html", data)
-	if err != nil {
-		h.logger.Error("Error executing template", slog.Any("error", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
+	h.renderForm(w, r, http.StatusOK, "create", recipeForm{
+		recipeInput: blankInput(),
+		CanManage:   true,
+	})
 }
 
 // Handle the form submission
 func (h *RecipeHandler) createRecipe(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		h.logger.Error("Error parsing form", slog.Any("error", err))
+		h.log(r).Error("Error parsing form", slog.Any("error", err))
 		http.Error(w, "Error processing form", http.StatusBadRequest)
 		return
 	}
 
-	// Generate a unique ID (we'll improve this later)
-	id := fmt.Sprintf("recipe-%d", time.Now().Unix())
+	input, errs := decodeRecipeForm(r)
+	recipe := input.recipe(errs)
 
-	// Parse form values with error handling
-	prepTime, err := time.ParseDuration(r.FormValue("prep_time") + "m")
+	visibility, groupID, err := h.formSharing(r)
 	if err != nil {
-		h.logger.Error("Invalid prep time", slog.Any("error", err))
-		http.Error(w, "Invalid prep time", http.StatusBadRequest)
-		return
+		errs["sharing"] = err.Error()
 	}
 
-	cookTime, err := time.ParseDuration(r.FormValue("cook_time") + "m")
-	if err != nil {
-		h.logger.Error("Invalid cook time", slog.Any("error", err))
-		http.Error(w, "Invalid cook time", http.StatusBadRequest)
+	// Show the form again with the problems marked and everything the user typed kept
+	if len(errs) > 0 {
+		h.log(r).Info("Recipe form has errors", slog.Any("fields", errs))
+		h.renderForm(w, r, http.StatusUnprocessableEntity, "create", recipeForm{
+			recipeInput: input,
+			CanManage:   true,
+			Errors:      errs,
+		})
 		return
 	}
 
-	servings, err := strconv.Atoi(r.FormValue("servings"))
+	// Generate a unique ID - random, since the store refuses to overwrite an existing recipe
+	id, err := auth.NewID("recipe")
 	if err != nil {
-		h.logger.Error("Invalid servings", slog.Any("error", err))
-		http.Error(w, "Invalid servings", http.StatusBadRequest)
-		return
-	}
-
-	// Parse ingredients
-	names := r.Form["ingredient_names[]"]
-	amounts := r.Form["ingredient_amounts[]"]
-	units := r.Form["ingredient_units[]"]
-
-	ingredients := make([]models.Ingredient, len(names))
-	for i := range names {
-		amount, err := strconv.ParseFloat(amounts[i], 64)
-		if err != nil {
-			h.logger.Error("Invalid amount for ingredient", slog.Any("error", err))
-			http.Error(w, "Invalid ingredient amount", http.StatusBadRequest)
-			return
-		}
-
-		ingredients[i] = models.Ingredient{
-			ID:       fmt.Sprintf("ing-%d", i),
-			Name:     names[i],
-			Amount:   amount,
-			Unit:     units[i],
-			Position: i,
-		}
-	}
-
-	// Parse instructions
-	instructionSteps := r.Form["instructions[]"]
-	instructions := make([]models.Instruction, len(instructionSteps))
-	for i, step := range instructionSteps {
-		instructions[i] = models.Instruction{
-			ID:       fmt.Sprintf("step-%d", i),
-			Step:     step,
-			Position: i,
-		}
-	}
-
-	recipe := models.Recipe{
-		ID:           id,
-		Title:        r.FormValue("title"),
-		Description:  r.FormValue("description"),
-		PrepTime:     prepTime,
-		CookTime:     cookTime,
-		Servings:     int32(servings),
-		Ingredients:  ingredients,
-		Instructions: instructions,
-	}
-
-	// Validate required fields
-	if recipe.Title == "" {
-		http.Error(w, "Title is required", http.StatusBadRequest)
+		h.log(r).Error("Error generating recipe id", slog.Any("error", err))
+		http.Error(w, "Error saving recipe", http.StatusInternalServerError)
 		return
 	}
+	recipe.ID = id
+	recipe.OwnerID = auth.CurrentUser(r).ID
+	recipe.Visibility = visibility
+	recipe.GroupID = groupID
 
 	// Store the recipe
-	if err := h.store.Create(recipe); err != nil {
-		h.logger.Error("Error creating recipe", slog.Any("error", err))
+	if err := h.store.Create(r.Context(), recipe); err != nil {
+		h.log(r).Error("Error creating recipe", slog.Any("error", err))
 		http.Error(w, "Error saving recipe", http.StatusInternalServerError)
 		return
 	}
@@ -233,148 +226,169 @@ func (h *RecipeHandler) editRecipeForm(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	recipe, err := h.store.Get(id)
+	recipe, err := h.getVisible(r, id)
 	if err != nil {
-		h.logger.Error("Error getting recipe to edit", slog.Any("error", err))
+		h.log(r).Error("Error getting recipe to edit", slog.Any("error", err))
 		http.Error(w, "Recipe not found", http.StatusNotFound)
 		return
 	}
 
-	data := TemplateData{
-		Template: "edit",
-		Data:     recipe,
-	}
-
-	err = h.tmpl.ExecuteTemplate(w, "layout.html", data)
-	if err != nil {
-		h.logger.Error("Error executing template", slog.Any("error", err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	if !auth.CanEdit(r, recipe) {
+		http.Error(w, "You can only edit your own recipes", http.StatusForbidden)
 		return
 	}
+
+	h.renderForm(w, r, http.StatusOK, "edit", recipeForm{
+		recipeInput: inputFromRecipe(recipe),
+		ID:          recipe.ID,
+		CanManage:   auth.CanManage(r, recipe),
+	})
 }
 
 // Handle the update
 func (h *RecipeHandler) updateRecipe(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
-	h.logger.Info("Update request - ID format check", slog.String("id", id))
+	h.log(r).Info("Update request - ID format check", slog.String("id", id))
 
-	// Just check if recipe exists
-	if _, err := h.store.Get(id); err != nil {
-		h.logger.Error("Error getting existing recipe", slog.Any("error", err))
+	// Check the recipe exists and the user may change it
+	existing, err := h.getVisible(r, id)
+	if err != nil {
+		h.log(r).Error("Error getting existing recipe", slog.Any("error", err))
 		http.Error(w, "Recipe not found", http.StatusNotFound)
 		return
 	}
+	if !auth.CanEdit(r, existing) {
+		http.Error(w, "You can only edit your own recipes", http.StatusForbidden)
+		return
+	}
 
 	// Parse form values
 	if err := r.ParseForm(); err != nil {
-		h.logger.Error("Error parsing form", slog.Any("error", err))
+		h.log(r).Error("Error parsing form", slog.Any("error", err))
 		http.Error(w, "Error processing form", http.StatusBadRequest)
 		return
 	}
 
-	// Debug logging for all form values
-	h.logger.Info("All form values", slog.Any("form", r.Form))
-	h.logger.Info("Form method", slog.String("method", r.Method))
-	h.logger.Info("Content-Type", slog.String("content_type", r.Header.Get("Content-Type")))
+	// The whole form is only worth logging when debugging; the logger masks any secrets in it
+	h.log(r).Debug("Recipe form submitted", slog.Any("form", r.Form),
+		slog.String("content_type", r.Header.Get("Content-Type")))
 
-	// Check if we're getting the value from Form vs PostForm
-	h.logger.Info("prep_time from FormValue", slog.String("prep_time", r.FormValue("prep_time")))
-	h.logger.Info("prep_time from Form", slog.String("prep_time", r.Form.Get("prep_time")))
-	h.logger.Info("prep_time from PostForm", slog.String("prep_time", r.PostForm.Get("prep_time")))
+	input, errs := decodeRecipeForm(r)
+	recipe := input.recipe(errs)
 
-	// Parse form values with error handling
-	prepTimeStr := r.FormValue("prep_time")
-	if prepTimeStr == "" {
-		h.logger.Error("Empty prep time received")
-		http.Error(w, "Prep time is required", http.StatusBadRequest)
-		return
+	// Group editors can change the recipe but not who it's shared with
+	visibility, groupID := existing.Visibility, existing.GroupID
+	if auth.CanManage(r, existing) {
+		visibility, groupID, err = h.formSharing(r)
+		if err != nil {
+			errs["sharing"] = err.Error()
+		}
 	}
-	prepTime := time.Duration(mustParseFloat(prepTimeStr)) * time.Minute
 
-	cookTimeStr := r.FormValue("cook_time")
-	if cookTimeStr == "" {
-		h.logger.Error("Empty cook time received")
-		http.Error(w, "Cook time is required", http.StatusBadRequest)
+	// The edit page submits with fetch and swaps in the returned form when it gets a 422
+	if len(errs) > 0 {
+		h.log(r).Info("Recipe form has errors", slog.Any("fields", errs))
+		h.renderForm(w, r, http.StatusUnprocessableEntity, "edit", recipeForm{
+			recipeInput: input,
+			ID:          id,
+			CanManage:   auth.CanManage(r, existing),
+			Errors:      errs,
+		})
 		return
 	}
-	cookTime := time.Duration(mustParseFloat(cookTimeStr)) * time.Minute
 
-	servings, err := strconv.Atoi(r.FormValue("servings"))
-	if err != nil {
-		h.logger.Error("Invalid servings", slog.Any("error", err))
-		http.Error(w, "Invalid servings", http.StatusBadRequest)
+	recipe.ID = id
+	recipe.OwnerID = existing.OwnerID // the form never changes ownership
+	recipe.Visibility = visibility
+	recipe.GroupID = groupID
+
+	if err := h.store.Update(r.Context(), recipe); err != nil {
+		h.log(r).Error("Error updating recipe", slog.Any("error", err))
+		http.Error(w, "Error updating recipe", http.StatusInternalServerError)
 		return
 	}
 
-	// Parse ingredients
-	names := r.Form["ingredient_names[]"]
-	amounts := r.Form["ingredient_amounts[]"]
-	units := r.Form["ingredient_units[]"]
-
-	ingredients := make([]models.Ingredient, len(names))
-	for i := range names {
-		amount, err := strconv.ParseFloat(amounts[i], 64)
-		if err != nil {
-			h.logger.Error("Invalid amount for ingredient", slog.Any("error", err))
-			http.Error(w, "Invalid ingredient amount", http.StatusBadRequest)
-			return
-		}
+	// Instead of redirecting, send a success response
+	w.WriteHeader(http.StatusOK)
+	// Optionally send a success message
+	w.Write([]byte("Recipe updated successfully"))
+}
 
-		ingredients[i] = models.Ingredient{
-			ID:       fmt.Sprintf("ing-%d", i),
-			Name:     names[i],
-			Amount:   amount,
-			Unit:     units[i],
-			Position: i,
-		}
+// renderForm shows the create or edit form with the groups the user can share with
+func (h *RecipeHandler) renderForm(w http.ResponseWriter, r *http.Request, status int, page string, form recipeForm) {
+	groups, err := h.shareableGroups(r)
+	if err != nil {
+		h.log(r).Error("Error listing groups", slog.Any("error", err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
 	}
+	form.Groups = groups
 
-	// Parse instructions
-	instructionSteps := r.Form["instructions[]"]
-	instructions := make([]models.Instruction, len(instructionSteps))
-	for i, step := range instructionSteps {
-		instructions[i] = models.Instruction{
-			ID:       fmt.Sprintf("step-%d", i),
-			Step:     step,
-			Position: i,
-		}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := h.tmpl.Render(w, handlers.NewTemplateData(r, page, form)); err != nil {
+		h.log(r).Error("Error executing template", slog.Any("error", err))
 	}
+}
 
-	recipe := models.Recipe{
-		ID:           id,
-		Title:        r.FormValue("title"),
-		Description:  r.FormValue("description"),
-		PrepTime:     prepTime,
-		CookTime:     cookTime,
-		Servings:     int32(servings),
-		Ingredients:  ingredients,
-		Instructions: instructions,
+// getVisible loads a recipe the requester is allowed to see
+// Hidden recipes are reported as not found so their IDs don't leak
+func (h *RecipeHandler) getVisible(r *http.Request, id string) (models.Recipe, error) {
+	recipe, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		return models.Recipe{}, err
 	}
+	if !auth.CanView(r, recipe) {
+		return models.Recipe{}, fmt.Errorf("recipe %s: %w", id, storage.ErrNotFound)
+	}
+	return recipe, nil
+}
 
-	h.logger.Info("Method", slog.String("method", r.Method))
-	h.logger.Info("Content-Type", slog.String("content_type", r.Header.Get("Content-Type")))
-	h.logger.Info("Raw prep_time value", slog.String("prep_time", r.FormValue("prep_time")))
-	h.logger.Info("Raw cook_time value", slog.String("cook_time", r.FormValue("cook_time")))
-
-	if err := h.store.Update(recipe); err != nil {
-		h.logger.Error("Error updating recipe", slog.Any("error", err))
-		http.Error(w, "Error updating recipe", http.StatusInternalServerError)
-		return
+// groupName returns the name of a group, or "" if it no longer exists
+func (h *RecipeHandler) groupName(id string) string {
+	if id == "" {
+		return ""
 	}
+	group, err := h.groups.GetGroup(id)
+	if err != nil {
+		return ""
+	}
+	return group.Name
+}
 
-	// Instead of redirecting, send a success response
-	w.WriteHeader(http.StatusOK)
-	// Optionally send a success message
-	w.Write([]byte("Recipe updated successfully"))
+// shareableGroups returns the groups the requester can share recipes with
+// Admins can share with any group
+func (h *RecipeHandler) shareableGroups(r *http.Request) ([]models.Group, error) {
+	viewer := auth.CurrentViewer(r)
+	if viewer.Admin {
+		return h.groups.ListGroups()
+	}
+	return h.groups.ListGroupsForUser(viewer.UserID)
 }
 
-func mustParseFloat(s string) float64 {
-	f, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		return 0
+// formSharing reads the visibility and group fields from the form
+// New recipes are private unless the user says otherwise
+func (h *RecipeHandler) formSharing(r *http.Request) (models.Visibility, string, error) {
+	visibility := models.Visibility(r.FormValue("visibility"))
+	if visibility == "" {
+		visibility = models.VisibilityPrivate
+	}
+	// The group picker is submitted even when it's not used
+	groupID := ""
+	if visibility == models.VisibilityGroup {
+		groupID = r.FormValue("group_id")
 	}
-	return f
+
+	if err := auth.CurrentViewer(r).CheckSharing(visibility, groupID); err != nil {
+		return "", "", err
+	}
+	if groupID != "" {
+		if _, err := h.groups.GetGroup(groupID); err != nil {
+			return "", "", fmt.Errorf("group not found: %s", groupID)
+		}
+	}
+	return visibility, groupID, nil
 }
 
 // Delete recipe handler
@@ -382,23 +396,27 @@ func (h *RecipeHandler) deleteRecipe(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	h.logger.Info("Attempting to delete recipe", slog.String("id", id))
+	h.log(r).Info("Attempting to delete recipe", slog.String("id", id))
 
 	// Check if recipe exists
-	_, err := h.store.Get(id)
+	recipe, err := h.getVisible(r, id)
 	if err != nil {
-		h.logger.Error("Recipe not found for deletion", slog.Any("error", err))
+		h.log(r).Error("Recipe not found for deletion", slog.Any("error", err))
 		http.Error(w, "Recipe not found", http.StatusNotFound)
 		return
 	}
+	if !auth.CanManage(r, recipe) {
+		http.Error(w, "You can only delete your own recipes", http.StatusForbidden)
+		return
+	}
 
-	// Delete the recipe
-	if err := h.store.Delete(id); err != nil {
-		h.logger.Error("Error deleting recipe", slog.Any("error", err))
+	// Delete the recipe, along with everyone's cook progress for it
+	if err := handlers.DeleteRecipe(r.Context(), h.store, h.progress, id, h.log(r)); err != nil {
+		h.log(r).Error("Error deleting recipe", slog.Any("error", err))
 		http.Error(w, "Error deleting recipe", http.StatusInternalServerError)
 		return
 	}
 
-	h.logger.Info("Successfully deleted recipe", slog.String("id", id))
+	h.log(r).Info("Successfully deleted recipe", slog.String("id", id))
 	http.Redirect(w, r, "/recipes", http.StatusSeeOther)
 }
@@ -0,0 +1,257 @@
+package recipe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage/memory"
+	"go_recipe_app/internal/timeline"
+	"go_recipe_app/web"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testApp is the recipe handler behind the session middleware, backed by memory
+type testApp struct {
+	handler http.Handler
+	auth    *auth.Manager
+	store   *memory.Store
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	files := web.Files("")
+	assets, err := web.NewAssets(files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := handlers.NewRenderer(web.Templates(files), assets.FuncMap())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.New()
+	manager := auth.NewManager(store, store, store, store, auth.Options{}, logger)
+	h := New(tmpl, store, store, store, logger)
+	return &testApp{handler: manager.Middleware(h.Router), auth: manager, store: store}
+}
+
+// user creates an account and returns it with a logged in session cookie
+func (a *testApp) user(t *testing.T, username string, role models.Role) (models.User, *http.Cookie) {
+	t.Helper()
+	user, err := a.auth.CreateUser(username, "correct horse", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	if err := a.auth.StartSession(rec, user); err != nil {
+		t.Fatal(err)
+	}
+	return user, rec.Result().Cookies()[0]
+}
+
+// do sends a request as the holder of cookie, or as a visitor when it's nil
+func (a *testApp) do(method, path, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if strings.HasPrefix(body, "{") {
+		req.Header.Set("Content-Type", "application/json")
+	} else if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	a.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func (a *testApp) recipe(t *testing.T, owner models.User, visibility models.Visibility) models.Recipe {
+	t.Helper()
+	recipe := models.Recipe{
+		ID:           "recipe-" + owner.Username + "-" + string(visibility),
+		Title:        "Soup",
+		Servings:     2,
+		OwnerID:      owner.ID,
+		Visibility:   visibility,
+		Ingredients:  []models.Ingredient{{ID: "ing-1", Name: "Water", Amount: 1, Unit: "l"}},
+		Instructions: []models.Instruction{{ID: "step-1", Step: "Boil", Duration: 10 * time.Minute}, {ID: "step-2", Step: "Serve", Position: 1}},
+	}
+	if err := a.store.Create(context.Background(), recipe); err != nil {
+		t.Fatal(err)
+	}
+	return recipe
+}
+
+func TestEditPermissions(t *testing.T) {
+	app := newTestApp(t)
+	alice, aliceCookie := app.user(t, "alice", models.RoleMember)
+	_, bobCookie := app.user(t, "bob", models.RoleMember)
+	_, adminCookie := app.user(t, "admin", models.RoleAdmin)
+	public := app.recipe(t, alice, models.VisibilityPublic)
+	private := app.recipe(t, alice, models.VisibilityPrivate)
+
+	form := "title=Better+Soup&prep_time=5&cook_time=10&servings=2&visibility=public&ingredient_names[]=Water&ingredient_amounts[]=1&ingredient_units[]=l&ingredient_sections[]=&instructions[]=Boil&instruction_minutes[]="
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		cookie     *http.Cookie
+		wantStatus int
+	}{
+		{"visitor views public", "GET", "/recipes/" + public.ID, nil, http.StatusOK},
+		{"visitor edits", "PUT", "/recipes/" + public.ID, nil, http.StatusUnauthorized},
+		{"non-owner views public", "GET", "/recipes/" + public.ID, bobCookie, http.StatusOK},
+		{"non-owner edit form", "GET", "/recipes/" + public.ID + "/edit", bobCookie, http.StatusForbidden},
+		{"non-owner edits", "PUT", "/recipes/" + public.ID, bobCookie, http.StatusForbidden},
+		{"non-owner deletes", "DELETE", "/recipes/" + public.ID, bobCookie, http.StatusForbidden},
+		{"non-owner views private", "GET", "/recipes/" + private.ID, bobCookie, http.StatusNotFound},
+		{"non-owner deletes private", "DELETE", "/recipes/" + private.ID, bobCookie, http.StatusNotFound},
+		{"owner edits", "PUT", "/recipes/" + public.ID, aliceCookie, http.StatusOK},
+		{"admin deletes", "DELETE", "/recipes/" + private.ID, adminCookie, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := ""
+			if tt.method == "PUT" {
+				body = form
+			}
+			if rec := app.do(tt.method, tt.path, body, tt.cookie); rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	// Only the owner's edit and the admin's delete went through
+	got, err := app.store.Get(context.Background(), public.ID)
+	if err != nil {
+		t.Fatalf("public recipe gone: %v", err)
+	}
+	if got.Title != "Better Soup" {
+		t.Errorf("title = %q, want the owner's edit", got.Title)
+	}
+	if _, err := app.store.Get(context.Background(), private.ID); err == nil {
+		t.Error("admin delete left the recipe")
+	}
+}
+
+func TestGroupEditorPermissions(t *testing.T) {
+	app := newTestApp(t)
+	alice, aliceCookie := app.user(t, "alice", models.RoleMember)
+	dave, daveCookie := app.user(t, "dave", models.RoleMember)
+	group := models.Group{ID: "group-family", Name: "Family", OwnerID: alice.ID,
+		Members: []models.GroupMember{{UserID: dave.ID, Role: models.GroupEditor}}}
+	if err := app.store.CreateGroup(group); err != nil {
+		t.Fatal(err)
+	}
+	recipe := app.recipe(t, alice, models.VisibilityGroup)
+	recipe.GroupID = group.ID
+	if err := app.store.Update(context.Background(), recipe); err != nil {
+		t.Fatal(err)
+	}
+	path := "/recipes/" + recipe.ID
+
+	// A group editor can change the recipe, but deleting it is left to its owner
+	form := "title=Better+Soup&prep_time=5&cook_time=10&servings=2&ingredient_names[]=Water&ingredient_amounts[]=1&ingredient_units[]=l&ingredient_sections[]=&instructions[]=Boil&instruction_minutes[]="
+	if rec := app.do("PUT", path, form, daveCookie); rec.Code != http.StatusOK {
+		t.Errorf("group editor edits = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if rec := app.do("GET", path, "", daveCookie); strings.Contains(rec.Body.String(), "deleteRecipe(") {
+		t.Error("group editor is shown the delete button")
+	}
+	if rec := app.do("GET", path, "", aliceCookie); !strings.Contains(rec.Body.String(), "deleteRecipe(") {
+		t.Error("owner isn't shown the delete button")
+	}
+	if rec := app.do("DELETE", path, "", daveCookie); rec.Code != http.StatusForbidden {
+		t.Errorf("group editor deletes = %d, want 403", rec.Code)
+	}
+	if rec := app.do("DELETE", path, "", aliceCookie); rec.Code != http.StatusSeeOther {
+		t.Errorf("owner deletes = %d, want 303", rec.Code)
+	}
+}
+
+func TestCookProgressPerUser(t *testing.T) {
+	app := newTestApp(t)
+	alice, aliceCookie := app.user(t, "alice", models.RoleMember)
+	_, bobCookie := app.user(t, "bob", models.RoleMember)
+	path := "/recipes/" + app.recipe(t, alice, models.VisibilityPublic).ID + "/cook/progress"
+
+	step := func(cookie *http.Cookie) int {
+		t.Helper()
+		rec := app.do("GET", path, "", cookie)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET progress = %d", rec.Code)
+		}
+		var progress models.CookProgress
+		if err := json.NewDecoder(rec.Body).Decode(&progress); err != nil {
+			t.Fatal(err)
+		}
+		return progress.Step
+	}
+
+	if rec := app.do("PUT", path, `{"step": 1}`, aliceCookie); rec.Code != http.StatusOK {
+		t.Fatalf("alice's PUT = %d: %s", rec.Code, rec.Body)
+	}
+	if got := step(bobCookie); got != 0 {
+		t.Errorf("bob sees step %d, want a fresh start", got)
+	}
+
+	// Bob saving and resetting his own progress leaves alice's alone
+	if rec := app.do("PUT", path, `{"step": 1}`, bobCookie); rec.Code != http.StatusOK {
+		t.Fatalf("bob's PUT = %d", rec.Code)
+	}
+	if rec := app.do("DELETE", path, "", bobCookie); rec.Code != http.StatusNoContent {
+		t.Fatalf("bob's DELETE = %d", rec.Code)
+	}
+	if got := step(aliceCookie); got != 1 {
+		t.Errorf("alice sees step %d after bob reset, want 1", got)
+	}
+
+	// Visitors have no progress to read or change
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		if rec := app.do(method, path, "", nil); rec.Code != http.StatusSeeOther && rec.Code != http.StatusUnauthorized {
+			t.Errorf("visitor %s = %d, want a login prompt", method, rec.Code)
+		}
+	}
+}
+
+func TestTimelineRecipeLimit(t *testing.T) {
+	app := newTestApp(t)
+	alice, cookie := app.user(t, "alice", models.RoleMember)
+	soup := app.recipe(t, alice, models.VisibilityPrivate)
+
+	ids := []string{soup.ID, soup.ID, soup.ID}
+	rec := app.do("GET", "/timeline?"+url.Values{"recipe": ids, "format": {"json"}}.Encode(), "", cookie)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("repeated recipe = %d, want 200", rec.Code)
+	}
+	var plan timeline.Plan
+	if err := json.NewDecoder(rec.Body).Decode(&plan); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Entries) != len(soup.Instructions) {
+		t.Errorf("plan has %d steps, want the recipe's %d once", len(plan.Entries), len(soup.Instructions))
+	}
+
+	// Too many is a bad request whether the page or JSON was asked for
+	ids = nil
+	for i := 0; i <= timeline.MaxRecipes; i++ {
+		ids = append(ids, fmt.Sprintf("recipe-%d", i))
+	}
+	for _, format := range []string{"", "json"} {
+		rec := app.do("GET", "/timeline?"+url.Values{"recipe": ids, "format": {format}}.Encode(), "", cookie)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("format %q with %d recipes = %d, want 400", format, len(ids), rec.Code)
+		}
+	}
+}
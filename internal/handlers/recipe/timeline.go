@@ -0,0 +1,115 @@
+// Multi-recipe cooking timeline
+
+package recipe
+
+import (
+	"fmt"
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/handlers"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/timeline"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serveTimeLayout matches the value of an <input type="datetime-local">
+const serveTimeLayout = "2006-01-02T15:04"
+
+// timelinePage is the template data for the timeline view
+type timelinePage struct {
+	Recipes    []models.Recipe
+	Selected   map[string]bool
+	MaxRecipes int
+	ServeAt    string
+	Plan       *timeline.Plan
+	Error      string
+}
+
+// Show the timeline form, and the merged plan once recipes are picked
+// Ask for JSON with ?format=json or an Accept: application/json header
+func (h *RecipeHandler) showTimeline(w http.ResponseWriter, r *http.Request) {
+	wantJSON := r.URL.Query().Get("format") == "json" ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+
+	page := timelinePage{
+		Selected:   make(map[string]bool),
+		MaxRecipes: timeline.MaxRecipes,
+		ServeAt:    r.URL.Query().Get("serve"),
+	}
+	for _, id := range r.URL.Query()["recipe"] {
+		page.Selected[id] = true
+	}
+
+	// Default to two hours from now, on the hour
+	now := time.Now()
+	if page.ServeAt == "" {
+		page.ServeAt = now.Add(2 * time.Hour).Truncate(time.Hour).Format(serveTimeLayout)
+	}
+
+	status := http.StatusOK
+	ids, err := timeline.RecipeIDs(r.URL.Query()["recipe"])
+	if err != nil {
+		if wantJSON {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		status = http.StatusBadRequest
+		page.Error = fmt.Sprintf("Pick at most %d recipes", timeline.MaxRecipes)
+	} else if len(ids) > 0 {
+		plan, code, msg := h.buildTimeline(r, ids, page.ServeAt, now)
+		if wantJSON {
+			if plan == nil {
+				http.Error(w, msg, code)
+				return
+			}
+			writeJSON(w, http.StatusOK, plan)
+			return
+		}
+		page.Plan = plan
+		page.Error = msg
+	} else if wantJSON {
+		http.Error(w, "At least one recipe is required", http.StatusBadRequest)
+		return
+	}
+
+	recipes, err := h.store.ListVisible(r.Context(), auth.CurrentViewer(r))
+	if err != nil {
+		h.log(r).Error("Error listing recipes", slog.Any("error", err))
+		http.Error(w, "Error getting recipes", http.StatusInternalServerError)
+		return
+	}
+	page.Recipes = recipes
+
+	data := handlers.NewTemplateData(r, "timeline", page)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := h.tmpl.Render(w, data); err != nil {
+		h.log(r).Error("Error executing template", slog.Any("error", err))
+	}
+}
+
+// buildTimeline loads the chosen recipes and schedules them
+// On failure it returns a nil plan with the status code and message to show
+func (h *RecipeHandler) buildTimeline(r *http.Request, ids []string, serve string, now time.Time) (*timeline.Plan, int, string) {
+	serveAt, err := time.ParseInLocation(serveTimeLayout, serve, time.Local)
+	if err != nil {
+		h.log(r).Error("Invalid serve time", slog.Any("error", err))
+		return nil, http.StatusBadRequest, "Invalid serve time"
+	}
+
+	recipes := make([]models.Recipe, 0, len(ids))
+	for _, id := range ids {
+		recipe, err := h.getVisible(r, id)
+		if err != nil {
+			h.log(r).Error("Error getting recipe for timeline", slog.Any("error", err))
+			return nil, http.StatusNotFound, "Recipe not found: " + id
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	plan := timeline.Build(recipes, serveAt, now)
+	return &plan, http.StatusOK, ""
+}
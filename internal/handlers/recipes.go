@@ -0,0 +1,24 @@
+// Recipe operations shared by the web pages and the JSON API
+
+package handlers
+
+import (
+	"context"
+	"go_recipe_app/internal/storage"
+	"log/slog"
+)
+
+// DeleteRecipe deletes a recipe and everyone's saved cook progress for it
+// Both the web pages and the API delete through here, so neither leaves progress behind.
+// The recipe is gone once this returns nil; failing to clear the progress is only logged
+func DeleteRecipe(ctx context.Context, recipes storage.ContextRecipeStore, progress storage.CookProgressStore, id string, logger *slog.Logger) error {
+	if err := recipes.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	// Saved cook progress is meaningless without the recipe
+	if err := progress.DeleteRecipeCookProgress(id); err != nil {
+		logger.Warn("Error deleting cook progress", slog.String("id", id), slog.Any("error", err))
+	}
+	return nil
+}
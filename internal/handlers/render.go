@@ -0,0 +1,123 @@
+// Rendering pages from the layout, the shared partials and each page's own template
+
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// Where the templates live, within the templates directory
+const (
+	layoutFile      = "layout.html"
+	partialsPattern = "partials/*.html"
+	pagesPattern    = "pages/*.html"
+)
+
+// Renderer renders pages
+// Each page gets a template set of its own, compiled from the layout, every partial and the
+// page's file, which fills in the layout's blocks:
+//
+//	{{define "head"}}     extra tags for <head>, like the page's stylesheet (optional)
+//	{{define "content"}}  the page itself
+//
+// Two pages can define the same blocks without clashing, and adding a page is just adding
+// its file to the pages directory - pages/view.html is rendered as "view".
+//
+// The sets can be re-parsed while the server runs - requests already rendering keep the old ones.
+// In development the "watch web files" job calls Reload whenever a file changes
+type Renderer struct {
+	files fs.FS
+	funcs template.FuncMap
+	pages atomic.Pointer[map[string]*template.Template]
+}
+
+// NewRenderer parses the templates in files, a templates directory
+// funcs are the functions the templates may call, on top of the helpers in Funcs
+func NewRenderer(files fs.FS, funcs ...template.FuncMap) (*Renderer, error) {
+	t := &Renderer{files: files, funcs: Funcs()}
+	for _, fm := range funcs {
+		for name, fn := range fm {
+			t.funcs[name] = fn
+		}
+	}
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reload re-parses the templates
+// That only picks up changes when they're read from disk, in development mode.
+// If parsing fails the old templates stay in use
+func (t *Renderer) Reload() error {
+	base, err := template.New(layoutFile).Funcs(t.funcs).ParseFS(t.files, layoutFile)
+	if err != nil {
+		return fmt.Errorf("could not parse layout: %v", err)
+	}
+	partials, err := fs.Glob(t.files, partialsPattern)
+	if err != nil {
+		return fmt.Errorf("could not list partials: %v", err)
+	}
+	if len(partials) > 0 {
+		if _, err := base.ParseFS(t.files, partials...); err != nil {
+			return fmt.Errorf("could not parse partials: %v", err)
+		}
+	}
+
+	files, err := fs.Glob(t.files, pagesPattern)
+	if err != nil {
+		return fmt.Errorf("could not list pages: %v", err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no page templates found")
+	}
+	pages := make(map[string]*template.Template, len(files))
+	for _, file := range files {
+		page, err := base.Clone()
+		if err != nil {
+			return fmt.Errorf("could not copy layout: %v", err)
+		}
+		if _, err := page.ParseFS(t.files, file); err != nil {
+			return fmt.Errorf("could not parse page: %v", err)
+		}
+		if page.Lookup("content") == nil {
+			return fmt.Errorf("page %s doesn't define content", file)
+		}
+		pages[strings.TrimSuffix(path.Base(file), ".html")] = page
+	}
+
+	t.pages.Store(&pages)
+	return nil
+}
+
+// Check reports whether the pages are loaded and ready to render
+func (t *Renderer) Check() error {
+	pages := t.pages.Load()
+	if pages == nil || len(*pages) == 0 {
+		return fmt.Errorf("page templates not loaded")
+	}
+	return nil
+}
+
+// Render writes the page named by data.Template, inside the layout
+// The page is rendered in full before anything is written, so a template error
+// leaves w untouched and the caller can still send an error response
+func (t *Renderer) Render(w io.Writer, data TemplateData) error {
+	page, ok := (*t.pages.Load())[data.Template]
+	if !ok {
+		return fmt.Errorf("no page template %q", data.Template)
+	}
+	var buf bytes.Buffer
+	if err := page.ExecuteTemplate(&buf, layoutFile, data); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"go_recipe_app/web"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEmbeddedTemplates(t *testing.T) {
+	files := web.Files("")
+	assets, err := web.NewAssets(files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, err := NewRenderer(web.Templates(files), assets.FuncMap())
+	if err != nil {
+		t.Fatalf("embedded templates don't parse: %v", err)
+	}
+
+	// Every static file a page links must exist, or the page fails to render
+	linked := regexp.MustCompile(`asset "([^"]+)"`)
+	for name, page := range *renderer.pages.Load() {
+		for _, tmpl := range page.Templates() {
+			if tmpl.Tree == nil {
+				continue
+			}
+			for _, m := range linked.FindAllStringSubmatch(tmpl.Tree.Root.String(), -1) {
+				if _, err := assets.Path(m[1]); err != nil {
+					t.Errorf("page %s, template %s: %v", name, tmpl.Name(), err)
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	err = renderer.Render(&b, TemplateData{Template: "error", Data: errorPage{Status: 500, Message: "Something broke"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "<title>Recipe App</title>") || !strings.Contains(b.String(), "Something broke") {
+		t.Errorf("error page = %q, want the layout around the message", b.String())
+	}
+}
+
+func TestRenderer(t *testing.T) {
+	files := fstest.MapFS{
+		"layout.html":            {Data: []byte(`<head>{{block "head" .Data}}{{end}}</head><main>{{template "content" .Data}}</main>`)},
+		"partials/greeting.html": {Data: []byte(`{{define "greeting"}}Hello, {{.}}{{end}}`)},
+		// Both pages define the same blocks, which one shared namespace wouldn't allow
+		"pages/one.html": {Data: []byte(`{{define "head"}}<link href="one.css">{{end}}{{define "content"}}{{template "greeting" .}}{{end}}`)},
+		"pages/two.html": {Data: []byte(`{{define "content"}}{{plural . "step" "steps"}}{{end}}`)},
+	}
+	renderer, err := NewRenderer(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		page string
+		data interface{}
+		want string
+	}{
+		{"one", "cook", `<head><link href="one.css"></head><main>Hello, cook</main>`},
+		{"two", 3, `<head></head><main>3 steps</main>`},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := renderer.Render(&b, TemplateData{Template: tt.page, Data: tt.data}); err != nil {
+			t.Fatalf("%s: %v", tt.page, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s = %q, want %q", tt.page, b.String(), tt.want)
+		}
+	}
+
+	// A failed render writes nothing, so the caller can still send an error
+	var b strings.Builder
+	if err := renderer.Render(&b, TemplateData{Template: "two", Data: "not a number"}); err == nil {
+		t.Error("Render succeeded with bad data")
+	}
+	if b.Len() != 0 {
+		t.Errorf("failed Render wrote %q", b.String())
+	}
+	if err := renderer.Render(&b, TemplateData{Template: "three"}); err == nil {
+		t.Error("Render found a page that doesn't exist")
+	}
+
+	// A page that doesn't parse keeps the old set in use
+	files["pages/two.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{if}}{{end}}`)}
+	if err := renderer.Reload(); err == nil {
+		t.Error("Reload succeeded with a broken page")
+	}
+	if err := renderer.Render(&b, TemplateData{Template: "two", Data: 1}); err != nil || !strings.Contains(b.String(), "1 step") {
+		t.Errorf("after a failed reload: %q, %v", b.String(), err)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	durations := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0 min"},
+		{45 * time.Second, "45 sec"},
+		{5 * time.Minute, "5 min"},
+		{2 * time.Hour, "2 hr"},
+		{90*time.Minute + 20*time.Second, "1 hr 30 min"},
+	}
+	for _, tt := range durations {
+		if got := formatDuration(tt.d); got != tt.want {
+			t.Errorf("duration(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+
+	amounts := []struct {
+		amount float64
+		want   string
+	}{
+		{2, "2"},
+		{0.5, "½"},
+		{1.5, "1½"},
+		{0.33, "⅓"},
+		{2.75, "2¾"},
+		{0.125, "⅛"},
+		{1.2, "1.2"},
+		{0.999, "1"},
+		{3.14159, "3.14"},
+	}
+	for _, tt := range amounts {
+		if got := formatFraction(tt.amount); got != tt.want {
+			t.Errorf("fraction(%v) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		count any
+		want  string
+	}{{1, "1 serving"}, {int32(4), "4 servings"}, {int64(0), "0 servings"}} {
+		if got, err := plural(tt.count, "serving", "servings"); err != nil || got != tt.want {
+			t.Errorf("plural(%v) = %q, %v, want %q", tt.count, got, err, tt.want)
+		}
+	}
+	if _, err := plural("4", "serving", "servings"); err == nil {
+		t.Error("plural accepted a string")
+	}
+}
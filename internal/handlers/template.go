@@ -0,0 +1,52 @@
+// Package handlers holds what every HTTP handler package shares
+package handlers
+
+import (
+	"go_recipe_app/internal/auth"
+	"go_recipe_app/internal/logging"
+	"go_recipe_app/internal/models"
+	"net/http"
+)
+
+// TemplateData is a struct that holds the data for the template
+// This is a common design pattern in Go to pass data to templates
+type TemplateData struct {
+	Template  string // the page to render, the name of its file in the pages directory
+	Data      interface{}
+	User      *models.User // logged in user, nil for visitors
+	CSRFToken string       // anti-forgery token for forms and fetch calls
+}
+
+// NewTemplateData fills in the per-request fields every page needs
+func NewTemplateData(r *http.Request, template string, data interface{}) TemplateData {
+	return TemplateData{
+		Template:  template,
+		Data:      data,
+		User:      auth.CurrentUser(r),
+		CSRFToken: auth.CSRFToken(r),
+	}
+}
+
+// errorPage is the template data for the error page
+type errorPage struct {
+	Status    int
+	Message   string
+	RequestID string // lets a user quote the failure so it can be found in the logs
+}
+
+// ErrorPage returns a handler that renders the error page with the given status
+// The server shows it when a handler panics
+func (t *Renderer) ErrorPage(status int, message string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		data := NewTemplateData(r, "error", errorPage{
+			Status:    status,
+			Message:   message,
+			RequestID: logging.RequestID(r.Context()),
+		})
+		if err := t.Render(w, data); err != nil {
+			logging.FromContext(r.Context()).Error("Error executing template", "error", err)
+		}
+	})
+}
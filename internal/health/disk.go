@@ -0,0 +1,26 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// DiskSpace fails when the filesystem holding path has less than minFree bytes available
+// BoltDB needs room to grow the file, and a full disk corrupts nothing but fails every save
+func DiskSpace(path string, minFree uint64) CheckFunc {
+	dir := filepath.Dir(path)
+	return func(ctx context.Context) error {
+		free, err := freeBytes(dir)
+		if err == errUnsupported {
+			return nil // nothing to check on this platform
+		}
+		if err != nil {
+			return fmt.Errorf("could not check free space in %s: %v", dir, err)
+		}
+		if free < minFree {
+			return fmt.Errorf("only %d MB free in %s, want at least %d MB", free>>20, dir, minFree>>20)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package health
+
+import "errors"
+
+var errUnsupported = errors.New("free space check not supported on this platform")
+
+// freeBytes isn't implemented outside Unix; the disk check passes there
+func freeBytes(dir string) (uint64, error) {
+	return 0, errUnsupported
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package health
+
+import (
+	"errors"
+	"syscall"
+)
+
+var errUnsupported = errors.New("free space check not supported on this platform")
+
+// freeBytes returns the space available to unprivileged users on the filesystem holding dir
+func freeBytes(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
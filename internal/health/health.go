@@ -0,0 +1,160 @@
+// Package health serves the liveness and readiness endpoints for systemd and load balancers
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout bounds each readiness check so a stuck dependency can't hang the probe
+const checkTimeout = 2 * time.Second
+
+// CheckFunc reports a problem with a dependency, or nil if it's fine
+type CheckFunc func(ctx context.Context) error
+
+// Checker runs the readiness checks
+type Checker struct {
+	mu           sync.Mutex
+	names        []string
+	checks       map[string]CheckFunc
+	shuttingDown atomic.Bool
+}
+
+// checkResult is one check as /readyz reports it
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// report is the body of /readyz
+type report struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+// New creates a Checker with no checks
+func New() *Checker {
+	return &Checker{checks: make(map[string]CheckFunc)}
+}
+
+// Add registers a readiness check
+func (c *Checker) Add(name string, check CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.checks[name]; !exists {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// SetShuttingDown makes readiness fail so load balancers stop sending new requests
+func (c *Checker) SetShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Drain fails readiness, then keeps serving for delay before returning
+// Load balancers only notice on their next /readyz poll, so the listeners have to stay
+// open that long or they'd see connection errors instead of a clean 503
+func (c *Checker) Drain(delay time.Duration) {
+	c.SetShuttingDown()
+	time.Sleep(delay)
+}
+
+// Healthz reports that the process is up and serving requests
+// It doesn't look at dependencies - a failing database shouldn't get the process restarted
+func (c *Checker) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz runs every check and reports 200 only if they all pass
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	rep := c.run(r.Context())
+	status := http.StatusOK
+	if rep.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, rep)
+}
+
+// run runs the checks at the same time and collects their results
+func (c *Checker) run(ctx context.Context) report {
+	c.mu.Lock()
+	checks := make(map[string]CheckFunc, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.Unlock()
+
+	rep := report{Status: "ok", Checks: make(map[string]checkResult, len(checks)+1)}
+
+	if c.shuttingDown.Load() {
+		rep.Status = "fail"
+		rep.Checks["shutdown"] = checkResult{Status: "fail", Error: "server is shutting down"}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runCheck(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Checks[name] = result
+			if result.Status != "ok" {
+				rep.Status = "fail"
+			}
+		}()
+	}
+	wg.Wait()
+	return rep
+}
+
+// runCheck runs one check with a timeout, turning panics into failures
+func runCheck(ctx context.Context, check CheckFunc) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", checkTimeout)
+	}
+
+	result := checkResult{
+		Status:    "ok",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		storeErr   error
+		shutdown   bool
+		wantStatus int
+		wantFailed []string
+	}{
+		{name: "all ok", wantStatus: http.StatusOK},
+		{name: "failing check", storeErr: errors.New("closed"), wantStatus: http.StatusServiceUnavailable, wantFailed: []string{"store"}},
+		{name: "shutting down", shutdown: true, wantStatus: http.StatusServiceUnavailable, wantFailed: []string{"shutdown"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.Add("store", func(ctx context.Context) error { return tt.storeErr })
+			c.Add("templates", func(ctx context.Context) error { return nil })
+			if tt.shutdown {
+				c.SetShuttingDown()
+			}
+
+			rec := httptest.NewRecorder()
+			c.Readyz(rec, httptest.NewRequest("GET", "/readyz", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			var rep report
+			if err := json.NewDecoder(rec.Body).Decode(&rep); err != nil {
+				t.Fatalf("decoding body: %v", err)
+			}
+			for _, name := range tt.wantFailed {
+				if rep.Checks[name].Status != "fail" || rep.Checks[name].Error == "" {
+					t.Errorf("check %s = %+v, want a failure with an error", name, rep.Checks[name])
+				}
+			}
+			if rep.Checks["templates"].Status != "ok" {
+				t.Errorf("templates check = %+v, want ok", rep.Checks["templates"])
+			}
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	c := New()
+	c.Add("store", func(ctx context.Context) error { return nil })
+	readyz := func() int {
+		rec := httptest.NewRecorder()
+		c.Readyz(rec, httptest.NewRequest("GET", "/readyz", nil))
+		return rec.Code
+	}
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("before draining: status = %d, want 200", code)
+	}
+
+	// Readiness fails for the whole delay, while the caller is still serving
+	const delay = 200 * time.Millisecond
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		c.Drain(delay)
+		close(done)
+	}()
+	time.Sleep(delay / 4)
+	select {
+	case <-done:
+		t.Fatal("Drain returned before the delay")
+	default:
+	}
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("while draining: status = %d, want 503", code)
+	}
+	<-done
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("Drain returned after %v, want at least %v", elapsed, delay)
+	}
+
+	// No delay just fails readiness
+	c = New()
+	start = time.Now()
+	c.Drain(0)
+	if elapsed := time.Since(start); elapsed > delay {
+		t.Errorf("Drain(0) took %v", elapsed)
+	}
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("after Drain(0): status = %d, want 503", code)
+	}
+}
+
+func TestDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	if err := DiskSpace(dir+"/recipes.db", 0)(context.Background()); err != nil {
+		t.Errorf("with no minimum: %v", err)
+	}
+	if err := DiskSpace(dir+"/recipes.db", 1<<62)(context.Background()); err == nil {
+		t.Error("expected an error when the minimum is larger than any disk")
+	}
+}
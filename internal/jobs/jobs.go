@@ -0,0 +1,123 @@
+// Package jobs runs background work, such as clearing out expired sessions, on a schedule
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Job is a piece of work that runs every Interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Runner runs jobs in their own goroutines until it is stopped
+type Runner struct {
+	logger *slog.Logger
+	jobs   []Job
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running bool
+}
+
+// New creates a new Runner
+func New(logger *slog.Logger) *Runner {
+	return &Runner{logger: logger}
+}
+
+// Add registers a job; it must be called before Start
+func (r *Runner) Add(job Job) {
+	r.jobs = append(r.jobs, job)
+}
+
+// Start runs every job once straight away and then on its interval
+func (r *Runner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.running = true
+	for _, job := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, job)
+	}
+	r.logger.Info("background jobs started", "count", len(r.jobs))
+}
+
+// Stop cancels the jobs and waits for any that are mid-run to finish
+// It gives up waiting when ctx is done
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.running {
+		r.mu.Unlock()
+		return nil
+	}
+	r.cancel()
+	r.running = false
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.logger.Info("background jobs stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background jobs did not stop: %v", ctx.Err())
+	}
+}
+
+// Running reports whether the jobs have been started and not stopped
+func (r *Runner) Running() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running
+}
+
+func (r *Runner) loop(ctx context.Context, job Job) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.runOnce(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce runs a job, logging failures
+// A panicking job is logged and tried again next time rather than taking down the server
+func (r *Runner) runOnce(ctx context.Context, job Job) {
+	defer func() {
+		if p := recover(); p != nil {
+			r.logger.Error("background job panicked", "job", job.Name, "panic", p, "stack", string(debug.Stack()))
+		}
+	}()
+
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		r.logger.Error("background job failed", "job", job.Name, "error", err)
+		return
+	}
+	r.logger.Debug("background job finished", "job", job.Name, "duration", time.Since(start))
+}
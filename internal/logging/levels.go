@@ -0,0 +1,240 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Components that can have their own log level
+// Loggers join one with logger.With("component", name)
+var Components = []string{"http", "storage", "search", "jobs"}
+
+// Levels holds the minimum log level, overall and per component
+// Every logger made by NewLogger checks it on each call, so changes apply straight away
+type Levels struct {
+	base slog.LevelVar
+
+	mu         sync.RWMutex
+	components map[string]slog.Level // overrides; components not listed use base
+}
+
+// NewLevels starts with level for everything and no overrides
+func NewLevels(level slog.Level) *Levels {
+	l := &Levels{components: make(map[string]slog.Level)}
+	l.base.Set(level)
+	return l
+}
+
+// ParseLevel reads a level name: debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, use debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// Base returns the level used by components without an override
+func (l *Levels) Base() slog.Level {
+	return l.base.Level()
+}
+
+// SetBase changes the level for components without an override
+func (l *Levels) SetBase(level slog.Level) {
+	l.base.Set(level)
+}
+
+// Set overrides the level for one component
+func (l *Levels) Set(component string, level slog.Level) error {
+	if !knownComponent(component) {
+		return fmt.Errorf("unknown component %q, use one of %s", component, strings.Join(Components, ", "))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.components[component] = level
+	return nil
+}
+
+// Reset removes a component's override so it follows the base level again
+func (l *Levels) Reset(component string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.components, component)
+}
+
+// Level returns the level in force for a component; "" means the base level
+func (l *Levels) Level(component string) slog.Level {
+	if component != "" {
+		l.mu.RLock()
+		level, ok := l.components[component]
+		l.mu.RUnlock()
+		if ok {
+			return level
+		}
+	}
+	return l.base.Level()
+}
+
+// Apply sets the base level and replaces the overrides, e.g. from the config
+// overrides is a list like "storage=debug,http=warn"
+func (l *Levels) Apply(base string, overrides []string) error {
+	baseLevel, err := ParseLevel(base)
+	if err != nil {
+		return err
+	}
+	parsed := make(map[string]slog.Level, len(overrides))
+	for _, item := range overrides {
+		component, name, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("log level override %q should look like component=level", item)
+		}
+		component = strings.TrimSpace(component)
+		if !knownComponent(component) {
+			return fmt.Errorf("unknown component %q, use one of %s", component, strings.Join(Components, ", "))
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return err
+		}
+		parsed[component] = level
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.base.Set(baseLevel)
+	l.components = parsed
+	return nil
+}
+
+// levelsView is the JSON form of the levels
+type levelsView struct {
+	Base       string            `json:"base"`
+	Components map[string]string `json:"components"` // the level in force for each component
+	Overridden []string          `json:"overridden"` // components with their own level
+}
+
+func (l *Levels) view() levelsView {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	v := levelsView{
+		Base:       strings.ToLower(l.base.Level().String()),
+		Components: make(map[string]string, len(Components)),
+		Overridden: []string{},
+	}
+	for _, c := range Components {
+		level, ok := l.components[c]
+		if ok {
+			v.Overridden = append(v.Overridden, c)
+		} else {
+			level = l.base.Level()
+		}
+		v.Components[c] = strings.ToLower(level.String())
+	}
+	sort.Strings(v.Overridden)
+	return v
+}
+
+// levelChange is the body of a PUT to the levels handler
+// An empty component changes the base level; an empty level removes the component's override
+type levelChange struct {
+	Component string `json:"component"`
+	Level     string `json:"level"`
+}
+
+// Handler shows the levels on GET and changes one on PUT
+// Mount it behind authentication - it can make the logs very noisy
+func (l *Levels) Handler(logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var change levelChange
+			if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+				writeLevelsError(w, "invalid JSON, send {\"component\": \"storage\", \"level\": \"debug\"}")
+				return
+			}
+			if err := l.change(change); err != nil {
+				writeLevelsError(w, err.Error())
+				return
+			}
+			FromContextOr(r.Context(), logger).Info("log level changed",
+				slog.String("target", change.Component), slog.String("level", change.Level))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(l.view())
+	})
+}
+
+func (l *Levels) change(c levelChange) error {
+	if c.Component == "" {
+		level, err := ParseLevel(c.Level)
+		if err != nil {
+			return err
+		}
+		l.SetBase(level)
+		return nil
+	}
+	if c.Level == "" {
+		if !knownComponent(c.Component) {
+			return fmt.Errorf("unknown component %q, use one of %s", c.Component, strings.Join(Components, ", "))
+		}
+		l.Reset(c.Component)
+		return nil
+	}
+	level, err := ParseLevel(c.Level)
+	if err != nil {
+		return err
+	}
+	return l.Set(c.Component, level)
+}
+
+func writeLevelsError(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func knownComponent(name string) bool {
+	for _, c := range Components {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// levelHandler filters records by the level of the component the logger belongs to
+// The component comes from logger.With("component", ...), which is when WithAttrs sees it
+type levelHandler struct {
+	next      slog.Handler
+	levels    *Levels
+	component string
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.component) && h.next.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	for _, a := range attrs {
+		if a.Key == "component" {
+			component = a.Value.String()
+		}
+	}
+	return &levelHandler{next: h.next.WithAttrs(attrs), levels: h.levels, component: component}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{next: h.next.WithGroup(name), levels: h.levels, component: h.component}
+}
@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	levels := NewLevels(slog.LevelInfo)
+	logger := slog.New(&levelHandler{
+		next:   slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		levels: levels,
+	})
+	storage := logger.With("component", "storage")
+
+	logger.Debug("app debug")
+	storage.Debug("storage debug")
+	if buf.Len() != 0 {
+		t.Fatalf("debug logged at info level: %s", buf.String())
+	}
+
+	// Turning one component up leaves the rest alone, and applies to loggers made earlier
+	if err := levels.Set("storage", slog.LevelDebug); err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("app debug")
+	storage.Debug("storage debug")
+	if out := buf.String(); strings.Contains(out, "app debug") || !strings.Contains(out, "storage debug") {
+		t.Fatalf("got %q, want only the storage line", out)
+	}
+
+	if err := levels.Apply("warn", []string{"jobs=debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if levels.Level("storage") != slog.LevelWarn || levels.Level("jobs") != slog.LevelDebug {
+		t.Fatalf("after Apply storage=%v jobs=%v", levels.Level("storage"), levels.Level("jobs"))
+	}
+
+	for _, bad := range [][]string{{"jobs"}, {"nope=debug"}, {"jobs=loud"}} {
+		if err := levels.Apply("info", bad); err == nil {
+			t.Errorf("Apply(%q) succeeded, want an error", bad)
+		}
+	}
+}
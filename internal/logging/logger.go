@@ -1,59 +1,139 @@
 package logging
 
 import (
+	"context"
+	"errors"
 	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
 )
 
 type LogConfig struct {
-	Level   string
-	Format  string // json or text
-	LogPath string
+	Level        string  // used when Levels is nil
+	Levels       *Levels // levels that can change while the app runs
+	Format       string  // json or text
+	LogPath      string
+	ErrorLogPath string // errors are also written here; empty turns it off
+	Rotate       RotateConfig
+	Redact       RedactConfig // secrets to mask and how long values may be
 }
 
-func NewLogger(cfg LogConfig) (*slog.Logger, error) {
-	// Create log directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(cfg.LogPath), 0755); err != nil {
-		return nil, err
+// Files are the log files behind a logger
+type Files struct {
+	files []*RotatingFile
+}
+
+// Reopen reopens every log file, for use after logrotate has moved them
+func (f *Files) Reopen() error {
+	var errs []error
+	for _, file := range f.files {
+		errs = append(errs, file.Reopen())
 	}
+	return errors.Join(errs...)
+}
+
+// Close closes every log file
+func (f *Files) Close() error {
+	var errs []error
+	for _, file := range f.files {
+		errs = append(errs, file.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func NewLogger(cfg LogConfig) (*slog.Logger, *Files, error) {
+	files := &Files{}
 
-	// Open log file
-	f, err := os.OpenFile(cfg.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// Open log file; it creates the log directory if it doesn't exist
+	f, err := OpenRotatingFile(cfg.LogPath, cfg.Rotate)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	files.files = append(files.files, f)
 
 	// Create multi-writer for both file and stdout
 	mw := io.MultiWriter(os.Stdout, f)
 
-	// Set log level
-	var level slog.Level
-	switch cfg.Level {
-	case "debug":
-		level = slog.LevelDebug
-	case "info":
-		level = slog.LevelInfo
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo
+	// Set log level; unknown names fall back to info
+	levels := cfg.Levels
+	if levels == nil {
+		level, err := ParseLevel(cfg.Level)
+		if err != nil {
+			level = slog.LevelInfo
+		}
+		levels = NewLevels(level)
 	}
 
 	// Configure handler
-	var handler slog.Handler
-	if cfg.Format == "json" {
-		handler = slog.NewJSONHandler(mw, &slog.HandlerOptions{
-			Level: level,
-		})
-	} else {
-		handler = slog.NewTextHandler(mw, &slog.HandlerOptions{
+	// It lets everything through and the level handler in front decides, so levels can change at runtime
+	var handler slog.Handler = &levelHandler{
+		next:   newHandler(cfg.Format, mw, slog.LevelDebug),
+		levels: levels,
+	}
+
+	// Errors also go to their own file, so they're easy to find among everything else
+	if cfg.ErrorLogPath != "" {
+		ef, err := OpenRotatingFile(cfg.ErrorLogPath, cfg.Rotate)
+		if err != nil {
+			files.Close()
+			return nil, nil, err
+		}
+		files.files = append(files.files, ef)
+		// error.log always gets errors, whatever the levels are set to
+		handler = teeHandler{handler, newHandler(cfg.Format, ef, slog.LevelError)}
+	}
+
+	// Secrets are masked before anything is written, to every file
+	handler = NewRedactingHandler(handler, cfg.Redact)
+
+	return slog.New(handler), files, nil
+}
+
+func newHandler(format string, w io.Writer, level slog.Level) slog.Handler {
+	if format == "json" {
+		return slog.NewJSONHandler(w, &slog.HandlerOptions{
 			Level: level,
 		})
 	}
+	return slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+	})
+}
+
+// teeHandler sends each record to every handler that wants it
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	return slog.New(handler), nil
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
 }
@@ -0,0 +1,171 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redacted replaces the value of a masked key
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys are masked when no keys are configured
+var DefaultRedactKeys = []string{"password", "token", "cookie", "authorization"}
+
+// RedactConfig says what the redacting handler hides
+type RedactConfig struct {
+	// Keys to mask, case-insensitively. A key matches when it equals one of these or ends
+	// with it after a "_", "-" or ".", so "password" also masks "new_password", but
+	// "token" leaves "token_id" alone. Empty means DefaultRedactKeys
+	Keys []string
+
+	// MaxValueLen cuts string values longer than this many bytes; 0 means no limit
+	MaxValueLen int
+}
+
+// redactHandler masks secrets and trims long values before records reach next
+// Forms, query strings and headers logged whole (url.Values, http.Header) are masked key by key,
+// so logs can be attached to bug reports without leaking anyone's password
+type redactHandler struct {
+	next   slog.Handler
+	keys   []string
+	maxLen int
+}
+
+// NewRedactingHandler wraps next so masked keys and long values never reach it
+func NewRedactingHandler(next slog.Handler, cfg RedactConfig) slog.Handler {
+	keys := cfg.Keys
+	if len(keys) == 0 {
+		keys = DefaultRedactKeys
+	}
+	h := &redactHandler{next: next, maxLen: cfg.MaxValueLen}
+	for _, k := range keys {
+		h.keys = append(h.keys, strings.ToLower(k))
+	}
+	return h
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = h.redact(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(clean), keys: h.keys, maxLen: h.maxLen}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), keys: h.keys, maxLen: h.maxLen}
+}
+
+// masked reports whether values under key should be hidden
+func (h *redactHandler) masked(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range h.keys {
+		if key == k {
+			return true
+		}
+		if strings.HasSuffix(key, k) {
+			switch key[len(key)-len(k)-1] {
+			case '_', '-', '.':
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redact returns a with its value masked or trimmed as needed, looking inside groups and forms
+func (h *redactHandler) redact(a slog.Attr) slog.Attr {
+	if h.masked(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.truncate(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		clean := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			clean[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(clean...)}
+	case slog.KindAny:
+		switch m := v.Any().(type) {
+		case url.Values:
+			return h.redactMap(a.Key, m)
+		case http.Header:
+			return h.redactMap(a.Key, m)
+		case map[string][]string:
+			return h.redactMap(a.Key, m)
+		case map[string]string:
+			multi := make(map[string][]string, len(m))
+			for k, s := range m {
+				multi[k] = []string{s}
+			}
+			return h.redactMap(a.Key, multi)
+		case error:
+			return slog.String(a.Key, h.truncate(m.Error()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// redactMap logs a form or header as a group, one attribute per key, in key order
+func (h *redactHandler) redactMap(key string, m map[string][]string) slog.Attr {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]slog.Attr, 0, len(names))
+	for _, name := range names {
+		if h.masked(name) {
+			attrs = append(attrs, slog.String(name, Redacted))
+			continue
+		}
+		values := m[name]
+		if len(values) == 1 {
+			attrs = append(attrs, slog.String(name, h.truncate(values[0])))
+			continue
+		}
+		clean := make([]string, len(values))
+		for i, s := range values {
+			clean[i] = h.truncate(s)
+		}
+		attrs = append(attrs, slog.Any(name, clean))
+	}
+	return slog.Attr{Key: key, Value: slog.GroupValue(attrs...)}
+}
+
+// truncate cuts s to the maximum length, on a character boundary, and says how much was cut
+func (h *redactHandler) truncate(s string) string {
+	if h.maxLen <= 0 || len(s) <= h.maxLen {
+		return s
+	}
+	cut := h.maxLen
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s…[%d bytes truncated]", s[:cut], len(s)-cut)
+}
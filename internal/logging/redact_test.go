@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactingHandler(slog.NewTextHandler(&buf, nil), RedactConfig{MaxValueLen: 10}))
+
+	form := url.Values{
+		"title":            {"Pancakes"},
+		"password":         {"hunter2"},
+		"confirm_password": {"hunter2"},
+		"csrf_token":       {"abc123"},
+	}
+	header := http.Header{"Authorization": {"Bearer rcp_secret"}, "Cookie": {"session=xyz"}}
+	logger.With("token", "rcp_other").Info("form",
+		slog.Any("form", form),
+		slog.Any("header", header),
+		slog.String("token_id", "tok-1"),
+		slog.Group("user", slog.String("new_password", "swordfish")),
+		slog.String("notes", "a very long value indeed"),
+		slog.Any("error", errors.New("password is hunter2 and this message is long")),
+	)
+	out := buf.String()
+
+	for _, secret := range []string{"hunter2", "abc123", "rcp_secret", "session=xyz", "rcp_other", "swordfish"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log line leaks %q: %s", secret, out)
+		}
+	}
+	for _, want := range []string{"form.title=Pancakes", "token_id=tok-1", `notes="a very lon…[14 bytes truncated]"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log line is missing %s: %s", want, out)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat stamps rotated files; it sorts in time order and is safe in file names
+const rotatedTimeFormat = "20060102T150405.000"
+
+// RotateConfig says when a log file is rotated and how many old ones are kept
+type RotateConfig struct {
+	MaxSize  int64         // rotate before the file grows past this many bytes; 0 means no limit
+	MaxAge   time.Duration // rotate once the file has been written to for this long; 0 means no limit
+	MaxFiles int           // rotated files to keep; older ones are deleted. 0 keeps them all
+	Compress bool          // gzip rotated files
+}
+
+// RotatingFile is a log file that rotates itself
+// Rotated files sit next to it as name-<time>.log, or name-<time>.log.gz once compressed.
+// Compression and clean up happen in the background so logging never waits on them
+type RotatingFile struct {
+	path string
+	cfg  RotateConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	mill     chan struct{} // wakes the background compressor
+	millDone chan struct{}
+}
+
+// OpenRotatingFile opens path for appending, creating it and its directory if needed
+func OpenRotatingFile(path string, cfg RotateConfig) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("could not create log directory: %v", err)
+	}
+
+	f := &RotatingFile{
+		path:     path,
+		cfg:      cfg,
+		mill:     make(chan struct{}, 1),
+		millDone: make(chan struct{}),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	go f.runMill()
+	f.wakeMill() // tidy up anything a previous run left behind
+	return f, nil
+}
+
+// open opens the file at f.path, picking up its size so rotation counts what's already there
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("could not stat log file: %v", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+	if f.size > 0 {
+		// Age counts from when the file was started, as near as we can tell
+		f.opened = info.ModTime()
+	}
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past its limits
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			// Keep logging to the current file rather than lose the line
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// due reports whether writing n more bytes calls for a rotation
+func (f *RotatingFile) due(n int64) bool {
+	if f.cfg.MaxSize > 0 && f.size+n > f.cfg.MaxSize {
+		return true
+	}
+	return f.cfg.MaxAge > 0 && time.Since(f.opened) >= f.cfg.MaxAge
+}
+
+// rotate renames the current file aside and starts a new one
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	rotated := f.rotatedName(time.Now())
+	if err := os.Rename(f.path, rotated); err != nil {
+		// Reopen so writes continue, even though the file didn't move
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.wakeMill()
+	return nil
+}
+
+// rotatedName is where the current file goes when it's rotated at t
+// Two rotations in the same millisecond would collide, so the later one moves up a millisecond
+func (f *RotatingFile) rotatedName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext)
+	for {
+		name := fmt.Sprintf("%s-%s%s", base, t.Format(rotatedTimeFormat), ext)
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// Rotate rotates the file now, whatever its size or age
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Reopen closes the file and opens whatever is at the path now
+// External tools like logrotate move the file away and then signal the app to reopen it
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	return f.open()
+}
+
+// Close closes the file and waits for background compression to finish
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	close(f.mill)
+	<-f.millDone
+	return err
+}
+
+func (f *RotatingFile) wakeMill() {
+	select {
+	case f.mill <- struct{}{}:
+	default: // a run is already queued and will see this rotation too
+	}
+}
+
+// runMill compresses rotated files and deletes the oldest past MaxFiles
+func (f *RotatingFile) runMill() {
+	defer close(f.millDone)
+	for range f.mill {
+		if err := f.millOnce(); err != nil {
+			fmt.Fprintf(os.Stderr, "log clean up failed: %v\n", err)
+		}
+	}
+}
+
+func (f *RotatingFile) millOnce() error {
+	rotated, err := f.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	// Oldest first, so the ones past the limit are at the front
+	if f.cfg.MaxFiles > 0 && len(rotated) > f.cfg.MaxFiles {
+		for _, name := range rotated[:len(rotated)-f.cfg.MaxFiles] {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		rotated = rotated[len(rotated)-f.cfg.MaxFiles:]
+	}
+
+	if f.cfg.Compress {
+		for _, name := range rotated {
+			if !strings.HasSuffix(name, ".gz") {
+				if err := compressFile(name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// rotatedFiles lists the rotated copies of the file, oldest first
+func (f *RotatingFile) rotatedFiles() ([]string, error) {
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext)
+
+	var names []string
+	for _, pattern := range []string{base + "-*" + ext, base + "-*" + ext + ".gz"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matches {
+			stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+			stamp = strings.TrimPrefix(stamp, base+"-")
+			if _, err := time.Parse(rotatedTimeFormat, stamp); err == nil {
+				names = append(names, name)
+			}
+		}
+	}
+
+	// The timestamps sort in time order; compare without .gz so both forms interleave correctly
+	sort.Slice(names, func(i, j int) bool {
+		return strings.TrimSuffix(names[i], ".gz") < strings.TrimSuffix(names[j], ".gz")
+	})
+	return names, nil
+}
+
+// compressFile gzips name to name.gz and removes the original
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Write to a temporary name so a crash never leaves a truncated .gz that looks finished
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, name+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs") // opening creates it
+	path := filepath.Join(dir, "app.log")
+
+	f, err := OpenRotatingFile(path, RotateConfig{MaxSize: 10, MaxFiles: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each line fills the file, so every write after the first rotates
+	for _, line := range []string{"first-\n", "second\n", "third-\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil { // waits for compression
+		t.Fatal(err)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil || string(current) != "fourth\n" {
+		t.Fatalf("current file = %q, %v; want the last line", current, err)
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "app-*.log.gz"))
+	if len(rotated) != 2 {
+		t.Fatalf("kept %d rotated files, want 2: %v", len(rotated), rotated)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "app-*.log")); len(leftovers) != 0 {
+		t.Errorf("uncompressed rotated files left behind: %v", leftovers)
+	}
+
+	// The oldest was deleted; the newest rotated file holds the third line
+	if got := gunzip(t, rotated[1]); got != "third-\n" {
+		t.Errorf("newest rotated file = %q, want the third line", got)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	f, err := OpenRotatingFile(path, RotateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("before\n"))
+
+	// What logrotate does: move the file, then tell the app
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("after\n"))
+
+	moved, _ := os.ReadFile(path + ".1")
+	current, _ := os.ReadFile(path)
+	if string(moved) != "before\n" || string(current) != "after\n" {
+		t.Errorf("moved = %q, current = %q", moved, current)
+	}
+}
+
+func TestErrorLog(t *testing.T) {
+	dir := t.TempDir()
+	logger, files, err := NewLogger(LogConfig{
+		Level:        "info",
+		LogPath:      filepath.Join(dir, "app.log"),
+		ErrorLogPath: filepath.Join(dir, "error.log"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("all is well")
+	logger.With("component", "test").Error("something broke")
+	files.Close()
+
+	errors, _ := os.ReadFile(filepath.Join(dir, "error.log"))
+	if strings.Contains(string(errors), "all is well") || !strings.Contains(string(errors), "component=test") {
+		t.Errorf("error.log = %q, want only the error with its attributes", errors)
+	}
+	all, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(all), "all is well") || !strings.Contains(string(all), "something broke") {
+		t.Errorf("app.log = %q, want both lines", all)
+	}
+}
+
+func gunzip(t *testing.T, name string) string {
+	t.Helper()
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// HTTP records request counts and latencies per route
+type HTTP struct {
+	requests *CounterVec
+	latency  *HistogramVec
+}
+
+// NewHTTP creates and registers the HTTP metrics
+func NewHTTP(r *Registry) *HTTP {
+	return &HTTP{
+		requests: r.NewCounterVec("recipe_http_requests_total",
+			"HTTP requests handled, by route template, method and status code.",
+			"route", "method", "status"),
+		latency: r.NewHistogramVec("recipe_http_request_duration_seconds",
+			"Time taken to handle HTTP requests, by route template and method.",
+			DefaultBuckets, "route", "method"),
+	}
+}
+
+// Observe records one finished request; route should be the route template, not the path
+func (h *HTTP) Observe(method, route string, status int, latency time.Duration) {
+	h.requests.Inc(route, method, strconv.Itoa(status))
+	h.latency.Observe(latency.Seconds(), route, method)
+}
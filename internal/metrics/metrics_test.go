@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("test_requests_total", "Requests.", "route", "status")
+	latency := reg.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	reg.Register(CollectorFunc(func(w *Writer) {
+		w.Gauge("test_info", "Line one\nline two.", 1, Label{"version", `go "1"\x`})
+	}))
+
+	requests.Inc("/recipes/{id}", "200")
+	requests.Add(2, "/recipes", "200")
+	latency.Observe(0.05, "/recipes")
+	latency.Observe(0.5, "/recipes")
+	latency.Observe(3, "/recipes")
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+
+	want := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{route="/recipes",status="200"} 2
+test_requests_total{route="/recipes/{id}",status="200"} 1
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/recipes",le="0.1"} 1
+test_latency_seconds_bucket{route="/recipes",le="1"} 2
+test_latency_seconds_bucket{route="/recipes",le="+Inf"} 3
+test_latency_seconds_sum{route="/recipes"} 3.55
+test_latency_seconds_count{route="/recipes"} 3
+# HELP test_info Line one\nline two.
+# TYPE test_info gauge
+test_info{version="go \"1\"\\x"} 1
+`
+	if b.String() != want {
+		t.Errorf("Got:\n%s\nWant:\n%s", b.String(), want)
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	reg := NewRegistry()
+	handler := reg.Handler("secret")
+
+	for _, tt := range []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("Authorization %q: got %d, want %d", tt.auth, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package metrics keeps counters and histograms and serves them in the Prometheus text format
+// It is deliberately small - just what the app needs, without pulling in the Prometheus client
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector writes one or more metric families when the registry is scraped
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// Registry holds every collector and writes them out on each scrape
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a collector; metrics are written in the order they were registered
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo writes every metric in the text exposition format
+func (r *Registry) WriteTo(out io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	w := &Writer{w: bufio.NewWriter(out)}
+	for _, c := range collectors {
+		c.Collect(w)
+	}
+	if err := w.w.Flush(); err != nil {
+		return w.n, err
+	}
+	return w.n, w.err
+}
+
+// Handler serves the metrics for Prometheus to scrape
+// If token is set, scrapers must send it as "Authorization: Bearer <token>"
+func (r *Registry) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if token != "" && !validBearer(req, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		r.WriteTo(w)
+	})
+}
+
+// Label is a name="value" pair on a sample
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Writer formats metric families and samples
+// Errors are sticky: after the first failed write the rest are skipped
+type Writer struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+// Family writes the HELP and TYPE lines that start a metric family
+// typ is "counter", "gauge" or "histogram"
+func (w *Writer) Family(name, help, typ string) {
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// Sample writes one value of a family
+func (w *Writer) Sample(name string, value float64, labels ...Label) {
+	w.printf("%s%s %s\n", name, formatLabels(labels), formatValue(value))
+}
+
+// Gauge writes a single-sample gauge family
+func (w *Writer) Gauge(name, help string, value float64, labels ...Label) {
+	w.Family(name, help, "gauge")
+	w.Sample(name, value, labels...)
+}
+
+// Counter writes a single-sample counter family
+func (w *Writer) Counter(name, help string, value float64, labels ...Label) {
+	w.Family(name, help, "counter")
+	w.Sample(name, value, labels...)
+}
+
+func (w *Writer) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// sortedKeys returns a map's keys in order, so scrapes list series consistently
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validBearer compares the request's bearer token with the expected one in constant time
+func validBearer(r *http.Request, token string) bool {
+	header := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return false
+	}
+	return constantTimeEqual(header[len(prefix):], token)
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// Runtime reports Go runtime and process metrics using the names the Prometheus client uses,
+// so existing Go dashboards work unchanged
+func Runtime() Collector {
+	started := time.Now()
+
+	return CollectorFunc(func(w *Writer) {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+
+		w.Gauge("go_info", "Information about the Go environment.", 1, Label{"version", runtime.Version()})
+		w.Gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+		w.Gauge("go_threads", "Number of OS threads created.", float64(pprof.Lookup("threadcreate").Count()))
+		w.Gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(m.Alloc))
+		w.Counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(m.TotalAlloc))
+		w.Gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(m.Sys))
+		w.Gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(m.HeapInuse))
+		w.Gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(m.HeapObjects))
+		w.Counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(m.NumGC))
+		w.Counter("go_gc_pause_seconds_total", "Total time spent in GC stop-the-world pauses.", float64(m.PauseTotalNs)/1e9)
+		w.Gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(started.Unix()))
+	})
+}
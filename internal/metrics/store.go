@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"time"
+)
+
+// Store wraps a ContextRecipeStore, timing every call and counting the ones that fail
+type Store struct {
+	next    storage.ContextRecipeStore
+	latency *HistogramVec
+	errors  *CounterVec
+}
+
+// NewStore wraps next and registers its metrics
+func NewStore(r *Registry, next storage.ContextRecipeStore) *Store {
+	return &Store{
+		next: next,
+		latency: r.NewHistogramVec("recipe_store_operation_duration_seconds",
+			"Time taken by recipe store operations, by method.",
+			DefaultBuckets, "method"),
+		errors: r.NewCounterVec("recipe_store_errors_total",
+			"Recipe store operations that returned an error, by method.",
+			"method"),
+	}
+}
+
+// observe records a call that started at start and returned err
+func (s *Store) observe(method string, start time.Time, err error) {
+	s.latency.Observe(time.Since(start).Seconds(), method)
+	if err != nil {
+		s.errors.Inc(method)
+	}
+}
+
+func (s *Store) List(ctx context.Context) ([]models.Recipe, error) {
+	start := time.Now()
+	recipes, err := s.next.List(ctx)
+	s.observe("List", start, err)
+	return recipes, err
+}
+
+func (s *Store) ListVisible(ctx context.Context, viewer models.Viewer) ([]models.Recipe, error) {
+	start := time.Now()
+	recipes, err := s.next.ListVisible(ctx, viewer)
+	s.observe("ListVisible", start, err)
+	return recipes, err
+}
+
+func (s *Store) Get(ctx context.Context, id string) (models.Recipe, error) {
+	start := time.Now()
+	recipe, err := s.next.Get(ctx, id)
+	s.observe("Get", start, err)
+	return recipe, err
+}
+
+func (s *Store) Create(ctx context.Context, recipe models.Recipe) error {
+	start := time.Now()
+	err := s.next.Create(ctx, recipe)
+	s.observe("Create", start, err)
+	return err
+}
+
+func (s *Store) Update(ctx context.Context, recipe models.Recipe) error {
+	start := time.Now()
+	err := s.next.Update(ctx, recipe)
+	s.observe("Update", start, err)
+	return err
+}
+
+func (s *Store) Delete(ctx context.Context, id string) error {
+	start := time.Now()
+	err := s.next.Delete(ctx, id)
+	s.observe("Delete", start, err)
+	return err
+}
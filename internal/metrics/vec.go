@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit request and store latencies, in seconds (5ms to 10s)
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// labelKey joins label values into a map key
+// The NUL separator sorts before any printable character, so series sort by their first label first
+func labelKey(values []string) string {
+	return strings.Join(values, "\x00")
+}
+
+func labelPairs(names, values []string) []Label {
+	labels := make([]Label, len(names))
+	for i := range names {
+		labels[i] = Label{Name: names[i], Value: values[i]}
+	}
+	return labels
+}
+
+// CounterVec is a counter split by label values, e.g. requests by route and status
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	keys   map[string][]string
+}
+
+// NewCounterVec creates a CounterVec and registers it
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+		keys:   make(map[string][]string),
+	}
+	r.Register(c)
+	return c
+}
+
+// Add increases the counter for the given label values
+// It panics if the number of values doesn't match the labels - that's a programming error
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if len(values) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", c.name, len(c.labels), len(values)))
+	}
+	key := labelKey(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.keys[key]; !ok {
+		c.keys[key] = append([]string(nil), values...)
+	}
+	c.values[key] += delta
+}
+
+// Inc adds one to the counter for the given label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Collect implements Collector
+func (c *CounterVec) Collect(w *Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w.Family(c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		w.Sample(c.name, c.values[key], labelPairs(c.labels, c.keys[key])...)
+	}
+}
+
+// histogram is one series of a HistogramVec
+type histogram struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a histogram split by label values, e.g. latency by route
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+// NewHistogramVec creates a HistogramVec and registers it
+// buckets are upper bounds in increasing order; +Inf is added automatically
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+	r.Register(h)
+	return h
+}
+
+// Observe records a value (usually seconds) for the given label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	if len(values) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s wants %d label values, got %d", h.name, len(h.labels), len(values)))
+	}
+	key := labelKey(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Collect implements Collector
+func (h *HistogramVec) Collect(w *Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w.Family(h.name, h.help, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		labels := labelPairs(h.labels, s.values)
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			w.Sample(h.name+"_bucket", float64(cumulative), append(labels, Label{"le", formatValue(upper)})...)
+		}
+		w.Sample(h.name+"_bucket", float64(s.count), append(labels, Label{"le", "+Inf"})...)
+		w.Sample(h.name+"_sum", s.sum, labels...)
+		w.Sample(h.name+"_count", float64(s.count), labels...)
+	}
+}
+
+// CollectorFunc turns a function into a Collector, for values read at scrape time
+type CollectorFunc func(w *Writer)
+
+// Collect implements Collector
+func (f CollectorFunc) Collect(w *Writer) {
+	f(w)
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,104 @@
+// Audit trail of changes to recipes
+
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditAction is what was done to a recipe
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore" // a deleted recipe brought back from its audit entry
+)
+
+// AuditActions lists every action in the order the UI shows them
+var AuditActions = []AuditAction{AuditCreate, AuditUpdate, AuditDelete, AuditRestore}
+
+// FieldChange is one field an update changed, shown as before and after
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// AuditEntry records one change to a recipe
+// Entries are only ever appended; IDs increase, so they sort oldest first
+type AuditEntry struct {
+	ID          string        `json:"id"`
+	Time        time.Time     `json:"time"`
+	Action      AuditAction   `json:"action"`
+	RecipeID    string        `json:"recipe_id"`
+	RecipeTitle string        `json:"recipe_title"`
+	UserID      string        `json:"user_id,omitempty"` // empty when nobody was logged in
+	Username    string        `json:"username,omitempty"`
+	IP          string        `json:"ip,omitempty"`
+	Changes     []FieldChange `json:"changes,omitempty"`  // updates only
+	Snapshot    *Recipe       `json:"snapshot,omitempty"` // deletes only: the recipe as it was, so it can be restored
+}
+
+// maxChangeLength keeps change summaries readable when a long description is edited
+const maxChangeLength = 200
+
+// DiffRecipes lists the fields that differ between old and updated
+func DiffRecipes(old, updated Recipe) []FieldChange {
+	var changes []FieldChange
+	add := func(field, a, b string) {
+		if a != b {
+			changes = append(changes, FieldChange{Field: field, Old: shorten(a), New: shorten(b)})
+		}
+	}
+
+	add("title", old.Title, updated.Title)
+	add("description", old.Description, updated.Description)
+	add("prep_time", old.PrepTime.String(), updated.PrepTime.String())
+	add("cook_time", old.CookTime.String(), updated.CookTime.String())
+	add("servings", strconv.Itoa(int(old.Servings)), strconv.Itoa(int(updated.Servings)))
+	add("visibility", string(old.Visibility), string(updated.Visibility))
+	add("group_id", old.GroupID, updated.GroupID)
+	add("owner_id", old.OwnerID, updated.OwnerID)
+	add("ingredients", ingredientSummary(old.Ingredients), ingredientSummary(updated.Ingredients))
+	add("instructions", instructionSummary(old.Instructions), instructionSummary(updated.Instructions))
+	return changes
+}
+
+// ingredientSummary lists ingredients as "2 cup flour; 1 egg"
+func ingredientSummary(ingredients []Ingredient) string {
+	items := make([]string, len(ingredients))
+	for i, ing := range ingredients {
+		items[i] = strings.Join(strings.Fields(fmt.Sprintf("%s %s %s",
+			strconv.FormatFloat(ing.Amount, 'f', -1, 64), ing.Unit, ing.Name)), " ")
+		if ing.Section != "" && ing.Section != DefaultSection {
+			items[i] += " (" + ing.Section + ")"
+		}
+	}
+	return strings.Join(items, "; ")
+}
+
+// instructionSummary lists the steps as "1. Boil water; 2. Add pasta"
+func instructionSummary(instructions []Instruction) string {
+	items := make([]string, len(instructions))
+	for i, step := range instructions {
+		items[i] = fmt.Sprintf("%d. %s", i+1, step.Step)
+		if step.Duration > 0 {
+			items[i] += " [" + step.Duration.String() + "]"
+		}
+	}
+	return strings.Join(items, "; ")
+}
+
+// shorten cuts s to maxChangeLength characters
+func shorten(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxChangeLength {
+		return s
+	}
+	return string(runes[:maxChangeLength]) + "…"
+}
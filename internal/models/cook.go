@@ -0,0 +1,23 @@
+// Cook mode progress, saved server-side so a cook can switch devices mid-recipe
+
+package models
+
+import "time"
+
+// StepTimer is the state of one step's countdown
+// A running timer is described by EndsAt; a paused one by Remaining
+type StepTimer struct {
+	Step      int           `json:"step"` // index into the recipe's ordered instructions
+	Running   bool          `json:"running"`
+	Remaining time.Duration `json:"remaining"`
+	EndsAt    time.Time     `json:"ends_at"`
+}
+
+// CookProgress tracks which step is on screen and which timers are going
+type CookProgress struct {
+	UserID    string      `json:"user_id"`
+	RecipeID  string      `json:"recipe_id"`
+	Step      int         `json:"step"`
+	Timers    []StepTimer `json:"timers"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
@@ -0,0 +1,73 @@
+// Pulling timer durations out of free-text instruction steps
+
+package models
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationPattern matches things like "20 minutes", "1-2 hours", "an hour" or "45 secs"
+// The optional second number handles ranges, in which case we use the upper bound
+var durationPattern = regexp.MustCompile(
+	`(?i)\b(\d+(?:\.\d+)?|an?|one|two|three|four|five|six|seven|eight|nine|ten|twelve|fifteen|twenty|thirty)` +
+		`(?:\s*(?:-|–|to)\s*(\d+(?:\.\d+)?))?` +
+		`\s*(hours?|hrs?|minutes?|mins?|seconds?|secs?)\b`)
+
+// compoundJoiner matches what may sit between parts of one duration, as in "1 hour and 30 minutes"
+var compoundJoiner = regexp.MustCompile(`^\s*(?:,|and)?\s*$`)
+
+var numberWords = map[string]float64{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10, "twelve": 12,
+	"fifteen": 15, "twenty": 20, "thirty": 30,
+}
+
+// ExtractDuration finds the first duration mentioned in an instruction step
+// Parts written next to each other are added up, so "1 hour 30 minutes" is 90 minutes
+// It returns 0 when the text mentions no duration
+func ExtractDuration(text string) time.Duration {
+	matches := durationPattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for i, m := range matches {
+		// Only keep adding while the next match directly follows the previous one
+		if i > 0 && !compoundJoiner.MatchString(text[matches[i-1][1]:m[0]]) {
+			break
+		}
+
+		amount := parseAmount(text[m[2]:m[3]])
+		if m[4] >= 0 {
+			amount = parseAmount(text[m[4]:m[5]])
+		}
+		total += time.Duration(amount * float64(unitOf(text[m[6]:m[7]])))
+	}
+	return total
+}
+
+func parseAmount(s string) float64 {
+	if n, ok := numberWords[strings.ToLower(s)]; ok {
+		return n
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func unitOf(s string) time.Duration {
+	switch s = strings.ToLower(s); {
+	case strings.HasPrefix(s, "h"):
+		return time.Hour
+	case strings.HasPrefix(s, "m"):
+		return time.Minute
+	default:
+		return time.Second
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtractDuration(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Duration
+	}{
+		{"Simmer for 20 minutes", 20 * time.Minute},
+		{"Bake 45 mins until golden", 45 * time.Minute},
+		{"Rest for 30 secs", 30 * time.Second},
+		{"1.5 hours in a low oven", 90 * time.Minute},
+		{"Roast for two hours", 2 * time.Hour},
+		{"Chill for an hour", time.Hour},
+		{"Wait a minute", time.Minute},
+		{"Cook 2 HRS", 2 * time.Hour},
+
+		// Parts next to each other add up
+		{"Braise 1 hour 30 minutes", 90 * time.Minute},
+		{"Braise 1 hour and 30 minutes", 90 * time.Minute},
+		{"Braise 1 hour, 30 minutes, 15 seconds", time.Hour + 30*time.Minute + 15*time.Second},
+
+		// A range uses its upper bound, so the timer doesn't go off early
+		{"Fry 5-10 minutes", 10 * time.Minute},
+		{"Fry 5 – 10 minutes", 10 * time.Minute},
+		{"Prove 1 to 2 hours", 2 * time.Hour},
+
+		// Only the first duration counts when they're apart
+		{"Boil 10 minutes, then simmer 20 minutes", 10 * time.Minute},
+
+		// No duration at all
+		{"Season to taste", 0},
+		{"", 0},
+		{"Serves 4", 0},
+		{"Use 2 eggs", 0},
+		{"Cut into 3 minutely thin slices", 0},
+	}
+
+	for _, tt := range tests {
+		if got := ExtractDuration(tt.text); got != tt.want {
+			t.Errorf("ExtractDuration(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestInstructionTimer(t *testing.T) {
+	// An explicit duration wins over one in the text
+	step := Instruction{Step: "Simmer for 20 minutes", Duration: 25 * time.Minute}
+	if got := step.Timer(); got != 25*time.Minute {
+		t.Errorf("Timer() = %v, want the explicit 25m", got)
+	}
+	step.Duration = 0
+	if got := step.Timer(); got != 20*time.Minute {
+		t.Errorf("Timer() = %v, want 20m from the text", got)
+	}
+}
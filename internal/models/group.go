@@ -0,0 +1,130 @@
+// Groups (households) and the rules for who can see and edit a recipe
+
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Visibility controls who can see a recipe
+type Visibility string
+
+const (
+	VisibilityPrivate Visibility = "private" // owner (and admins) only
+	VisibilityGroup   Visibility = "group"   // members of the recipe's group
+	VisibilityPublic  Visibility = "public"  // everyone, including visitors
+)
+
+// Visibilities lists every visibility in the order forms show them
+var Visibilities = []Visibility{VisibilityPrivate, VisibilityGroup, VisibilityPublic}
+
+// GroupRole is what a member may do with the group's recipes
+type GroupRole string
+
+const (
+	GroupViewer GroupRole = "viewer"
+	GroupEditor GroupRole = "editor"
+)
+
+// GroupMember is one user's membership of a group
+type GroupMember struct {
+	UserID string    `json:"user_id"`
+	Role   GroupRole `json:"role"`
+}
+
+// Group is a named set of users, such as a household, that recipes can be shared with
+// The owner manages membership and always counts as an editor
+type Group struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	OwnerID   string        `json:"owner_id"`
+	Members   []GroupMember `json:"members"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// RoleOf returns the user's role in the group, if they belong to it
+func (g Group) RoleOf(userID string) (GroupRole, bool) {
+	if userID == "" {
+		return "", false
+	}
+	if g.OwnerID == userID {
+		return GroupEditor, true
+	}
+	for _, m := range g.Members {
+		if m.UserID == userID {
+			return m.Role, true
+		}
+	}
+	return "", false
+}
+
+// Viewer describes who is asking, for visibility and edit checks
+// The zero Viewer is an anonymous visitor
+type Viewer struct {
+	UserID string
+	Admin  bool
+	Groups map[string]GroupRole // group ID -> the viewer's role in it
+}
+
+// InGroup reports whether the viewer belongs to the group
+func (v Viewer) InGroup(groupID string) bool {
+	_, ok := v.Groups[groupID]
+	return ok
+}
+
+// VisibleTo reports whether the viewer may see the recipe
+func (r Recipe) VisibleTo(v Viewer) bool {
+	if v.Admin || (v.UserID != "" && r.OwnerID == v.UserID) {
+		return true
+	}
+	switch r.Visibility {
+	case VisibilityPublic:
+		return true
+	case VisibilityGroup:
+		return r.GroupID != "" && v.InGroup(r.GroupID)
+	default:
+		return false
+	}
+}
+
+// EditableBy reports whether the viewer may change the recipe
+// Recipes saved before accounts existed have no owner, so only admins can touch them
+func (r Recipe) EditableBy(v Viewer) bool {
+	if v.UserID == "" {
+		return false
+	}
+	if v.Admin || (r.OwnerID != "" && r.OwnerID == v.UserID) {
+		return true
+	}
+	return r.Visibility == VisibilityGroup && r.GroupID != "" && v.Groups[r.GroupID] == GroupEditor
+}
+
+// CheckSharing validates a visibility and group chosen by the viewer
+// Only members of a group (or admins) can share recipes with it
+func (v Viewer) CheckSharing(visibility Visibility, groupID string) error {
+	switch visibility {
+	case VisibilityPrivate, VisibilityPublic:
+		if groupID != "" {
+			return fmt.Errorf("only group recipes can have a group")
+		}
+		return nil
+	case VisibilityGroup:
+	default:
+		return fmt.Errorf("invalid visibility: %q", visibility)
+	}
+
+	if groupID == "" {
+		return fmt.Errorf("choose a group to share with")
+	}
+	if !v.Admin && !v.InGroup(groupID) {
+		return fmt.Errorf("you can only share with groups you belong to")
+	}
+	return nil
+}
+
+// ManagedBy reports whether the viewer may change who can see the recipe, or delete it
+// Group editors can edit the content but not re-share or delete it
+func (r Recipe) ManagedBy(v Viewer) bool {
+	return v.Admin || (v.UserID != "" && r.OwnerID == v.UserID)
+}
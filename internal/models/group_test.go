@@ -0,0 +1,130 @@
+package models
+
+import "testing"
+
+// Viewers as auth builds them; "gone" is a group that was deleted, so nobody's
+// viewer lists it any more even though recipes still point at it
+var (
+	anonymous   = Viewer{}
+	owner       = Viewer{UserID: "alice", Groups: map[string]GroupRole{"family": GroupEditor}}
+	groupViewer = Viewer{UserID: "carol", Groups: map[string]GroupRole{"family": GroupViewer}}
+	groupEditor = Viewer{UserID: "dave", Groups: map[string]GroupRole{"family": GroupEditor}}
+	nonMember   = Viewer{UserID: "bob", Groups: map[string]GroupRole{"book-club": GroupEditor}}
+	admin       = Viewer{UserID: "root", Admin: true}
+)
+
+func TestRecipeAccess(t *testing.T) {
+	private := Recipe{OwnerID: "alice", Visibility: VisibilityPrivate}
+	group := Recipe{OwnerID: "alice", Visibility: VisibilityGroup, GroupID: "family"}
+	public := Recipe{OwnerID: "alice", Visibility: VisibilityPublic}
+	deletedGroup := Recipe{OwnerID: "alice", Visibility: VisibilityGroup, GroupID: "gone"}
+	unowned := Recipe{Visibility: VisibilityPublic} // saved before accounts existed
+	unset := Recipe{OwnerID: "alice"}               // no visibility is treated as private
+
+	tests := []struct {
+		name                       string
+		recipe                     Recipe
+		viewer                     Viewer
+		visible, editable, manages bool
+	}{
+		{"owner, private", private, owner, true, true, true},
+		{"owner, group", group, owner, true, true, true},
+		{"owner, public", public, owner, true, true, true},
+		{"owner, deleted group", deletedGroup, owner, true, true, true},
+
+		{"group viewer, private", private, groupViewer, false, false, false},
+		{"group viewer, group", group, groupViewer, true, false, false},
+		{"group viewer, public", public, groupViewer, true, false, false},
+		{"group viewer, deleted group", deletedGroup, groupViewer, false, false, false},
+
+		{"group editor, private", private, groupEditor, false, false, false},
+		{"group editor, group", group, groupEditor, true, true, false},
+		{"group editor, public", public, groupEditor, true, false, false},
+		{"group editor, deleted group", deletedGroup, groupEditor, false, false, false},
+
+		{"non-member, private", private, nonMember, false, false, false},
+		{"non-member, group", group, nonMember, false, false, false},
+		{"non-member, public", public, nonMember, true, false, false},
+		{"non-member, unset", unset, nonMember, false, false, false},
+
+		{"admin, private", private, admin, true, true, true},
+		{"admin, group", group, admin, true, true, true},
+		{"admin, deleted group", deletedGroup, admin, true, true, true},
+		{"admin, unowned", unowned, admin, true, true, true},
+
+		{"anonymous, private", private, anonymous, false, false, false},
+		{"anonymous, group", group, anonymous, false, false, false},
+		{"anonymous, public", public, anonymous, true, false, false},
+		{"anonymous, unowned", unowned, anonymous, true, false, false},
+
+		{"member, unowned", unowned, nonMember, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.recipe.VisibleTo(tt.viewer); got != tt.visible {
+				t.Errorf("VisibleTo = %v, want %v", got, tt.visible)
+			}
+			if got := tt.recipe.EditableBy(tt.viewer); got != tt.editable {
+				t.Errorf("EditableBy = %v, want %v", got, tt.editable)
+			}
+			if got := tt.recipe.ManagedBy(tt.viewer); got != tt.manages {
+				t.Errorf("ManagedBy = %v, want %v", got, tt.manages)
+			}
+		})
+	}
+}
+
+func TestCheckSharing(t *testing.T) {
+	tests := []struct {
+		name       string
+		viewer     Viewer
+		visibility Visibility
+		groupID    string
+		wantErr    bool
+	}{
+		{"private", owner, VisibilityPrivate, "", false},
+		{"public", nonMember, VisibilityPublic, "", false},
+		{"private with a group", owner, VisibilityPrivate, "family", true},
+		{"public with a group", owner, VisibilityPublic, "family", true},
+		{"no visibility", owner, "", "", true},
+		{"unknown visibility", owner, "friends", "", true},
+		{"group without a group", owner, VisibilityGroup, "", true},
+		{"group editor shares with their group", groupEditor, VisibilityGroup, "family", false},
+		{"group viewer shares with their group", groupViewer, VisibilityGroup, "family", false},
+		{"non-member shares with the group", nonMember, VisibilityGroup, "family", true},
+		{"anonymous shares with the group", anonymous, VisibilityGroup, "family", true},
+		{"member shares with a deleted group", owner, VisibilityGroup, "gone", true},
+		{"admin shares with any group", admin, VisibilityGroup, "family", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.viewer.CheckSharing(tt.visibility, tt.groupID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckSharing(%q, %q) = %v, want error: %v", tt.visibility, tt.groupID, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGroupRoleOf(t *testing.T) {
+	group := Group{OwnerID: "alice", Members: []GroupMember{
+		{UserID: "carol", Role: GroupViewer},
+		{UserID: "dave", Role: GroupEditor},
+	}}
+	tests := []struct {
+		userID   string
+		wantRole GroupRole
+		wantOK   bool
+	}{
+		{"alice", GroupEditor, true}, // the owner always counts as an editor
+		{"carol", GroupViewer, true},
+		{"dave", GroupEditor, true},
+		{"bob", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if role, ok := group.RoleOf(tt.userID); role != tt.wantRole || ok != tt.wantOK {
+			t.Errorf("RoleOf(%q) = %q, %v; want %q, %v", tt.userID, role, ok, tt.wantRole, tt.wantOK)
+		}
+	}
+}
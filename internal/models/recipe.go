@@ -3,9 +3,14 @@
 package models
 
 import (
+	"sort"
 	"time"
 )
 
+// DefaultSection is the section name given to ingredients and steps that were
+// saved before sections existed, or that were entered without one
+const DefaultSection = "Main"
+
 // To store our Recipe struct we need to serialize it into json
 // Go structs are typed collections of fields - they are useful for grouping data together to form records
 // below add fields with types
@@ -18,19 +23,48 @@ type Ingredient struct {
 	Name     string  `json:"name"`
 	Amount   float64 `json:"amount"`
 	Unit     string  `json:"unit"`
+	Section  string  `json:"section"`  // Named group, e.g. "For the dough"
 	Position int     `json:"position"` // For ordering ingredients
 }
 
 // Instruction represents a recipe step
 type Instruction struct {
-	ID       string `json:"id"`
-	Step     string `json:"step"`
-	Position int    `json:"position"` // For ordering steps
+	ID       string        `json:"id"`
+	Step     string        `json:"step"`
+	Section  string        `json:"section"`            // Named group, e.g. "For the filling"
+	Duration time.Duration `json:"duration,omitempty"` // Explicit timer, overrides anything found in Step
+	Position int           `json:"position"`           // For ordering steps
+}
+
+// Timer returns how long the step takes
+// An explicit Duration wins, otherwise we look for one in the step text
+func (i Instruction) Timer() time.Duration {
+	if i.Duration > 0 {
+		return i.Duration
+	}
+	return ExtractDuration(i.Step)
+}
+
+// IngredientGroup is one named section of a recipe's ingredient list
+type IngredientGroup struct {
+	Name        string
+	Ingredients []Ingredient
+}
+
+// InstructionGroup is one named section of a recipe's steps
+// Start is the 1-based number of the first step so numbering runs across sections
+type InstructionGroup struct {
+	Name         string
+	Start        int
+	Instructions []Instruction
 }
 
 // Update Recipe struct to include ingredients and instructions
 type Recipe struct {
 	ID           string        `json:"id"`
+	OwnerID      string        `json:"owner_id"` // User who created it; empty for recipes saved before accounts existed
+	Visibility   Visibility    `json:"visibility"`
+	GroupID      string        `json:"group_id,omitempty"` // Group it's shared with when Visibility is "group"
 	Title        string        `json:"title"`
 	Description  string        `json:"description"`
 	PrepTime     time.Duration `json:"prep_time"`
@@ -39,3 +73,113 @@ type Recipe struct {
 	Ingredients  []Ingredient  `json:"ingredients"`
 	Instructions []Instruction `json:"instructions"`
 }
+
+// Normalize sorts ingredients and instructions by Position and moves anything
+// without a section into DefaultSection
+func (r *Recipe) Normalize() {
+	sort.SliceStable(r.Ingredients, func(i, j int) bool {
+		return r.Ingredients[i].Position < r.Ingredients[j].Position
+	})
+	for i := range r.Ingredients {
+		if r.Ingredients[i].Section == "" {
+			r.Ingredients[i].Section = DefaultSection
+		}
+	}
+
+	sort.SliceStable(r.Instructions, func(i, j int) bool {
+		return r.Instructions[i].Position < r.Instructions[j].Position
+	})
+	for i := range r.Instructions {
+		if r.Instructions[i].Section == "" {
+			r.Instructions[i].Section = DefaultSection
+		}
+	}
+}
+
+// IngredientGroups returns the ingredients grouped by section, in order of
+// each section's first appearance
+func (r Recipe) IngredientGroups() []IngredientGroup {
+	var groups []IngredientGroup
+	index := make(map[string]int)
+	for _, ing := range r.sortedIngredients() {
+		name := sectionName(ing.Section)
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, IngredientGroup{Name: name})
+		}
+		groups[i].Ingredients = append(groups[i].Ingredients, ing)
+	}
+	return groups
+}
+
+// InstructionGroups returns the steps grouped by section, in order of each
+// section's first appearance
+func (r Recipe) InstructionGroups() []InstructionGroup {
+	var groups []InstructionGroup
+	index := make(map[string]int)
+	for _, step := range r.sortedInstructions() {
+		name := sectionName(step.Section)
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, InstructionGroup{Name: name})
+		}
+		groups[i].Instructions = append(groups[i].Instructions, step)
+	}
+
+	// Number steps continuously across sections
+	next := 1
+	for i := range groups {
+		groups[i].Start = next
+		next += len(groups[i].Instructions)
+	}
+	return groups
+}
+
+// HasSections reports whether the recipe uses anything besides the default section
+// Templates use it to skip section headings for simple recipes
+func (r Recipe) HasSections() bool {
+	for _, ing := range r.Ingredients {
+		if sectionName(ing.Section) != DefaultSection {
+			return true
+		}
+	}
+	for _, step := range r.Instructions {
+		if sectionName(step.Section) != DefaultSection {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderedInstructions returns the steps sorted by Position
+// Cook mode refers to steps by their index in this slice
+func (r Recipe) OrderedInstructions() []Instruction {
+	return r.sortedInstructions()
+}
+
+func (r Recipe) sortedIngredients() []Ingredient {
+	ingredients := append([]Ingredient(nil), r.Ingredients...)
+	sort.SliceStable(ingredients, func(i, j int) bool {
+		return ingredients[i].Position < ingredients[j].Position
+	})
+	return ingredients
+}
+
+func (r Recipe) sortedInstructions() []Instruction {
+	instructions := append([]Instruction(nil), r.Instructions...)
+	sort.SliceStable(instructions, func(i, j int) bool {
+		return instructions[i].Position < instructions[j].Position
+	})
+	return instructions
+}
+
+func sectionName(s string) string {
+	if s == "" {
+		return DefaultSection
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	recipe := Recipe{
+		Ingredients: []Ingredient{
+			{Name: "Salt", Position: 2},
+			{Name: "Flour", Section: "Dough", Position: 0},
+			{Name: "Water", Section: "Dough", Position: 1},
+			{Name: "Pepper", Position: 2}, // ties keep their order
+		},
+		Instructions: []Instruction{
+			{Step: "Bake", Position: 1},
+			{Step: "Knead", Section: "Dough", Position: 0},
+		},
+	}
+	recipe.Normalize()
+
+	var ingredients []string
+	for _, ing := range recipe.Ingredients {
+		ingredients = append(ingredients, ing.Section+"/"+ing.Name)
+	}
+	if want := []string{"Dough/Flour", "Dough/Water", "Main/Salt", "Main/Pepper"}; !reflect.DeepEqual(ingredients, want) {
+		t.Errorf("ingredients = %v, want %v", ingredients, want)
+	}
+	var steps []string
+	for _, step := range recipe.Instructions {
+		steps = append(steps, step.Section+"/"+step.Step)
+	}
+	if want := []string{"Dough/Knead", "Main/Bake"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps = %v, want %v", steps, want)
+	}
+}
+
+func TestIngredientGroups(t *testing.T) {
+	// Sections come out in the order they first appear once sorted by position,
+	// and a missing section reads as the default one
+	recipe := Recipe{Ingredients: []Ingredient{
+		{Name: "Sugar", Section: "Filling", Position: 3},
+		{Name: "Flour", Section: "Pastry", Position: 0},
+		{Name: "Salt", Position: 1},
+		{Name: "Butter", Section: "Pastry", Position: 2},
+		{Name: "Pepper", Section: DefaultSection, Position: 4},
+	}}
+
+	var got []string
+	for _, g := range recipe.IngredientGroups() {
+		names := g.Name + ":"
+		for _, ing := range g.Ingredients {
+			names += " " + ing.Name
+		}
+		got = append(got, names)
+	}
+	want := []string{"Pastry: Flour Butter", "Main: Salt Pepper", "Filling: Sugar"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IngredientGroups() = %v, want %v", got, want)
+	}
+	if recipe.Ingredients[0].Name != "Sugar" {
+		t.Error("IngredientGroups() reordered the recipe's own slice")
+	}
+}
+
+func TestInstructionGroups(t *testing.T) {
+	recipe := Recipe{Instructions: []Instruction{
+		{Step: "Assemble", Position: 4},
+		{Step: "Make the dough", Section: "Dough", Position: 0},
+		{Step: "Rest the dough", Section: "Dough", Position: 1},
+		{Step: "Cook the filling", Section: "Filling", Position: 2},
+		{Step: "Cool the filling", Section: "Filling", Position: 3},
+	}}
+
+	tests := []struct {
+		name  string
+		start int
+		steps int
+	}{
+		{"Dough", 1, 2},
+		{"Filling", 3, 2},
+		{DefaultSection, 5, 1}, // numbering runs on across sections
+	}
+	groups := recipe.InstructionGroups()
+	if len(groups) != len(tests) {
+		t.Fatalf("got %d groups, want %d", len(groups), len(tests))
+	}
+	for i, tt := range tests {
+		g := groups[i]
+		if g.Name != tt.name || g.Start != tt.start || len(g.Instructions) != tt.steps {
+			t.Errorf("group %d = %s starting at %d with %d steps, want %s starting at %d with %d",
+				i, g.Name, g.Start, len(g.Instructions), tt.name, tt.start, tt.steps)
+		}
+	}
+	if groups[0].Instructions[1].Step != "Rest the dough" {
+		t.Errorf("dough steps = %+v, want them in position order", groups[0].Instructions)
+	}
+}
+
+func TestHasSections(t *testing.T) {
+	plain := Recipe{
+		Ingredients:  []Ingredient{{Name: "Salt"}, {Name: "Water", Section: DefaultSection}},
+		Instructions: []Instruction{{Step: "Boil"}},
+	}
+	if plain.HasSections() {
+		t.Error("HasSections() = true for a recipe with only the default section")
+	}
+	plain.Instructions[0].Section = "To serve"
+	if !plain.HasSections() {
+		t.Error("HasSections() = false with a named step section")
+	}
+}
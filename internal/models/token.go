@@ -0,0 +1,40 @@
+// Personal API tokens for scripts and bots
+
+package models
+
+import "time"
+
+// Scope limits what an API token may do
+type Scope string
+
+const (
+	ScopeRecipesRead  Scope = "recipes:read"
+	ScopeRecipesWrite Scope = "recipes:write"
+	ScopeAdmin        Scope = "admin" // implies every other scope; only admins may hold it
+)
+
+// AllScopes lists every scope in the order the UI shows them
+var AllScopes = []Scope{ScopeRecipesRead, ScopeRecipesWrite, ScopeAdmin}
+
+// APIToken is a bearer token minted by a user
+// Hash is the SHA-256 of the secret - the secret itself is only shown once, when minted
+type APIToken struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name"`
+	Hash       string    `json:"hash"`
+	Prefix     string    `json:"prefix"` // first few characters of the secret, to tell tokens apart
+	Scopes     []Scope   `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+// HasScope reports whether the token grants scope
+func (t APIToken) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+// User accounts and login sessions
+
+package models
+
+import "time"
+
+// Role controls what a user may do beyond managing their own recipes
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  // may edit or delete any recipe and manage users
+	RoleMember Role = "member" // may manage their own recipes
+)
+
+// User is an account that can log in
+// PasswordHash is a bcrypt hash - the plain password is never stored
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Session is a logged-in browser
+// ID is the SHA-256 of the cookie value, so a leaked database can't be used to log in
+type Session struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	CSRFToken string    `json:"csrf_token"` // anti-forgery token for this session's forms
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the session is no longer valid at the given time
+func (s Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
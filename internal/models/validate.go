@@ -0,0 +1,106 @@
+// Checking a recipe before it's saved, shared by the web form and the API
+
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits on what a recipe may hold
+const (
+	MaxTitleLength = 200
+	MaxServings    = 100
+	MaxRecipeTime  = 7 * 24 * time.Hour // a week is plenty, even for sourdough
+)
+
+// ValidationErrors maps a field to what's wrong with it
+// The keys match the form fields and JSON names: title, prep_time, cook_time, servings,
+// ingredients and instructions
+type ValidationErrors map[string]string
+
+// Error lists the problems, so ValidationErrors can be returned as an error
+func (v ValidationErrors) Error() string {
+	problems := make([]string, 0, len(v))
+	for _, field := range []string{"title", "prep_time", "cook_time", "servings", "ingredients", "instructions"} {
+		if msg, ok := v[field]; ok {
+			problems = append(problems, msg)
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// Validate checks the recipe can be saved, returning nil if it can
+// Every problem is reported at once; a list with several bad rows reports the first
+func (r Recipe) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+
+	switch n := utf8.RuneCountInString(strings.TrimSpace(r.Title)); {
+	case n == 0:
+		errs["title"] = "Title is required"
+	case n > MaxTitleLength:
+		errs["title"] = fmt.Sprintf("Title must be at most %d characters", MaxTitleLength)
+	}
+
+	if msg := checkTime(r.PrepTime); msg != "" {
+		errs["prep_time"] = "Prep time " + msg
+	}
+	if msg := checkTime(r.CookTime); msg != "" {
+		errs["cook_time"] = "Cook time " + msg
+	}
+	if r.Servings < 1 || r.Servings > MaxServings {
+		errs["servings"] = fmt.Sprintf("Servings must be a whole number from 1 to %d", MaxServings)
+	}
+
+	if len(r.Ingredients) == 0 {
+		errs["ingredients"] = "Add at least one ingredient"
+	}
+	for i, ing := range r.Ingredients {
+		var msg string
+		switch {
+		case strings.TrimSpace(ing.Name) == "":
+			msg = "needs a name"
+		case math.IsNaN(ing.Amount) || math.IsInf(ing.Amount, 0):
+			msg = "amount must be a number"
+		case ing.Amount < 0:
+			msg = "amount can't be negative"
+		}
+		if msg != "" {
+			errs["ingredients"] = fmt.Sprintf("Ingredient %d %s", i+1, msg)
+			break
+		}
+	}
+
+	for i, step := range r.Instructions {
+		var msg string
+		switch {
+		case strings.TrimSpace(step.Step) == "":
+			msg = "needs a description"
+		case checkTime(step.Duration) != "":
+			msg = "timer " + checkTime(step.Duration)
+		}
+		if msg != "" {
+			errs["instructions"] = fmt.Sprintf("Step %d %s", i+1, msg)
+			break
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// checkTime describes what's wrong with a prep, cook or step time, or returns ""
+func checkTime(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "can't be negative"
+	case d > MaxRecipeTime:
+		return fmt.Sprintf("must be at most %d minutes", int(MaxRecipeTime.Minutes()))
+	}
+	return ""
+}
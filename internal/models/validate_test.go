@@ -0,0 +1,64 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	valid := func() Recipe {
+		return Recipe{
+			Title:        "Soup",
+			PrepTime:     5 * time.Minute,
+			CookTime:     time.Hour,
+			Servings:     4,
+			Ingredients:  []Ingredient{{Name: "Water", Amount: 1, Unit: "l"}, {Name: "Salt"}},
+			Instructions: []Instruction{{Step: "Boil", Duration: 10 * time.Minute}},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		change     func(*Recipe)
+		wantFields []string
+	}{
+		{"valid", func(*Recipe) {}, nil},
+		{"no steps", func(r *Recipe) { r.Instructions = nil }, nil},
+		{"blank title", func(r *Recipe) { r.Title = "  " }, []string{"title"}},
+		{"long title", func(r *Recipe) { r.Title = strings.Repeat("é", MaxTitleLength+1) }, []string{"title"}},
+		{"title at the limit", func(r *Recipe) { r.Title = strings.Repeat("é", MaxTitleLength) }, nil},
+		{"negative prep time", func(r *Recipe) { r.PrepTime = -time.Minute }, []string{"prep_time"}},
+		{"cook time over a week", func(r *Recipe) { r.CookTime = MaxRecipeTime + time.Minute }, []string{"cook_time"}},
+		{"no servings", func(r *Recipe) { r.Servings = 0 }, []string{"servings"}},
+		{"too many servings", func(r *Recipe) { r.Servings = MaxServings + 1 }, []string{"servings"}},
+		{"no ingredients", func(r *Recipe) { r.Ingredients = nil }, []string{"ingredients"}},
+		{"unnamed ingredient", func(r *Recipe) { r.Ingredients[1].Name = "" }, []string{"ingredients"}},
+		{"negative amount", func(r *Recipe) { r.Ingredients[0].Amount = -1 }, []string{"ingredients"}},
+		{"infinite amount", func(r *Recipe) { r.Ingredients[0].Amount = math.Inf(1) }, []string{"ingredients"}},
+		{"blank step", func(r *Recipe) { r.Instructions[0].Step = "" }, []string{"instructions"}},
+		{"negative timer", func(r *Recipe) { r.Instructions[0].Duration = -time.Second }, []string{"instructions"}},
+		{"several problems", func(r *Recipe) { r.Title, r.Servings = "", 0 }, []string{"title", "servings"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipe := valid()
+			tt.change(&recipe)
+			errs := recipe.Validate()
+
+			if len(errs) != len(tt.wantFields) {
+				t.Fatalf("Validate() = %v, want errors for %v", errs, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if errs[field] == "" {
+					t.Errorf("no error for %s, got %v", field, errs)
+				}
+				if !strings.Contains(errs.Error(), errs[field]) {
+					t.Errorf("Error() = %q, missing %q", errs.Error(), errs[field])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a certificate from disk and picks up renewals without a restart
+// certbot and friends replace the files in place, so we watch their modification times
+type CertReloader struct {
+	certPath string
+	keyPath  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certMod  time.Time
+	keyMod   time.Time
+	loadedAt time.Time
+}
+
+// NewCertReloader loads the certificate and key, failing if they can't be used
+func NewCertReloader(certPath, keyPath string) (*CertReloader, error) {
+	c := &CertReloader{certPath: certPath, keyPath: keyPath}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload reads the certificate and key from disk
+// If they can't be loaded the current certificate stays in use
+func (c *CertReloader) Reload() error {
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		return fmt.Errorf("could not load tls certificate: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.certMod, c.keyMod = certMod, keyMod
+	c.loadedAt = time.Now()
+	return nil
+}
+
+// ReloadIfChanged reloads the certificate when either file has been modified since the last load
+func (c *CertReloader) ReloadIfChanged() (bool, error) {
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.RLock()
+	changed := !certMod.Equal(c.certMod) || !keyMod.Equal(c.keyMod)
+	c.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+	return true, c.Reload()
+}
+
+// GetCertificate hands the current certificate to each TLS handshake
+// It matches tls.Config.GetCertificate
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+func (c *CertReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(c.certPath)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("could not read tls certificate: %v", err)
+	}
+	keyInfo, err := os.Stat(c.keyPath)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("could not read tls key: %v", err)
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// BodyLimit caps request bodies at defaultMax bytes, or at the size given in routes for
+// the matching route template, such as "/login"
+// It must run as router middleware so the matched route is known
+func BodyLimit(defaultMax int64, routes map[string]int64) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			max := defaultMax
+			if n, ok := routes[currentTemplate(r)]; ok {
+				max = n
+			}
+
+			// Most clients say up front how big the body is, so refuse those without reading it
+			if r.ContentLength > max {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			// For the rest, reads fail once the limit is passed
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimiter hands out requests from a token bucket per key
+// Each bucket holds up to burst tokens and refills at rate tokens per second
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time // replaced in tests
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perMinute requests a minute per key,
+// with bursts of up to burst requests
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from key's bucket
+// When the bucket is empty it returns false and how long until a token is available
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	// Refill for the time since the last request, up to the bucket size
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// Prune forgets buckets that have refilled completely, which behave the same as new ones
+// Without it the map would grow with every client ever seen
+func (l *RateLimiter) Prune() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	now := l.now()
+	removed := 0
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RateLimit rejects requests with 429 Too Many Requests once key's bucket runs dry
+// If routes is non-empty, only requests matching one of them are limited - entries look
+// like "POST /login", a method and a route template
+func RateLimit(l *RateLimiter, key func(*http.Request) string, routes ...string) mux.MiddlewareFunc {
+	only := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		only[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(only) > 0 && !only[r.Method+" "+currentTemplate(r)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if ok, wait := l.Allow(key(r)); !ok {
+				// Retry-After is in whole seconds; round up so clients don't come back too early
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Too many requests - slow down and try again shortly", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// currentTemplate is the path template of the route mux matched
+func currentTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return ""
+}
+
+// ClientIP is the address the request came from
+// Behind a reverse proxy every request comes from the proxy, so with trustProxy set the
+// X-Real-IP header, or the last X-Forwarded-For entry, is used instead. Only set it when
+// the proxy overwrites those headers - otherwise clients can pick their own address
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			parts := strings.Split(fwd, ",")
+			return strings.TrimSpace(parts[len(parts)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewRateLimiter(60, 2) // one a second, bursts of two
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d within the burst was refused", i+1)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("request past the burst was allowed")
+	}
+	if wait != time.Second {
+		t.Errorf("wait = %v, want 1s", wait)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("another key should have its own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("bucket did not refill after a second")
+	}
+
+	now = now.Add(time.Minute)
+	if n := l.Prune(); n != 2 {
+		t.Errorf("Prune removed %d buckets, want 2", n)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	l := NewRateLimiter(60, 1)
+	r := mux.NewRouter()
+	r.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET", "POST")
+	r.Use(RateLimit(l, func(*http.Request) string { return "client" }, "POST /login"))
+
+	send := func(method string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, "/login", nil))
+		return rec
+	}
+
+	if rec := send("POST"); rec.Code != http.StatusOK {
+		t.Fatalf("first POST status = %d, want 200", rec.Code)
+	}
+	rec := send("POST")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second POST status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+	if rec := send("GET"); rec.Code != http.StatusOK {
+		t.Errorf("GET is not a limited route, status = %d", rec.Code)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	r := mux.NewRouter()
+	read := func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}
+	r.HandleFunc("/recipes", read)
+	r.HandleFunc("/login", read)
+	r.Use(BodyLimit(100, map[string]int64{"/login": 10}))
+
+	tests := []struct {
+		path       string
+		size       int
+		chunked    bool
+		wantStatus int
+	}{
+		{"/recipes", 100, false, http.StatusOK},
+		{"/recipes", 101, false, http.StatusRequestEntityTooLarge},
+		{"/login", 11, false, http.StatusRequestEntityTooLarge},
+		{"/login", 11, true, http.StatusBadRequest}, // no Content-Length, so the read fails instead
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(strings.Repeat("x", tt.size)))
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s with %d bytes: status = %d, want %d", tt.path, tt.size, rec.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:5555"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 203.0.113.9")
+
+	if got := ClientIP(req, false); got != "10.0.0.1" {
+		t.Errorf("untrusted proxy: got %q, want the connection address", got)
+	}
+	if got := ClientIP(req, true); got != "203.0.113.9" {
+		t.Errorf("trusted proxy: got %q, want the address nginx added", got)
+	}
+}
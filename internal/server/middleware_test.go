@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		allowed    []string
+		method     string
+		origin     string
+		preflight  bool
+		wantStatus int
+		wantOrigin string
+	}{
+		{"no origin", []string{"https://a.example"}, "GET", "", false, http.StatusOK, ""},
+		{"allowed origin", []string{"https://a.example"}, "GET", "https://a.example", false, http.StatusOK, "https://a.example"},
+		{"other origin", []string{"https://a.example"}, "GET", "https://evil.example", false, http.StatusOK, ""},
+		{"wildcard", []string{"*"}, "GET", "https://b.example", false, http.StatusOK, "*"},
+		{"allowed preflight", []string{"https://a.example"}, "OPTIONS", "https://a.example", true, http.StatusNoContent, "https://a.example"},
+		{"rejected preflight", []string{"https://a.example"}, "OPTIONS", "https://evil.example", true, http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/recipes", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", "PUT")
+			}
+			rec := httptest.NewRecorder()
+
+			CORS(tt.allowed)(ok).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Got Access-Control-Allow-Origin %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+				t.Errorf("Credentials should never be allowed, got %q", got)
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, hsts := range []bool{false, true} {
+		rec := httptest.NewRecorder()
+		SecurityHeaders(hsts)(ok).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Header().Get("Content-Security-Policy") == "" || rec.Header().Get("X-Frame-Options") != "DENY" {
+			t.Errorf("Missing security headers: %v", rec.Header())
+		}
+		if got := rec.Header().Get("Strict-Transport-Security") != ""; got != hsts {
+			t.Errorf("HSTS set = %v, want %v", got, hsts)
+		}
+	}
+}
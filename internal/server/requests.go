@@ -0,0 +1,194 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go_recipe_app/internal/logging"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader carries the request ID in and out, so logs can be matched across services
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength stops clients from stuffing large values into every log line
+const maxRequestIDLength = 128
+
+// RequestID gives every request an ID, reusing a sane X-Request-ID from a proxy if there is one
+// The ID goes on the request context and back out in the response header
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID allows the characters proxies use for IDs (hex, UUIDs, base64) and nothing
+// that could break a log line
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// Extremely unlikely; a timestamp still tells requests apart
+		return fmt.Sprintf("t%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder remembers the status code and size of a response for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the real writer, e.g. to flush
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// ObserveFunc is told about every finished request, e.g. to record metrics
+type ObserveFunc func(method, route string, status int, latency time.Duration)
+
+// AccessLog puts a request-scoped logger on the context and writes one line per request
+// routes is used to log the route template ("/recipes/{id}") rather than every distinct path
+// observe, if not nil, gets the same details as the log line
+// The access line belongs to the "http" component, so its level can be set on its own
+func AccessLog(logger *slog.Logger, routes *mux.Router, observe ObserveFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			reqLogger := logger.With("request_id", logging.RequestID(r.Context()))
+			rec := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r.WithContext(logging.WithLogger(r.Context(), reqLogger)))
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK // nothing written at all
+			}
+			latency := time.Since(start)
+			route := RouteTemplate(routes, r)
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			}
+			reqLogger.With("component", "http").LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int("bytes", rec.bytes),
+				slog.Duration("latency", latency),
+			)
+			if observe != nil {
+				observe(r.Method, route, status, latency)
+			}
+		})
+	}
+}
+
+// RouteTemplate returns the mux route template a request matches, such as "/recipes/{id}"
+// Unmatched requests return "unmatched" so scanners can't flood the logs with unique paths
+func RouteTemplate(routes *mux.Router, r *http.Request) string {
+	if routes == nil {
+		return r.URL.Path
+	}
+	var match mux.RouteMatch
+	if !routes.Match(r, &match) || match.Route == nil {
+		return "unmatched"
+	}
+	tmpl, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return "unmatched"
+	}
+	return tmpl
+}
+
+// panicRecorder notes whether anything has been sent, so Recover knows if it can still send a page
+type panicRecorder struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (p *panicRecorder) WriteHeader(status int) {
+	p.wrote = true
+	p.ResponseWriter.WriteHeader(status)
+}
+
+func (p *panicRecorder) Write(b []byte) (int, error) {
+	p.wrote = true
+	return p.ResponseWriter.Write(b)
+}
+
+func (p *panicRecorder) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
+
+// Recover turns a panicking handler into a logged stack trace and a 500 page
+// If the handler had already started its response there's nothing to do but log it
+func Recover(errorPage http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &panicRecorder{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// net/http uses this panic to abort a response on purpose
+				if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(p)
+				}
+
+				logging.FromContext(r.Context()).Error("panic serving request",
+					slog.Any("panic", p),
+					slog.String("stack", string(debug.Stack())),
+				)
+				if !rec.wrote {
+					errorPage.ServeHTTP(w, r)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"go_recipe_app/internal/logging"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRequestChain(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/recipes/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Info("handling")
+		w.Write([]byte("ok"))
+	})
+	router.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		panic("kaboom")
+	})
+
+	errorPage := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("sorry " + logging.RequestID(r.Context())))
+	})
+	handler := RequestID(AccessLog(logger, router, nil)(Recover(errorPage)(router)))
+
+	// An incoming ID is kept and appears on the handler's own log lines
+	req := httptest.NewRequest("GET", "/recipes/recipe-1", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Errorf("Got request ID %q, want abc-123", got)
+	}
+	lines := decodeLines(t, &logs)
+	if len(lines) != 2 {
+		t.Fatalf("Got %d log lines, want 2", len(lines))
+	}
+	if lines[0]["request_id"] != "abc-123" || lines[0]["msg"] != "handling" {
+		t.Errorf("Handler log line missing request ID: %v", lines[0])
+	}
+	if lines[1]["route"] != "/recipes/{id}" || lines[1]["status"] != float64(200) || lines[1]["bytes"] != float64(2) {
+		t.Errorf("Unexpected access log line: %v", lines[1])
+	}
+
+	// A bad incoming ID is replaced, and a panic becomes the error page
+	req = httptest.NewRequest("GET", "/boom", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	id := rec.Header().Get(RequestIDHeader)
+	if id == "" || id == "bad id\nwith newline" {
+		t.Errorf("Invalid request ID was not replaced: %q", id)
+	}
+	if rec.Code != http.StatusInternalServerError || rec.Body.String() != "sorry "+id {
+		t.Errorf("Got %d %q, want the error page", rec.Code, rec.Body.String())
+	}
+	lines = decodeLines(t, &logs)
+	if len(lines) != 2 || lines[0]["msg"] != "panic serving request" || lines[0]["stack"] == "" {
+		t.Fatalf("Panic was not logged with a stack: %v", lines)
+	}
+	if lines[1]["status"] != float64(500) || lines[1]["level"] != "ERROR" {
+		t.Errorf("Access log should record the 500: %v", lines[1])
+	}
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]interface{}
+		if err := dec.Decode(&line); err != nil {
+			t.Fatalf("Bad log line: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
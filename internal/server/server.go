@@ -0,0 +1,152 @@
+// Package server builds the HTTP server and the middleware that wraps every request
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"go_recipe_app/internal/config"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Server is the app's HTTP(S) server, plus the HTTP to HTTPS redirect server when TLS is on
+type Server struct {
+	HTTP     *http.Server
+	Redirect *http.Server  // nil unless serving TLS with a redirect port
+	Certs    *CertReloader // nil unless serving TLS
+}
+
+// Options are the parts of the middleware chain that come from outside the config
+type Options struct {
+	Logger    *slog.Logger // for access logs and panics; slog.Default() if nil
+	Routes    *mux.Router  // names routes in the access log; paths are logged if nil
+	ErrorPage http.Handler // shown when a handler panics; a plain 500 if nil
+	Observe   ObserveFunc  // called after every request, e.g. for metrics; optional
+}
+
+// New creates the servers for the app using the timeouts, limits and TLS settings from cfg
+// Every request passes through, outermost first: request ID, access log, panic recovery,
+// security headers and CORS, before reaching handler
+func New(cfg *config.Config, handler http.Handler, opts Options) (*Server, error) {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.ErrorPage == nil {
+		opts.ErrorPage = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		})
+	}
+
+	handler = CORS(cfg.AllowedOrigins)(handler)
+	handler = SecurityHeaders(cfg.Secure())(handler)
+	handler = Recover(opts.ErrorPage)(handler)
+	handler = AccessLog(opts.Logger, opts.Routes, opts.Observe)(handler)
+	handler = RequestID(handler)
+
+	s := &Server{HTTP: newHTTPServer(cfg, cfg.Port, handler)}
+	if !cfg.TLSEnabled() {
+		return s, nil
+	}
+
+	certs, err := NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	s.Certs = certs
+
+	minVersion := uint16(tls.VersionTLS12)
+	if cfg.TLSMinVersion == "1.3" {
+		minVersion = tls.VersionTLS13
+	}
+	s.HTTP.TLSConfig = &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: certs.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"}, // offer HTTP/2 first
+	}
+
+	if cfg.HTTPRedirectPort != 0 {
+		s.Redirect = newHTTPServer(cfg, cfg.HTTPRedirectPort, RedirectToHTTPS(cfg.Port))
+	}
+	return s, nil
+}
+
+func newHTTPServer(cfg *config.Config, port int, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// TLS reports whether the server serves HTTPS
+func (s *Server) TLS() bool {
+	return s.Certs != nil
+}
+
+// ListenAndServe serves until Shutdown or Close is called, then returns http.ErrServerClosed
+// Like http.Server.ListenAndServe it always returns a non-nil error
+func (s *Server) ListenAndServe() error {
+	errs := make(chan error, 2)
+
+	if s.Redirect != nil {
+		go func() { errs <- s.Redirect.ListenAndServe() }()
+	}
+	go func() {
+		if s.TLS() {
+			// The certificate comes from TLSConfig.GetCertificate, so no files are passed here
+			errs <- s.HTTP.ListenAndServeTLS("", "")
+			return
+		}
+		errs <- s.HTTP.ListenAndServe()
+	}()
+
+	// If either listener fails, stop the other one too
+	err := <-errs
+	if !errors.Is(err, http.ErrServerClosed) {
+		s.Close()
+	}
+	return err
+}
+
+// Shutdown gracefully stops both servers, waiting for in-flight requests until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	var redirectErr error
+	if s.Redirect != nil {
+		redirectErr = s.Redirect.Shutdown(ctx)
+	}
+	return errors.Join(s.HTTP.Shutdown(ctx), redirectErr)
+}
+
+// Close stops both servers immediately, dropping any open connections
+func (s *Server) Close() error {
+	var redirectErr error
+	if s.Redirect != nil {
+		redirectErr = s.Redirect.Close()
+	}
+	return errors.Join(s.HTTP.Close(), redirectErr)
+}
+
+// RedirectToHTTPS sends every request to the same URL on the HTTPS port
+// 308 keeps the method and body, so a stray POST isn't silently turned into a GET
+func RedirectToHTTPS(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
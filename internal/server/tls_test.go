@@ -0,0 +1,193 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"go_recipe_app/internal/config"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a fresh certificate for 127.0.0.1 and returns it
+func writeSelfSignedCert(t *testing.T, certPath, keyPath string, serial int64) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "recipe test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+// startTLS serves s over TLS on a random local port and returns its address
+func startTLS(t *testing.T, s *Server) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go s.HTTP.ServeTLS(ln, "", "")
+	t.Cleanup(func() { s.Close() })
+	return ln.Addr().String()
+}
+
+func testConfig(certPath, keyPath string) *config.Config {
+	return &config.Config{
+		Port:              8443,
+		TLSCertFile:       certPath,
+		TLSKeyFile:        keyPath,
+		TLSMinVersion:     "1.2",
+		ReadTimeout:       5 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      5 * time.Second,
+		IdleTimeout:       5 * time.Second,
+		MaxHeaderBytes:    1 << 16,
+		AllowedOrigins:    []string{"*"},
+	}
+}
+
+func TestTLSServerReloadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := writeSelfSignedCert(t, certPath, keyPath, 1)
+
+	s, err := New(testConfig(certPath, keyPath), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), Options{})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	addr := startTLS(t, s)
+
+	// get connects trusting only the expected certificate, so a stale one fails the handshake
+	get := func(trusted *x509.Certificate) (*http.Response, error) {
+		pool := x509.NewCertPool()
+		pool.AddCert(trusted)
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool},
+			ForceAttemptHTTP2: true,
+		}}
+		defer client.CloseIdleConnections()
+		return client.Get("https://" + addr + "/")
+	}
+
+	resp, err := get(first)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("Got %s, want HTTP/2", resp.Proto)
+	}
+	if resp.Header.Get("Strict-Transport-Security") == "" {
+		t.Error("HSTS header missing over TLS")
+	}
+
+	// Replace the files as certbot would; bump the mtime in case the filesystem is coarse
+	second := writeSelfSignedCert(t, certPath, keyPath, 2)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certPath, later, later)
+
+	changed, err := s.Certs.ReloadIfChanged()
+	if err != nil || !changed {
+		t.Fatalf("ReloadIfChanged = %v, %v; want true, nil", changed, err)
+	}
+	if changed, _ := s.Certs.ReloadIfChanged(); changed {
+		t.Error("Reloaded again without a change")
+	}
+
+	if _, err := get(first); err == nil {
+		t.Error("Old certificate still served after reload")
+	}
+	resp, err = get(second)
+	if err != nil {
+		t.Fatalf("Request with new certificate failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeSelfSignedCert(t, certPath, keyPath, 1)
+
+	cfg := testConfig(certPath, keyPath)
+	cfg.TLSMinVersion = "1.3"
+	s, err := New(cfg, http.NotFoundHandler(), Options{})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	addr := startTLS(t, s)
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	if err == nil {
+		conn.Close()
+		t.Fatal("TLS 1.2 handshake succeeded with a 1.3 minimum")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		port int
+		host string
+		want string
+	}{
+		{443, "example.com", "https://example.com/recipes?x=1"},
+		{443, "example.com:80", "https://example.com/recipes?x=1"},
+		{8443, "example.com:8080", "https://example.com:8443/recipes?x=1"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "http://"+tt.host+"/recipes?x=1", nil)
+		rec := httptest.NewRecorder()
+		RedirectToHTTPS(tt.port).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusPermanentRedirect {
+			t.Errorf("Got status %d, want %d", rec.Code, http.StatusPermanentRedirect)
+		}
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("Got Location %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"go_recipe_app/internal/models"
+	"time"
+)
+
+// withoutContext adapts a ContextRecipeStore for callers that don't have a context
+type withoutContext struct {
+	store   ContextRecipeStore
+	timeout time.Duration
+}
+
+// WithoutContext lets code written against RecipeStore use a ContextRecipeStore
+// Each call gets a fresh context that gives up after timeout; zero means no limit
+func WithoutContext(store ContextRecipeStore, timeout time.Duration) RecipeStore {
+	return withoutContext{store: store, timeout: timeout}
+}
+
+func (a withoutContext) context() (context.Context, context.CancelFunc) {
+	if a.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), a.timeout)
+}
+
+func (a withoutContext) List() ([]models.Recipe, error) {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.store.List(ctx)
+}
+
+func (a withoutContext) ListVisible(viewer models.Viewer) ([]models.Recipe, error) {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.store.ListVisible(ctx, viewer)
+}
+
+func (a withoutContext) Get(id string) (models.Recipe, error) {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.store.Get(ctx, id)
+}
+
+func (a withoutContext) Create(recipe models.Recipe) error {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.store.Create(ctx, recipe)
+}
+
+func (a withoutContext) Update(recipe models.Recipe) error {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.store.Update(ctx, recipe)
+}
+
+func (a withoutContext) Delete(id string) error {
+	ctx, cancel := a.context()
+	defer cancel()
+	return a.store.Delete(ctx, id)
+}
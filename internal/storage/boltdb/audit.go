@@ -0,0 +1,111 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var auditBucket = []byte("audit")
+
+// auditID turns the bucket's sequence number into an entry ID
+// Zero padding makes the IDs, and so the keys, sort in the order they were added
+func auditID(seq uint64) string {
+	return fmt.Sprintf("%012d", seq)
+}
+
+// AppendAudit adds an entry to the end of the audit log
+func (s *Store) AppendAudit(entry models.AuditEntry) (models.AuditEntry, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(auditBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("could not number audit entry: %v", err)
+		}
+		entry.ID = auditID(seq)
+
+		buf, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("could not marshal audit entry: %v", err)
+		}
+		if err := b.Put([]byte(entry.ID), buf); err != nil {
+			return fmt.Errorf("could not store audit entry: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	s.logger.Debug("audit entry added", slog.String("id", entry.ID),
+		slog.String("action", string(entry.Action)), slog.String("recipe_id", entry.RecipeID))
+	return entry, nil
+}
+
+// GetAudit reads one audit entry
+func (s *Store) GetAudit(id string) (models.AuditEntry, error) {
+	var entry models.AuditEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(auditBucket).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("audit entry %s: %w", id, storage.ErrNotFound)
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("could not unmarshal audit entry: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	return entry, nil
+}
+
+// ListAudit returns the entries matching filter, newest first
+// It walks the bucket backwards from the end, so recent pages don't read the whole log
+func (s *Store) ListAudit(filter storage.AuditFilter) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(auditBucket).Cursor()
+
+		k, v := c.Last()
+		if filter.Before != "" {
+			// Seek finds the first key at or after Before; step back past it
+			k, v = c.Seek([]byte(filter.Before))
+			if k == nil {
+				k, v = c.Last()
+			}
+			for k != nil && string(k) >= filter.Before {
+				k, v = c.Prev()
+			}
+		}
+
+		for ; k != nil; k, v = c.Prev() {
+			var entry models.AuditEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("could not unmarshal audit entry: %v", err)
+			}
+			// Entries are in time order, so once we're before Since nothing further back can match
+			if !filter.Since.IsZero() && entry.Time.Before(filter.Since) {
+				break
+			}
+			if !filter.Match(entry) {
+				continue
+			}
+			entries = append(entries, entry)
+			if filter.Limit > 0 && len(entries) == filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,83 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// cookProgressBucket holds a bucket per recipe, keyed by user ID within it
+// so deleting a recipe can drop everyone's progress at once
+var cookProgressBucket = []byte("cook_progress")
+
+// GetCookProgress reads a user's saved cook mode state for a recipe
+func (s *Store) GetCookProgress(userID, recipeID string) (models.CookProgress, error) {
+	var progress models.CookProgress
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var data []byte
+		if b := tx.Bucket(cookProgressBucket).Bucket([]byte(recipeID)); b != nil {
+			data = b.Get([]byte(userID))
+		}
+		if data == nil {
+			return fmt.Errorf("cook progress for %s: %w", recipeID, storage.ErrNotFound)
+		}
+		if err := json.Unmarshal(data, &progress); err != nil {
+			return fmt.Errorf("could not unmarshal cook progress: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.CookProgress{}, err
+	}
+	return progress, nil
+}
+
+// SaveCookProgress creates or replaces a user's cook mode state for a recipe
+func (s *Store) SaveCookProgress(progress models.CookProgress) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		buf, err := json.Marshal(progress)
+		if err != nil {
+			return fmt.Errorf("could not marshal cook progress: %v", err)
+		}
+		b, err := tx.Bucket(cookProgressBucket).CreateBucketIfNotExists([]byte(progress.RecipeID))
+		if err != nil {
+			return fmt.Errorf("could not create cook progress bucket: %v", err)
+		}
+		if err := b.Put([]byte(progress.UserID), buf); err != nil {
+			return fmt.Errorf("could not store cook progress: %v", err)
+		}
+		return nil
+	})
+}
+
+// DeleteCookProgress forgets a user's cook mode state for a recipe
+// Deleting progress that was never saved is not an error
+func (s *Store) DeleteCookProgress(userID, recipeID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(cookProgressBucket).Bucket([]byte(recipeID))
+		if b == nil {
+			return nil
+		}
+		if err := b.Delete([]byte(userID)); err != nil {
+			return fmt.Errorf("could not delete cook progress: %v", err)
+		}
+		return nil
+	})
+}
+
+// DeleteRecipeCookProgress forgets every user's cook mode state for a recipe
+func (s *Store) DeleteRecipeCookProgress(recipeID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(cookProgressBucket).DeleteBucket([]byte(recipeID))
+		if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return fmt.Errorf("could not delete cook progress: %v", err)
+		}
+		return nil
+	})
+}
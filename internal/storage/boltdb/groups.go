@@ -0,0 +1,116 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var groupBucket = []byte("groups")
+
+// ListGroups returns every group
+func (s *Store) ListGroups() ([]models.Group, error) {
+	return s.listGroups(func(models.Group) bool { return true })
+}
+
+// ListGroupsForUser returns the groups a user owns or belongs to
+func (s *Store) ListGroupsForUser(userID string) ([]models.Group, error) {
+	return s.listGroups(func(group models.Group) bool {
+		_, ok := group.RoleOf(userID)
+		return ok
+	})
+}
+
+func (s *Store) listGroups(keep func(models.Group) bool) ([]models.Group, error) {
+	var groups []models.Group
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(groupBucket).ForEach(func(k, v []byte) error {
+			var group models.Group
+			if err := json.Unmarshal(v, &group); err != nil {
+				return fmt.Errorf("could not unmarshal group: %v", err)
+			}
+			if keep(group) {
+				groups = append(groups, group)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetGroup reads a group by ID
+func (s *Store) GetGroup(id string) (models.Group, error) {
+	var group models.Group
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(groupBucket).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("group %s: %w", id, storage.ErrNotFound)
+		}
+		if err := json.Unmarshal(data, &group); err != nil {
+			return fmt.Errorf("could not unmarshal group: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Group{}, err
+	}
+	return group, nil
+}
+
+// CreateGroup stores a new group
+func (s *Store) CreateGroup(group models.Group) error {
+	s.logger.Debug("creating group", slog.String("id", group.ID), slog.String("name", group.Name))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(groupBucket).Get([]byte(group.ID)) != nil {
+			return fmt.Errorf("group %s: %w", group.ID, storage.ErrAlreadyExists)
+		}
+		return putGroup(tx, group)
+	})
+}
+
+// UpdateGroup replaces an existing group
+func (s *Store) UpdateGroup(group models.Group) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(groupBucket).Get([]byte(group.ID)) == nil {
+			return fmt.Errorf("group %s: %w", group.ID, storage.ErrNotFound)
+		}
+		return putGroup(tx, group)
+	})
+}
+
+// DeleteGroup removes a group
+// Recipes shared with it fall back to being visible to their owners only
+func (s *Store) DeleteGroup(id string) error {
+	s.logger.Debug("deleting group", slog.String("id", id))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(groupBucket)
+		if b.Get([]byte(id)) == nil {
+			return fmt.Errorf("group %s: %w", id, storage.ErrNotFound)
+		}
+		if err := b.Delete([]byte(id)); err != nil {
+			return fmt.Errorf("could not delete group: %v", err)
+		}
+		return nil
+	})
+}
+
+func putGroup(tx *bolt.Tx, group models.Group) error {
+	buf, err := json.Marshal(group)
+	if err != nil {
+		return fmt.Errorf("could not marshal group: %v", err)
+	}
+	if err := tx.Bucket(groupBucket).Put([]byte(group.ID), buf); err != nil {
+		return fmt.Errorf("could not store group: %v", err)
+	}
+	return nil
+}
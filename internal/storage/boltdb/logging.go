@@ -0,0 +1,77 @@
+package boltdb
+
+import (
+	"context"
+	"go_recipe_app/internal/logging"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// component tags every log line from the store
+const component = "storage"
+
+// log returns the logger for an operation
+// Inside a request that's the request's logger, so the lines carry its request ID
+func (s *Store) log(ctx context.Context) *slog.Logger {
+	if logger := logging.FromContextOr(ctx, nil); logger != nil {
+		return logger.With("component", component)
+	}
+	return s.logger
+}
+
+// finish logs a finished operation at debug level, and warns if it was slow
+func (s *Store) finish(ctx context.Context, op string, start time.Time, err error, attrs ...any) {
+	elapsed := time.Since(start)
+	logger := s.log(ctx)
+
+	attrs = append(attrs, slog.String("op", op), slog.Duration("duration", elapsed))
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	logger.Debug("store operation", attrs...)
+
+	if s.slow == nil || elapsed < s.slow.threshold {
+		return
+	}
+	if ok, suppressed := s.slow.sample(op, time.Now()); ok {
+		attrs = append(attrs, slog.Duration("threshold", s.slow.threshold), slog.Int("suppressed", suppressed))
+		logger.Warn("slow store operation", attrs...)
+	}
+}
+
+// slowLog limits slow-operation warnings to one per operation per interval
+// A struggling disk makes everything slow at once, and a warning per call would bury the logs;
+// the warnings that do get through say how many were skipped
+type slowLog struct {
+	threshold time.Duration
+	interval  time.Duration
+
+	mu         sync.Mutex
+	last       map[string]time.Time
+	suppressed map[string]int
+}
+
+func newSlowLog(threshold, interval time.Duration) *slowLog {
+	return &slowLog{
+		threshold:  threshold,
+		interval:   interval,
+		last:       make(map[string]time.Time),
+		suppressed: make(map[string]int),
+	}
+}
+
+// sample reports whether a slow op should be logged now, and how many were skipped since the last one
+func (l *slowLog) sample(op string, now time.Time) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if last, ok := l.last[op]; ok && now.Sub(last) < l.interval {
+		l.suppressed[op]++
+		return false, 0
+	}
+	suppressed := l.suppressed[op]
+	l.last[op] = now
+	l.suppressed[op] = 0
+	return true, suppressed
+}
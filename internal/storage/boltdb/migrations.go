@@ -0,0 +1,148 @@
+package boltdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"go_recipe_app/internal/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// migration upgrades the data in a single transaction
+// Migrations run in order and each one runs exactly once per database
+type migration struct {
+	name string
+	run  func(tx *bolt.Tx) error
+}
+
+// migrations is the ordered list of schema changes
+// Append new migrations to the end - never reorder or remove them
+var migrations = []migration{
+	{name: "default ingredient and instruction sections", run: migrateSections},
+	{name: "recipe visibility", run: migrateVisibility},
+	{name: "cook progress per user", run: migrateCookProgress},
+}
+
+// migrate applies every migration newer than the stored schema version
+func migrate(db *bolt.DB, logger *slog.Logger) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return fmt.Errorf("could not create meta bucket: %v", err)
+		}
+
+		version := 0
+		if v := meta.Get(schemaVersionKey); v != nil {
+			version = int(binary.BigEndian.Uint64(v))
+		}
+
+		for i := version; i < len(migrations); i++ {
+			logger.Info("running migration", slog.Int("version", i+1), slog.String("name", migrations[i].name))
+			if err := migrations[i].run(tx); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %v", i+1, migrations[i].name, err)
+			}
+		}
+
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(len(migrations)))
+		return meta.Put(schemaVersionKey, buf)
+	})
+}
+
+// updateRecipes rewrites every stored recipe through fn
+func updateRecipes(tx *bolt.Tx, fn func(recipe *models.Recipe)) error {
+	b := tx.Bucket(recipeBucket)
+
+	// Collect first - bolt does not allow modifying a bucket during ForEach
+	updated := make(map[string][]byte)
+	err := b.ForEach(func(k, v []byte) error {
+		var recipe models.Recipe
+		if err := json.Unmarshal(v, &recipe); err != nil {
+			return fmt.Errorf("could not unmarshal recipe %s: %v", k, err)
+		}
+		fn(&recipe)
+		buf, err := json.Marshal(recipe)
+		if err != nil {
+			return fmt.Errorf("could not marshal recipe %s: %v", k, err)
+		}
+		updated[string(k)] = buf
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, v := range updated {
+		if err := b.Put([]byte(k), v); err != nil {
+			return fmt.Errorf("could not store recipe %s: %v", k, err)
+		}
+	}
+	return nil
+}
+
+// migrateSections puts every existing ingredient and step into the default section
+func migrateSections(tx *bolt.Tx) error {
+	return updateRecipes(tx, func(recipe *models.Recipe) {
+		recipe.Normalize()
+	})
+}
+
+// migrateVisibility makes existing recipes public, since everyone could see them before
+func migrateVisibility(tx *bolt.Tx) error {
+	return updateRecipes(tx, func(recipe *models.Recipe) {
+		if recipe.Visibility == "" {
+			recipe.Visibility = models.VisibilityPublic
+		}
+	})
+}
+
+// migrateCookProgress moves progress saved under just a recipe ID to the recipe's owner,
+// who was the only one who could save it while progress wasn't kept per user
+// Progress for a recipe that's gone or has no owner is dropped
+func migrateCookProgress(tx *bolt.Tx) error {
+	b := tx.Bucket(cookProgressBucket)
+	recipes := tx.Bucket(recipeBucket)
+
+	// Collect first - bolt does not allow modifying a bucket during ForEach
+	old := make(map[string][]byte)
+	err := b.ForEach(func(k, v []byte) error {
+		if v != nil { // nil values are the new per-recipe buckets
+			old[string(k)] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for recipeID, data := range old {
+		if err := b.Delete([]byte(recipeID)); err != nil {
+			return fmt.Errorf("could not delete cook progress %s: %v", recipeID, err)
+		}
+
+		var recipe models.Recipe
+		if v := recipes.Get([]byte(recipeID)); v == nil || json.Unmarshal(v, &recipe) != nil || recipe.OwnerID == "" {
+			continue
+		}
+		var progress models.CookProgress
+		if err := json.Unmarshal(data, &progress); err != nil {
+			continue
+		}
+		progress.UserID = recipe.OwnerID
+		buf, err := json.Marshal(progress)
+		if err != nil {
+			return fmt.Errorf("could not marshal cook progress %s: %v", recipeID, err)
+		}
+		owner, err := b.CreateBucket([]byte(recipeID))
+		if err != nil {
+			return fmt.Errorf("could not create cook progress bucket %s: %v", recipeID, err)
+		}
+		if err := owner.Put([]byte(recipe.OwnerID), buf); err != nil {
+			return fmt.Errorf("could not store cook progress %s: %v", recipeID, err)
+		}
+	}
+	return nil
+}
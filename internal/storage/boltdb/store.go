@@ -1,51 +1,106 @@
 package boltdb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"time"
 
 	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
 
 	bolt "go.etcd.io/bbolt"
 )
 
-var recipeBucket = []byte("recipes")
+var (
+	recipeBucket = []byte("recipes")
+	metaBucket   = []byte("meta")
+
+	schemaVersionKey = []byte("schema_version")
+)
+
+// buckets lists every top-level bucket the store uses
+var buckets = [][]byte{
+	recipeBucket, metaBucket, cookProgressBucket,
+	userBucket, usernameBucket, sessionBucket,
+	tokenBucket, tokenHashBucket, groupBucket,
+	auditBucket,
+}
 
 type Store struct {
-	db     *bolt.DB
-	logger *log.Logger
+	db      *bolt.DB
+	logger  *slog.Logger
+	timeout time.Duration
+	slow    *slowLog // nil when slow operation warnings are off
+}
+
+// Options configures the store
+type Options struct {
+	// Timeout bounds waiting for the file lock on open, and every recipe operation
+	// that doesn't already have an earlier deadline. Zero means one second
+	Timeout time.Duration
+
+	// Logger receives the store's logs, tagged component=storage. Nil discards them
+	Logger *slog.Logger
+
+	// Recipe operations slower than SlowThreshold log a warning; zero turns the warnings off
+	// At most one is logged per operation every SlowLogInterval, which defaults to a minute
+	SlowThreshold   time.Duration
+	SlowLogInterval time.Duration
 }
 
 // New creates a new BoltDB store
-func New(dbPath string) (*Store, error) {
-	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+func New(dbPath string, opts Options) (*Store, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.SlowLogInterval <= 0 {
+		opts.SlowLogInterval = time.Minute
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	logger = logger.With("component", component)
+
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: opts.Timeout})
 	if err != nil {
 		return nil, fmt.Errorf("could not open db: %v", err)
 	}
+	logger.Info("opening database", slog.String("path", dbPath))
 
-	logger := log.New(os.Stdout, "[BOLTDB] ", log.LstdFlags)
-	logger.Printf("Opening database at %s", dbPath)
-
-	// Create recipes bucket if it doesn't exist
+	// Create buckets if they don't exist
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(recipeBucket)
-		if err != nil {
-			return fmt.Errorf("could not create recipes bucket: %v", err)
+		for _, bucket := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return fmt.Errorf("could not create %s bucket: %v", bucket, err)
+			}
 		}
-		logger.Println("Recipes bucket created/verified")
+		logger.Debug("buckets created or verified")
 		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &Store{
-		db:     db,
-		logger: logger,
-	}, nil
+	// Bring older databases up to the current schema
+	if err := migrate(db, logger); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := &Store{
+		db:      db,
+		logger:  logger,
+		timeout: opts.Timeout,
+	}
+	if opts.SlowThreshold > 0 {
+		store.slow = newSlowLog(opts.SlowThreshold, opts.SlowLogInterval)
+	}
+	return store, nil
 }
 
 // Close closes the database
@@ -53,12 +108,49 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
+// Ping checks the database can complete a read transaction
+// It fails once the database is closed, or while a long write holds the file lock
+func (s *Store) Ping() error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(recipeBucket) == nil {
+			return fmt.Errorf("bucket %s not found", recipeBucket)
+		}
+		return nil
+	})
+}
+
+// withTimeout applies the store's timeout, unless the caller's deadline comes sooner
+func (s *Store) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.timeout)
+}
+
 // Create stores a new recipe
-func (s *Store) Create(recipe models.Recipe) error {
-	s.logger.Printf("Creating recipe: ID=%s, Title=%s", recipe.ID, recipe.Title)
+// It fails with storage.ErrAlreadyExists rather than overwrite a recipe with the same ID
+func (s *Store) Create(ctx context.Context, recipe models.Recipe) (err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	defer func(start time.Time) {
+		s.finish(ctx, "Create", start, err, slog.String("id", recipe.ID))
+	}(time.Now())
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if recipe.ID == "" {
+		return fmt.Errorf("recipe ID is required")
+	}
+
 	return s.db.Update(func(tx *bolt.Tx) error {
+		// Waiting for the write lock may have used up the deadline
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		b := tx.Bucket(recipeBucket)
 
+		// Put would silently replace an existing recipe
+		if existing := b.Get([]byte(recipe.ID)); existing != nil {
+			return fmt.Errorf("recipe %s: %w", recipe.ID, storage.ErrAlreadyExists)
+		}
+
 		// Convert recipe to JSON
 		buf, err := json.Marshal(recipe)
 		if err != nil {
@@ -70,22 +162,26 @@ func (s *Store) Create(recipe models.Recipe) error {
 		if err != nil {
 			return fmt.Errorf("could not store recipe: %v", err)
 		}
-
-		s.logger.Printf("Successfully created recipe: %s", recipe.ID)
 		return nil
 	})
 }
 
 // Get reads a recipe from the DB
-func (s *Store) Get(id string) (models.Recipe, error) {
-	s.logger.Printf("Fetching recipe: %s", id)
-	var recipe models.Recipe
+func (s *Store) Get(ctx context.Context, id string) (recipe models.Recipe, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	defer func(start time.Time) {
+		s.finish(ctx, "Get", start, err, slog.String("id", id))
+	}(time.Now())
+	if err := ctx.Err(); err != nil {
+		return models.Recipe{}, err
+	}
 
-	err := s.db.View(func(tx *bolt.Tx) error {
+	err = s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(recipeBucket)
 		data := b.Get([]byte(id))
 		if data == nil {
-			return fmt.Errorf("recipe not found: %s", id)
+			return fmt.Errorf("recipe %s: %w", id, storage.ErrNotFound)
 		}
 
 		if err := json.Unmarshal(data, &recipe); err != nil {
@@ -93,53 +189,85 @@ func (s *Store) Get(id string) (models.Recipe, error) {
 		}
 		return nil
 	})
-
 	if err != nil {
-		s.logger.Printf("Error fetching recipe: %v", err)
 		return models.Recipe{}, err
 	}
-
-	s.logger.Printf("Retrieved recipe: %s - %s", recipe.ID, recipe.Title)
 	return recipe, nil
 }
 
-// Lists all recipes in the DB
-func (s *Store) List() ([]models.Recipe, error) {
-	s.logger.Println("Listing all recipes")
-	var recipes []models.Recipe
+// Lists all recipes in the DB, ordered by ID
+func (s *Store) List(ctx context.Context) (recipes []models.Recipe, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	defer func(start time.Time) {
+		s.finish(ctx, "List", start, err, slog.Int("count", len(recipes)))
+	}(time.Now())
 
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(recipeBucket)
+	return s.scan(ctx, func(models.Recipe) bool { return true })
+}
 
-		return b.ForEach(func(k, v []byte) error {
+// ListVisible returns the recipes the viewer is allowed to see, ordered by ID
+// The filter runs inside the read transaction so hidden recipes never leave the store
+func (s *Store) ListVisible(ctx context.Context, viewer models.Viewer) (recipes []models.Recipe, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	defer func(start time.Time) {
+		s.finish(ctx, "ListVisible", start, err, slog.Int("count", len(recipes)))
+	}(time.Now())
+
+	return s.scan(ctx, func(recipe models.Recipe) bool { return recipe.VisibleTo(viewer) })
+}
+
+// scan reads every recipe, keeping the ones keep accepts
+// bbolt keeps keys sorted, so the recipes come out in ID order
+// It checks ctx between recipes so an abandoned request stops decoding straight away
+func (s *Store) scan(ctx context.Context, keep func(models.Recipe) bool) ([]models.Recipe, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var recipes []models.Recipe
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recipeBucket).ForEach(func(k, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 			var recipe models.Recipe
 			if err := json.Unmarshal(v, &recipe); err != nil {
 				return fmt.Errorf("could not unmarshal recipe: %v", err)
 			}
-			recipes = append(recipes, recipe)
+			if keep(recipe) {
+				recipes = append(recipes, recipe)
+			}
 			return nil
 		})
 	})
-
 	if err != nil {
-		s.logger.Printf("Error listing recipes: %v", err)
 		return nil, err
 	}
-
-	s.logger.Printf("Found %d recipes", len(recipes))
 	return recipes, nil
 }
 
 // Updates a recipe
-func (s *Store) Update(recipe models.Recipe) error {
-	s.logger.Printf("Updating recipe with ID: %s", recipe.ID)
+func (s *Store) Update(ctx context.Context, recipe models.Recipe) (err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	defer func(start time.Time) {
+		s.finish(ctx, "Update", start, err, slog.String("id", recipe.ID))
+	}(time.Now())
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		b := tx.Bucket(recipeBucket)
 
 		// Check if recipe exists
 		if existing := b.Get([]byte(recipe.ID)); existing == nil {
-			return fmt.Errorf("recipe not found: %s", recipe.ID)
+			return fmt.Errorf("recipe %s: %w", recipe.ID, storage.ErrNotFound)
 		}
 
 		buf, err := json.Marshal(recipe)
@@ -156,15 +284,25 @@ func (s *Store) Update(recipe models.Recipe) error {
 }
 
 // Deletes a recipe
-func (s *Store) Delete(id string) error {
-	s.logger.Printf("Deleting recipe with ID: %s", id)
+func (s *Store) Delete(ctx context.Context, id string) (err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	defer func(start time.Time) {
+		s.finish(ctx, "Delete", start, err, slog.String("id", id))
+	}(time.Now())
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		b := tx.Bucket(recipeBucket)
 
 		// Check if recipe exists
 		if existing := b.Get([]byte(id)); existing == nil {
-			return fmt.Errorf("recipe not found: %s", id)
+			return fmt.Errorf("recipe %s: %w", id, storage.ErrNotFound)
 		}
 
 		if err := b.Delete([]byte(id)); err != nil {
@@ -174,3 +312,38 @@ func (s *Store) Delete(id string) error {
 		return nil
 	})
 }
+
+// Stats is a snapshot of the database's counters for monitoring
+type Stats struct {
+	ReadTxTotal   int   // read transactions started since the database was opened
+	OpenReadTx    int   // read transactions open right now
+	PageAllocs    int64 // pages allocated by write transactions
+	PageAllocSize int64 // bytes allocated for those pages
+	FreePages     int   // pages on the freelist, ready for reuse
+	PendingPages  int   // freed pages still held by open read transactions
+	FreeBytes     int   // bytes in free pages
+	FreelistBytes int   // bytes used by the freelist itself
+	FileSize      int64
+}
+
+// Stats returns the database's counters and the size of its file
+func (s *Store) Stats() (Stats, error) {
+	st := s.db.Stats()
+	stats := Stats{
+		ReadTxTotal:   st.TxN,
+		OpenReadTx:    st.OpenTxN,
+		PageAllocs:    st.TxStats.GetPageCount(),
+		PageAllocSize: st.TxStats.GetPageAlloc(),
+		FreePages:     st.FreePageN,
+		PendingPages:  st.PendingPageN,
+		FreeBytes:     st.FreeAlloc,
+		FreelistBytes: st.FreelistInuse,
+	}
+
+	info, err := os.Stat(s.db.Path())
+	if err != nil {
+		return stats, fmt.Errorf("could not stat database file: %v", err)
+	}
+	stats.FileSize = info.Size()
+	return stats, nil
+}
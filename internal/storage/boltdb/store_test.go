@@ -4,13 +4,24 @@ package boltdb
 // ... is a go specific wildcard operator that means "test this package and all subpackages"
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
 	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"go_recipe_app/internal/storage/storagetest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	bolt "go.etcd.io/bbolt"
 )
 
+// ctx is used for recipe calls that aren't testing cancellation
+var ctx = context.Background()
+
 func setupTestDB(t *testing.T) (*Store, string) {
 	// Create a temporary directory for the test database
 	tempDir, err := os.MkdirTemp("", "recipe-test-*")
@@ -19,7 +30,7 @@ func setupTestDB(t *testing.T) (*Store, string) {
 	}
 
 	dbPath := filepath.Join(tempDir, "test.db")
-	store, err := New(dbPath)
+	store, err := New(dbPath, Options{})
 	if err != nil {
 		os.RemoveAll(tempDir)
 		t.Fatalf("Failed to create test store: %v", err)
@@ -33,6 +44,18 @@ func cleanupTestDB(store *Store, tempDir string) {
 	os.RemoveAll(tempDir)
 }
 
+// The shared suite every recipe store must pass
+func TestConformance(t *testing.T) {
+	storagetest.TestRecipeStore(t, func(t *testing.T) storage.ContextRecipeStore {
+		store, err := New(filepath.Join(t.TempDir(), "test.db"), Options{})
+		if err != nil {
+			t.Fatalf("Failed to create test store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
 func createTestRecipe() models.Recipe {
 	return models.Recipe{
 		ID:          "test-recipe-1",
@@ -67,13 +90,13 @@ func TestCreateAndGet(t *testing.T) {
 	recipe := createTestRecipe()
 
 	// Test Create
-	err := store.Create(recipe)
+	err := store.Create(ctx, recipe)
 	if err != nil {
 		t.Errorf("Failed to create recipe: %v", err)
 	}
 
 	// Test Get
-	retrieved, err := store.Get(recipe.ID)
+	retrieved, err := store.Get(ctx, recipe.ID)
 	if err != nil {
 		t.Errorf("Failed to get recipe: %v", err)
 	}
@@ -104,13 +127,13 @@ func TestList(t *testing.T) {
 	}
 
 	for _, recipe := range recipes {
-		if err := store.Create(recipe); err != nil {
+		if err := store.Create(ctx, recipe); err != nil {
 			t.Fatalf("Failed to create recipe: %v", err)
 		}
 	}
 
 	// Test List
-	listed, err := store.List()
+	listed, err := store.List(ctx)
 	if err != nil {
 		t.Errorf("Failed to list recipes: %v", err)
 	}
@@ -127,7 +150,7 @@ func TestUpdate(t *testing.T) {
 	recipe := createTestRecipe()
 
 	// Create initial recipe
-	if err := store.Create(recipe); err != nil {
+	if err := store.Create(ctx, recipe); err != nil {
 		t.Fatalf("Failed to create recipe: %v", err)
 	}
 
@@ -136,13 +159,13 @@ func TestUpdate(t *testing.T) {
 	recipe.Description = "Updated description"
 
 	// Test Update
-	err := store.Update(recipe)
+	err := store.Update(ctx, recipe)
 	if err != nil {
 		t.Errorf("Failed to update recipe: %v", err)
 	}
 
 	// Verify update
-	updated, err := store.Get(recipe.ID)
+	updated, err := store.Get(ctx, recipe.ID)
 	if err != nil {
 		t.Errorf("Failed to get updated recipe: %v", err)
 	}
@@ -159,18 +182,18 @@ func TestDelete(t *testing.T) {
 	recipe := createTestRecipe()
 
 	// Create recipe
-	if err := store.Create(recipe); err != nil {
+	if err := store.Create(ctx, recipe); err != nil {
 		t.Fatalf("Failed to create recipe: %v", err)
 	}
 
 	// Test Delete
-	err := store.Delete(recipe.ID)
+	err := store.Delete(ctx, recipe.ID)
 	if err != nil {
 		t.Errorf("Failed to delete recipe: %v", err)
 	}
 
 	// Verify deletion
-	_, err = store.Get(recipe.ID)
+	_, err = store.Get(ctx, recipe.ID)
 	if err == nil {
 		t.Error("Recipe still exists after deletion")
 	}
@@ -181,20 +204,352 @@ func TestErrorCases(t *testing.T) {
 	defer cleanupTestDB(store, tempDir)
 
 	// Test getting non-existent recipe
-	_, err := store.Get("non-existent")
+	_, err := store.Get(ctx, "non-existent")
 	if err == nil {
 		t.Error("Expected error when getting non-existent recipe")
 	}
 
 	// Test updating non-existent recipe
-	err = store.Update(models.Recipe{ID: "non-existent"})
+	err = store.Update(ctx, models.Recipe{ID: "non-existent"})
 	if err == nil {
 		t.Error("Expected error when updating non-existent recipe")
 	}
 
 	// Test deleting non-existent recipe
-	err = store.Delete("non-existent")
+	err = store.Delete(ctx, "non-existent")
 	if err == nil {
 		t.Error("Expected error when deleting non-existent recipe")
 	}
 }
+
+func TestListVisible(t *testing.T) {
+	store, tempDir := setupTestDB(t)
+	defer cleanupTestDB(store, tempDir)
+
+	recipes := []models.Recipe{
+		{ID: "private", OwnerID: "owner", Visibility: models.VisibilityPrivate},
+		{ID: "group", OwnerID: "owner", Visibility: models.VisibilityGroup, GroupID: "house"},
+		{ID: "other-group", OwnerID: "owner", Visibility: models.VisibilityGroup, GroupID: "elsewhere"},
+		{ID: "public", OwnerID: "owner", Visibility: models.VisibilityPublic},
+	}
+	for _, recipe := range recipes {
+		if err := store.Create(ctx, recipe); err != nil {
+			t.Fatalf("Failed to create recipe: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		viewer models.Viewer
+		want   []string
+	}{
+		{"visitor", models.Viewer{}, []string{"public"}},
+		{"owner", models.Viewer{UserID: "owner"}, []string{"group", "other-group", "private", "public"}},
+		{"group member", models.Viewer{UserID: "member", Groups: map[string]models.GroupRole{"house": models.GroupViewer}}, []string{"group", "public"}},
+		{"admin", models.Viewer{UserID: "admin", Admin: true}, []string{"group", "other-group", "private", "public"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visible, err := store.ListVisible(ctx, tt.viewer)
+			if err != nil {
+				t.Fatalf("Failed to list recipes: %v", err)
+			}
+			var got []string
+			for _, recipe := range visible {
+				got = append(got, recipe.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	store, tempDir := setupTestDB(t)
+	defer cleanupTestDB(store, tempDir)
+
+	if err := store.Create(ctx, createTestRecipe()); err != nil {
+		t.Fatalf("Failed to create recipe: %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.List(canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("List with a canceled context: got %v, want context.Canceled", err)
+	}
+	if err := store.Delete(canceled, "test-recipe-1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Delete with a canceled context: got %v, want context.Canceled", err)
+	}
+	if _, err := store.Get(ctx, "test-recipe-1"); err != nil {
+		t.Errorf("recipe should survive the canceled delete: %v", err)
+	}
+}
+
+func TestSlowLogSampling(t *testing.T) {
+	l := newSlowLog(100*time.Millisecond, time.Minute)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if ok, _ := l.sample("List", start); !ok {
+		t.Fatal("the first slow List should be logged")
+	}
+	for i := 1; i <= 3; i++ {
+		if ok, _ := l.sample("List", start.Add(time.Duration(i)*time.Second)); ok {
+			t.Fatal("slow List logged again within the interval")
+		}
+	}
+	if ok, _ := l.sample("Get", start.Add(time.Second)); !ok {
+		t.Error("each operation should be sampled separately")
+	}
+
+	ok, suppressed := l.sample("List", start.Add(time.Minute))
+	if !ok || suppressed != 3 {
+		t.Errorf("after the interval: ok=%v suppressed=%d, want true and 3", ok, suppressed)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	store, tempDir := setupTestDB(t)
+	defer cleanupTestDB(store, tempDir)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		action := models.AuditUpdate
+		if i == 2 {
+			action = models.AuditDelete
+		}
+		entry, err := store.AppendAudit(models.AuditEntry{
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Action:   action,
+			RecipeID: "recipe-1",
+		})
+		if err != nil {
+			t.Fatalf("AppendAudit: %v", err)
+		}
+		if entry.ID == "" {
+			t.Fatal("AppendAudit didn't assign an ID")
+		}
+	}
+
+	all, err := store.ListAudit(storage.AuditFilter{})
+	if err != nil || len(all) != 5 {
+		t.Fatalf("ListAudit = %d entries, %v; want 5", len(all), err)
+	}
+	if !all[0].Time.After(all[4].Time) {
+		t.Error("ListAudit should return the newest entry first")
+	}
+
+	// Paging: two at a time, continuing from the last entry of the previous page
+	page, _ := store.ListAudit(storage.AuditFilter{Limit: 2})
+	next, _ := store.ListAudit(storage.AuditFilter{Limit: 2, Before: page[1].ID})
+	if len(next) != 2 || next[0].ID != all[2].ID {
+		t.Errorf("second page = %v, want to start at %s", next, all[2].ID)
+	}
+
+	deletes, _ := store.ListAudit(storage.AuditFilter{Action: models.AuditDelete})
+	if len(deletes) != 1 {
+		t.Errorf("action filter found %d entries, want 1", len(deletes))
+	}
+
+	since, _ := store.ListAudit(storage.AuditFilter{Since: start.Add(3 * time.Hour)})
+	if len(since) != 2 {
+		t.Errorf("since filter found %d entries, want 2", len(since))
+	}
+}
+
+func TestCookProgress(t *testing.T) {
+	store, tempDir := setupTestDB(t)
+	defer cleanupTestDB(store, tempDir)
+
+	// Two people cooking the same recipe keep their own place
+	for user, step := range map[string]int{"alice": 2, "bob": 5} {
+		if err := store.SaveCookProgress(models.CookProgress{UserID: user, RecipeID: "soup", Step: step}); err != nil {
+			t.Fatalf("Failed to save cook progress: %v", err)
+		}
+	}
+	if p, err := store.GetCookProgress("alice", "soup"); err != nil || p.Step != 2 {
+		t.Errorf("alice's progress = %+v, %v; want step 2", p, err)
+	}
+	if err := store.DeleteCookProgress("bob", "soup"); err != nil {
+		t.Fatalf("Failed to delete cook progress: %v", err)
+	}
+	if _, err := store.GetCookProgress("bob", "soup"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("bob's progress after reset: %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetCookProgress("alice", "soup"); err != nil {
+		t.Errorf("resetting bob's progress lost alice's: %v", err)
+	}
+
+	// Deleting a recipe drops everyone's progress, and deleting twice is fine
+	for i := 0; i < 2; i++ {
+		if err := store.DeleteRecipeCookProgress("soup"); err != nil {
+			t.Fatalf("Failed to delete recipe cook progress: %v", err)
+		}
+	}
+	if _, err := store.GetCookProgress("alice", "soup"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("alice's progress after the recipe went: %v, want ErrNotFound", err)
+	}
+}
+
+func TestDeleteSessions(t *testing.T) {
+	store, tempDir := setupTestDB(t)
+	defer cleanupTestDB(store, tempDir)
+
+	now := time.Now().UTC()
+	for _, session := range []models.Session{
+		{ID: "alice-laptop", UserID: "alice", ExpiresAt: now.Add(time.Hour)},
+		{ID: "alice-phone", UserID: "alice", ExpiresAt: now.Add(time.Hour)},
+		{ID: "alice-old", UserID: "alice", ExpiresAt: now.Add(-time.Hour)},
+		{ID: "bob-laptop", UserID: "bob", ExpiresAt: now.Add(time.Hour)},
+	} {
+		if err := store.CreateSession(session); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	if deleted, err := store.DeleteExpiredSessions(now); err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredSessions = %d, %v; want 1", deleted, err)
+	}
+	if deleted, err := store.DeleteUserSessions("alice", "alice-laptop"); err != nil || deleted != 1 {
+		t.Fatalf("DeleteUserSessions = %d, %v; want 1", deleted, err)
+	}
+	for id, kept := range map[string]bool{"alice-laptop": true, "alice-phone": false, "alice-old": false, "bob-laptop": true} {
+		if _, err := store.GetSession(id); (err == nil) != kept {
+			t.Errorf("session %s: %v, want kept: %v", id, err, kept)
+		}
+	}
+}
+
+func TestMigrateCookProgress(t *testing.T) {
+	store, tempDir := setupTestDB(t)
+	defer cleanupTestDB(store, tempDir)
+
+	// Progress as it was saved before it was kept per user: straight under the recipe ID
+	recipe := createTestRecipe()
+	recipe.OwnerID = "alice"
+	if err := store.Create(ctx, recipe); err != nil {
+		t.Fatalf("Failed to create recipe: %v", err)
+	}
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(cookProgressBucket)
+		if err := b.Put([]byte(recipe.ID), []byte(`{"recipe_id":"test-recipe-1","step":3}`)); err != nil {
+			return err
+		}
+		if err := b.Put([]byte("deleted-recipe"), []byte(`{"recipe_id":"deleted-recipe","step":1}`)); err != nil {
+			return err
+		}
+		return migrateCookProgress(tx)
+	})
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+
+	if p, err := store.GetCookProgress("alice", recipe.ID); err != nil || p.Step != 3 || p.UserID != "alice" {
+		t.Errorf("owner's progress = %+v, %v; want step 3 moved to alice", p, err)
+	}
+	err = store.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(cookProgressBucket).Get([]byte("deleted-recipe")); v != nil {
+			t.Error("progress for a deleted recipe was kept")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+
+	// A database as it was before any migrations: no meta bucket and recipes
+	// without sections or visibility
+	db, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket(recipeBucket)
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte("old-recipe"), []byte(`{"id": "old-recipe", "title": "Old Soup",
+			"ingredients": [{"name": "Salt", "position": 1}, {"name": "Water", "position": 0}],
+			"instructions": [{"step": "Season", "position": 1}, {"step": "Boil", "position": 0}]}`)); err != nil {
+			return err
+		}
+		return b.Put([]byte("sectioned-recipe"), []byte(`{"id": "sectioned-recipe", "title": "Pie", "visibility": "private",
+			"ingredients": [{"name": "Flour", "section": "Pastry"}]}`))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := New(dbPath, Options{})
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	if got := schemaVersion(t, store); got != len(migrations) {
+		t.Errorf("schema version = %d, want %d", got, len(migrations))
+	}
+
+	old, err := store.Get(ctx, "old-recipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Visibility != models.VisibilityPublic {
+		t.Errorf("old recipe visibility = %q, want public", old.Visibility)
+	}
+	if old.Ingredients[0].Name != "Water" || old.Ingredients[0].Section != models.DefaultSection || old.Ingredients[1].Section != models.DefaultSection {
+		t.Errorf("old ingredients = %+v, want sorted into the default section", old.Ingredients)
+	}
+	if old.Instructions[0].Step != "Boil" || old.Instructions[1].Section != models.DefaultSection {
+		t.Errorf("old steps = %+v, want sorted into the default section", old.Instructions)
+	}
+
+	sectioned, err := store.Get(ctx, "sectioned-recipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sectioned.Visibility != models.VisibilityPrivate || sectioned.Ingredients[0].Section != "Pastry" {
+		t.Errorf("sectioned recipe = %+v, want its own visibility and section kept", sectioned)
+	}
+
+	// Migrations that already ran don't run again on the next open
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(recipeBucket).Put([]byte("late-recipe"), []byte(`{"id": "late-recipe", "title": "Late"}`))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	store, err = New(dbPath, Options{})
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer store.Close()
+	if got := schemaVersion(t, store); got != len(migrations) {
+		t.Errorf("schema version after reopening = %d, want %d", got, len(migrations))
+	}
+	if late, err := store.Get(ctx, "late-recipe"); err != nil || late.Visibility != "" {
+		t.Errorf("recipe written after migrating = %+v, %v; want it left alone", late, err)
+	}
+}
+
+// schemaVersion reads the stored schema version
+func schemaVersion(t *testing.T, store *Store) int {
+	t.Helper()
+	var version int
+	err := store.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(metaBucket).Get(schemaVersionKey); v != nil {
+			version = int(binary.BigEndian.Uint64(v))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
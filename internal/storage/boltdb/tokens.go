@@ -0,0 +1,136 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	tokenBucket     = []byte("tokens")
+	tokenHashBucket = []byte("token_hashes") // secret hash -> token ID
+)
+
+// ListTokens returns every token belonging to a user
+func (s *Store) ListTokens(userID string) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(tokenBucket).ForEach(func(k, v []byte) error {
+			var token models.APIToken
+			if err := json.Unmarshal(v, &token); err != nil {
+				return fmt.Errorf("could not unmarshal token: %v", err)
+			}
+			if token.UserID == userID {
+				tokens = append(tokens, token)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GetToken reads a token by ID
+func (s *Store) GetToken(id string) (models.APIToken, error) {
+	var token models.APIToken
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getToken(tx, id, &token)
+	})
+	if err != nil {
+		return models.APIToken{}, err
+	}
+	return token, nil
+}
+
+// GetTokenByHash reads a token by the hash of its secret
+func (s *Store) GetTokenByHash(hash string) (models.APIToken, error) {
+	var token models.APIToken
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(tokenHashBucket).Get([]byte(hash))
+		if id == nil {
+			return fmt.Errorf("token: %w", storage.ErrNotFound)
+		}
+		return getToken(tx, string(id), &token)
+	})
+	if err != nil {
+		return models.APIToken{}, err
+	}
+	return token, nil
+}
+
+// CreateToken stores a new token
+func (s *Store) CreateToken(token models.APIToken) error {
+	s.logger.Debug("creating api token", slog.String("id", token.ID), slog.String("user_id", token.UserID))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(tokenBucket).Get([]byte(token.ID)) != nil {
+			return fmt.Errorf("token %s: %w", token.ID, storage.ErrAlreadyExists)
+		}
+		if err := putToken(tx, token); err != nil {
+			return err
+		}
+		return tx.Bucket(tokenHashBucket).Put([]byte(token.Hash), []byte(token.ID))
+	})
+}
+
+// DeleteToken revokes a token
+func (s *Store) DeleteToken(id string) error {
+	s.logger.Debug("deleting api token", slog.String("id", id))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var token models.APIToken
+		if err := getToken(tx, id, &token); err != nil {
+			return err
+		}
+		if err := tx.Bucket(tokenHashBucket).Delete([]byte(token.Hash)); err != nil {
+			return fmt.Errorf("could not delete token hash: %v", err)
+		}
+		if err := tx.Bucket(tokenBucket).Delete([]byte(id)); err != nil {
+			return fmt.Errorf("could not delete token: %v", err)
+		}
+		return nil
+	})
+}
+
+// TouchToken records when a token was last used
+func (s *Store) TouchToken(id string, usedAt time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var token models.APIToken
+		if err := getToken(tx, id, &token); err != nil {
+			return err
+		}
+		token.LastUsedAt = usedAt
+		return putToken(tx, token)
+	})
+}
+
+func getToken(tx *bolt.Tx, id string, token *models.APIToken) error {
+	data := tx.Bucket(tokenBucket).Get([]byte(id))
+	if data == nil {
+		return fmt.Errorf("token %s: %w", id, storage.ErrNotFound)
+	}
+	if err := json.Unmarshal(data, token); err != nil {
+		return fmt.Errorf("could not unmarshal token: %v", err)
+	}
+	return nil
+}
+
+func putToken(tx *bolt.Tx, token models.APIToken) error {
+	buf, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("could not marshal token: %v", err)
+	}
+	if err := tx.Bucket(tokenBucket).Put([]byte(token.ID), buf); err != nil {
+		return fmt.Errorf("could not store token: %v", err)
+	}
+	return nil
+}
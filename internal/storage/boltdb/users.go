@@ -0,0 +1,233 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	userBucket     = []byte("users")
+	usernameBucket = []byte("usernames") // lowercased username -> user ID
+	sessionBucket  = []byte("sessions")
+)
+
+// ListUsers returns every user account
+func (s *Store) ListUsers() ([]models.User, error) {
+	var users []models.User
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(userBucket).ForEach(func(k, v []byte) error {
+			var user models.User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return fmt.Errorf("could not unmarshal user: %v", err)
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUser reads a user by ID
+func (s *Store) GetUser(id string) (models.User, error) {
+	var user models.User
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return getUser(tx, id, &user)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// GetUserByUsername reads a user by username, ignoring case
+func (s *Store) GetUserByUsername(username string) (models.User, error) {
+	var user models.User
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(usernameBucket).Get(usernameKey(username))
+		if id == nil {
+			return fmt.Errorf("user %s: %w", username, storage.ErrNotFound)
+		}
+		return getUser(tx, string(id), &user)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// CreateUser stores a new user, failing if the ID or username is taken
+func (s *Store) CreateUser(user models.User) error {
+	s.logger.Debug("creating user", slog.String("id", user.ID), slog.String("username", user.Username))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(userBucket)
+		usernames := tx.Bucket(usernameBucket)
+
+		if users.Get([]byte(user.ID)) != nil {
+			return fmt.Errorf("user %s: %w", user.ID, storage.ErrAlreadyExists)
+		}
+		if usernames.Get(usernameKey(user.Username)) != nil {
+			return fmt.Errorf("username %s: %w", user.Username, storage.ErrAlreadyExists)
+		}
+
+		if err := putUser(tx, user); err != nil {
+			return err
+		}
+		return usernames.Put(usernameKey(user.Username), []byte(user.ID))
+	})
+}
+
+// UpdateUser replaces an existing user, keeping the username index in step
+func (s *Store) UpdateUser(user models.User) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var existing models.User
+		if err := getUser(tx, user.ID, &existing); err != nil {
+			return err
+		}
+
+		usernames := tx.Bucket(usernameBucket)
+		if !strings.EqualFold(existing.Username, user.Username) {
+			if usernames.Get(usernameKey(user.Username)) != nil {
+				return fmt.Errorf("username %s: %w", user.Username, storage.ErrAlreadyExists)
+			}
+			if err := usernames.Delete(usernameKey(existing.Username)); err != nil {
+				return fmt.Errorf("could not update username index: %v", err)
+			}
+			if err := usernames.Put(usernameKey(user.Username), []byte(user.ID)); err != nil {
+				return fmt.Errorf("could not update username index: %v", err)
+			}
+		}
+
+		return putUser(tx, user)
+	})
+}
+
+func getUser(tx *bolt.Tx, id string, user *models.User) error {
+	data := tx.Bucket(userBucket).Get([]byte(id))
+	if data == nil {
+		return fmt.Errorf("user %s: %w", id, storage.ErrNotFound)
+	}
+	if err := json.Unmarshal(data, user); err != nil {
+		return fmt.Errorf("could not unmarshal user: %v", err)
+	}
+	return nil
+}
+
+func putUser(tx *bolt.Tx, user models.User) error {
+	buf, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("could not marshal user: %v", err)
+	}
+	if err := tx.Bucket(userBucket).Put([]byte(user.ID), buf); err != nil {
+		return fmt.Errorf("could not store user: %v", err)
+	}
+	return nil
+}
+
+func usernameKey(username string) []byte {
+	return []byte(strings.ToLower(username))
+}
+
+// GetSession reads a session by ID
+func (s *Store) GetSession(id string) (models.Session, error) {
+	var session models.Session
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionBucket).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("session: %w", storage.ErrNotFound)
+		}
+		if err := json.Unmarshal(data, &session); err != nil {
+			return fmt.Errorf("could not unmarshal session: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+// CreateSession stores a new session
+func (s *Store) CreateSession(session models.Session) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		buf, err := json.Marshal(session)
+		if err != nil {
+			return fmt.Errorf("could not marshal session: %v", err)
+		}
+		if err := tx.Bucket(sessionBucket).Put([]byte(session.ID), buf); err != nil {
+			return fmt.Errorf("could not store session: %v", err)
+		}
+		return nil
+	})
+}
+
+// DeleteSession removes a session; deleting a missing session is not an error
+func (s *Store) DeleteSession(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(sessionBucket).Delete([]byte(id)); err != nil {
+			return fmt.Errorf("could not delete session: %v", err)
+		}
+		return nil
+	})
+}
+
+// DeleteExpiredSessions removes every session that has expired by now
+func (s *Store) DeleteExpiredSessions(now time.Time) (int, error) {
+	return s.deleteSessions(func(id string, session models.Session) bool {
+		return session.Expired(now)
+	})
+}
+
+// DeleteUserSessions removes every session belonging to a user except keepID
+func (s *Store) DeleteUserSessions(userID, keepID string) (int, error) {
+	return s.deleteSessions(func(id string, session models.Session) bool {
+		return session.UserID == userID && id != keepID
+	})
+}
+
+// deleteSessions removes every session that match picks
+func (s *Store) deleteSessions(match func(id string, session models.Session) bool) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionBucket)
+
+		// Collect first - bolt does not allow modifying a bucket during ForEach
+		var matched [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var session models.Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return fmt.Errorf("could not unmarshal session: %v", err)
+			}
+			if match(string(k), session) {
+				matched = append(matched, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range matched {
+			if err := b.Delete(k); err != nil {
+				return fmt.Errorf("could not delete session: %v", err)
+			}
+		}
+		deleted = len(matched)
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"fmt"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+)
+
+// AppendAudit adds an entry to the end of the audit log
+func (s *Store) AppendAudit(entry models.AuditEntry) (models.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Same zero-padded numbering as the BoltDB store, so IDs sort in the order they were added
+	entry.ID = fmt.Sprintf("%012d", len(s.audit)+1)
+	s.audit = append(s.audit, entry)
+	return entry, nil
+}
+
+// GetAudit returns one audit entry
+func (s *Store) GetAudit(id string) (models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, entry := range s.audit {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return models.AuditEntry{}, fmt.Errorf("audit entry %s: %w", id, storage.ErrNotFound)
+}
+
+// ListAudit returns the entries matching filter, newest first
+func (s *Store) ListAudit(filter storage.AuditFilter) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var entries []models.AuditEntry
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		entry := s.audit[i]
+		if filter.Before != "" && entry.ID >= filter.Before {
+			continue
+		}
+		if !filter.Match(entry) {
+			continue
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"fmt"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+)
+
+// ListGroups returns every group
+func (s *Store) ListGroups() ([]models.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	groups := make([]models.Group, 0, len(s.groups))
+	for _, group := range s.groups {
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// ListGroupsForUser returns the groups a user owns or belongs to
+func (s *Store) ListGroupsForUser(userID string) ([]models.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var groups []models.Group
+	for _, group := range s.groups {
+		if _, ok := group.RoleOf(userID); ok {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// GetGroup returns a single group by ID
+func (s *Store) GetGroup(id string) (models.Group, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	group, exists := s.groups[id]
+	if !exists {
+		return models.Group{}, fmt.Errorf("group %s: %w", id, storage.ErrNotFound)
+	}
+	return group, nil
+}
+
+// CreateGroup adds a new group
+func (s *Store) CreateGroup(group models.Group) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.groups[group.ID]; exists {
+		return fmt.Errorf("group %s: %w", group.ID, storage.ErrAlreadyExists)
+	}
+	s.groups[group.ID] = group
+	return nil
+}
+
+// UpdateGroup replaces an existing group
+func (s *Store) UpdateGroup(group models.Group) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.groups[group.ID]; !exists {
+		return fmt.Errorf("group %s: %w", group.ID, storage.ErrNotFound)
+	}
+	s.groups[group.ID] = group
+	return nil
+}
+
+// DeleteGroup removes a group
+// Recipes shared with it fall back to being visible to their owners only
+func (s *Store) DeleteGroup(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.groups[id]; !exists {
+		return fmt.Errorf("group %s: %w", id, storage.ErrNotFound)
+	}
+	delete(s.groups, id)
+	return nil
+}
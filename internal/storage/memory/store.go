@@ -3,83 +3,183 @@
 package memory
 
 import (
+	"context"
 	"fmt"
 	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"slices"
+	"sort"
 	"sync"
 )
 
-// Store implements storage.RecipeStore interface
+// Store implements storage.ContextRecipeStore and the other store interfaces
 type Store struct {
-	mu      sync.RWMutex // For safe concurrent access
-	recipes map[string]models.Recipe
+	mu       sync.RWMutex // For safe concurrent access
+	recipes  map[string]models.Recipe
+	progress map[string]map[string]models.CookProgress // recipe ID, then user ID
+	users    map[string]models.User
+	sessions map[string]models.Session
+	tokens   map[string]models.APIToken
+	groups   map[string]models.Group
+	audit    []models.AuditEntry // append-only, oldest first
 }
 
 // New creates a new in-memory store
 func New() *Store {
 	return &Store{
-		recipes: make(map[string]models.Recipe),
+		recipes:  make(map[string]models.Recipe),
+		progress: make(map[string]map[string]models.CookProgress),
+		users:    make(map[string]models.User),
+		sessions: make(map[string]models.Session),
+		tokens:   make(map[string]models.APIToken),
+		groups:   make(map[string]models.Group),
 	}
 }
 
-//List returns all recipes
-func (s *Store) List() ([]models.Recipe, error) {
+// List returns all recipes, ordered by ID
+func (s *Store) List(ctx context.Context) ([]models.Recipe, error) {
+	return s.scan(ctx, func(models.Recipe) bool { return true })
+}
+
+// ListVisible returns the recipes the viewer is allowed to see, ordered by ID
+func (s *Store) ListVisible(ctx context.Context, viewer models.Viewer) ([]models.Recipe, error) {
+	return s.scan(ctx, func(recipe models.Recipe) bool { return recipe.VisibleTo(viewer) })
+}
+
+// scan returns copies of the recipes keep accepts, in ID order like the bbolt store
+func (s *Store) scan(ctx context.Context, keep func(models.Recipe) bool) ([]models.Recipe, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	recipes := make([]models.Recipe, 0, len(s.recipes))
+	var recipes []models.Recipe
 	for _, recipe := range s.recipes {
-		recipes = append(recipes, recipe)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if keep(recipe) {
+			recipes = append(recipes, cloneRecipe(recipe))
+		}
 	}
+	sort.Slice(recipes, func(i, j int) bool { return recipes[i].ID < recipes[j].ID })
 	return recipes, nil
 }
 
+// cloneRecipe copies a recipe's ingredients and steps, so changes a caller makes to a
+// recipe it passed in or got back don't reach the stored one - as with a database
+func cloneRecipe(recipe models.Recipe) models.Recipe {
+	recipe.Ingredients = slices.Clone(recipe.Ingredients)
+	recipe.Instructions = slices.Clone(recipe.Instructions)
+	return recipe
+}
+
 // Get returns a single recipe by ID
-func (s *Store) Get(id string) (models.Recipe, error) {
+func (s *Store) Get(ctx context.Context, id string) (models.Recipe, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Recipe{}, err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	recipe, exists := s.recipes[id]
 	if !exists {
-		return models.Recipe{}, fmt.Errorf("recipe not found: %s", id)
+		return models.Recipe{}, fmt.Errorf("recipe %s: %w", id, storage.ErrNotFound)
 	}
-	return recipe, nil
+	return cloneRecipe(recipe), nil
 }
 
 // Create adds a new recipe
-func (s *Store) Create(recipe models.Recipe) error {
+// It fails with storage.ErrAlreadyExists rather than overwrite a recipe with the same ID
+func (s *Store) Create(ctx context.Context, recipe models.Recipe) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if recipe.ID == "" {
+		return fmt.Errorf("recipe ID is required")
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.recipes[recipe.ID]; exists {
-		return fmt.Errorf("recipe already exists: %s", recipe.ID)
+		return fmt.Errorf("recipe %s: %w", recipe.ID, storage.ErrAlreadyExists)
 	}
 
-	s.recipes[recipe.ID] = recipe
+	s.recipes[recipe.ID] = cloneRecipe(recipe)
 	return nil
 }
 
 // Update modifies an existing recipe
-func (s *Store) Update(recipe models.Recipe) error {
+func (s *Store) Update(ctx context.Context, recipe models.Recipe) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.recipes[recipe.ID]; !exists {
-		return fmt.Errorf("recipe not found: %s", recipe.ID)
+		return fmt.Errorf("recipe %s: %w", recipe.ID, storage.ErrNotFound)
 	}
 
-	s.recipes[recipe.ID] = recipe
+	s.recipes[recipe.ID] = cloneRecipe(recipe)
 	return nil
 }
 
 // Delete removes a recipe
-func (s *Store) Delete(id string) error {
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.recipes[id]; !exists {
-		return fmt.Errorf("recipe not found: %s", id)
+		return fmt.Errorf("recipe %s: %w", id, storage.ErrNotFound)
 	}
 
 	delete(s.recipes, id)
 	return nil
 }
+
+// GetCookProgress returns a user's saved cook mode state for a recipe
+func (s *Store) GetCookProgress(userID, recipeID string) (models.CookProgress, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	progress, exists := s.progress[recipeID][userID]
+	if !exists {
+		return models.CookProgress{}, fmt.Errorf("cook progress for %s: %w", recipeID, storage.ErrNotFound)
+	}
+	return progress, nil
+}
+
+// SaveCookProgress creates or replaces a user's cook mode state for a recipe
+func (s *Store) SaveCookProgress(progress models.CookProgress) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.progress[progress.RecipeID] == nil {
+		s.progress[progress.RecipeID] = make(map[string]models.CookProgress)
+	}
+	s.progress[progress.RecipeID][progress.UserID] = progress
+	return nil
+}
+
+// DeleteCookProgress forgets a user's cook mode state for a recipe
+func (s *Store) DeleteCookProgress(userID, recipeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.progress[recipeID], userID)
+	return nil
+}
+
+// DeleteRecipeCookProgress forgets every user's cook mode state for a recipe
+func (s *Store) DeleteRecipeCookProgress(recipeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.progress, recipeID)
+	return nil
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"go_recipe_app/internal/storage"
+	"go_recipe_app/internal/storage/storagetest"
+	"testing"
+)
+
+// The shared suite every recipe store must pass
+func TestConformance(t *testing.T) {
+	storagetest.TestRecipeStore(t, func(t *testing.T) storage.ContextRecipeStore {
+		return New()
+	})
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"fmt"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"time"
+)
+
+// ListTokens returns every token belonging to a user
+func (s *Store) ListTokens(userID string) ([]models.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tokens []models.APIToken
+	for _, token := range s.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// GetToken returns a single token by ID
+func (s *Store) GetToken(id string) (models.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, exists := s.tokens[id]
+	if !exists {
+		return models.APIToken{}, fmt.Errorf("token %s: %w", id, storage.ErrNotFound)
+	}
+	return token, nil
+}
+
+// GetTokenByHash returns a single token by the hash of its secret
+func (s *Store) GetTokenByHash(hash string) (models.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.tokens {
+		if token.Hash == hash {
+			return token, nil
+		}
+	}
+	return models.APIToken{}, fmt.Errorf("token: %w", storage.ErrNotFound)
+}
+
+// CreateToken adds a new token
+func (s *Store) CreateToken(token models.APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tokens[token.ID]; exists {
+		return fmt.Errorf("token %s: %w", token.ID, storage.ErrAlreadyExists)
+	}
+	s.tokens[token.ID] = token
+	return nil
+}
+
+// DeleteToken revokes a token
+func (s *Store) DeleteToken(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tokens[id]; !exists {
+		return fmt.Errorf("token %s: %w", id, storage.ErrNotFound)
+	}
+	delete(s.tokens, id)
+	return nil
+}
+
+// TouchToken records when a token was last used
+func (s *Store) TouchToken(id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.tokens[id]
+	if !exists {
+		return fmt.Errorf("token %s: %w", id, storage.ErrNotFound)
+	}
+	token.LastUsedAt = usedAt
+	s.tokens[id] = token
+	return nil
+}
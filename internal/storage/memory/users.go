@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"fmt"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"strings"
+	"time"
+)
+
+// ListUsers returns every user account
+func (s *Store) ListUsers() ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]models.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// GetUser returns a single user by ID
+func (s *Store) GetUser(id string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return models.User{}, fmt.Errorf("user %s: %w", id, storage.ErrNotFound)
+	}
+	return user, nil
+}
+
+// GetUserByUsername returns a single user by username, ignoring case
+func (s *Store) GetUserByUsername(username string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+	return models.User{}, fmt.Errorf("user %s: %w", username, storage.ErrNotFound)
+}
+
+// CreateUser adds a new user, failing if the ID or username is taken
+func (s *Store) CreateUser(user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[user.ID]; exists {
+		return fmt.Errorf("user %s: %w", user.ID, storage.ErrAlreadyExists)
+	}
+	for _, existing := range s.users {
+		if strings.EqualFold(existing.Username, user.Username) {
+			return fmt.Errorf("username %s: %w", user.Username, storage.ErrAlreadyExists)
+		}
+	}
+
+	s.users[user.ID] = user
+	return nil
+}
+
+// UpdateUser modifies an existing user
+func (s *Store) UpdateUser(user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[user.ID]; !exists {
+		return fmt.Errorf("user %s: %w", user.ID, storage.ErrNotFound)
+	}
+	for _, existing := range s.users {
+		if existing.ID != user.ID && strings.EqualFold(existing.Username, user.Username) {
+			return fmt.Errorf("username %s: %w", user.Username, storage.ErrAlreadyExists)
+		}
+	}
+
+	s.users[user.ID] = user
+	return nil
+}
+
+// GetSession returns a single session by ID
+func (s *Store) GetSession(id string) (models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return models.Session{}, fmt.Errorf("session: %w", storage.ErrNotFound)
+	}
+	return session, nil
+}
+
+// CreateSession adds a new session
+func (s *Store) CreateSession(session models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = session
+	return nil
+}
+
+// DeleteSession removes a session
+func (s *Store) DeleteSession(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+// DeleteExpiredSessions removes every session that has expired by now
+func (s *Store) DeleteExpiredSessions(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, session := range s.sessions {
+		if session.Expired(now) {
+			delete(s.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// DeleteUserSessions removes every session belonging to a user except keepID
+func (s *Store) DeleteUserSessions(userID, keepID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, session := range s.sessions {
+		if session.UserID == userID && id != keepID {
+			delete(s.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -2,14 +2,122 @@
 
 package storage
 
-import "go_recipe_app/internal/models"
+import (
+	"context"
+	"errors"
+	"go_recipe_app/internal/models"
+	"time"
+)
+
+// Errors returned (wrapped) by stores - check for them with errors.Is
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+)
 
 // RecipeStore defines the interface for recipe storage
 type RecipeStore interface {
 	List() ([]models.Recipe, error)
+	ListVisible(viewer models.Viewer) ([]models.Recipe, error) // only what viewer may see
 	Get(id string) (models.Recipe, error)
 	Create(recipe models.Recipe) error
 	Update(recipe models.Recipe) error
 	Delete(id string) error
 }
 
+// ContextRecipeStore is RecipeStore with a context on every call
+// The context carries the request's deadline, so a scan stops once the browser gives up or
+// the database is too slow, and the request's logger, so store logs share its request ID
+type ContextRecipeStore interface {
+	List(ctx context.Context) ([]models.Recipe, error)
+	ListVisible(ctx context.Context, viewer models.Viewer) ([]models.Recipe, error)
+	Get(ctx context.Context, id string) (models.Recipe, error)
+	Create(ctx context.Context, recipe models.Recipe) error
+	Update(ctx context.Context, recipe models.Recipe) error
+	Delete(ctx context.Context, id string) error
+}
+
+// CookProgressStore saves where a cook is in a recipe so cook mode can resume on another device
+// Progress belongs to a user, so people cooking the same recipe don't move each other's place
+type CookProgressStore interface {
+	GetCookProgress(userID, recipeID string) (models.CookProgress, error)
+	SaveCookProgress(progress models.CookProgress) error
+	DeleteCookProgress(userID, recipeID string) error
+	DeleteRecipeCookProgress(recipeID string) error // everyone's progress, when the recipe goes
+}
+
+// UserStore defines the interface for user account storage
+// Usernames are unique regardless of case
+type UserStore interface {
+	ListUsers() ([]models.User, error)
+	GetUser(id string) (models.User, error)
+	GetUserByUsername(username string) (models.User, error)
+	CreateUser(user models.User) error
+	UpdateUser(user models.User) error
+}
+
+// SessionStore defines the interface for login session storage
+type SessionStore interface {
+	GetSession(id string) (models.Session, error)
+	CreateSession(session models.Session) error
+	DeleteSession(id string) error
+	DeleteExpiredSessions(now time.Time) (int, error)
+	DeleteUserSessions(userID, keepID string) (int, error)
+}
+
+// TokenStore defines the interface for API token storage
+// Tokens are looked up by the hash of their secret
+type TokenStore interface {
+	ListTokens(userID string) ([]models.APIToken, error)
+	GetToken(id string) (models.APIToken, error)
+	GetTokenByHash(hash string) (models.APIToken, error)
+	CreateToken(token models.APIToken) error
+	DeleteToken(id string) error
+	TouchToken(id string, usedAt time.Time) error
+}
+
+// GroupStore defines the interface for group (household) storage
+type GroupStore interface {
+	ListGroups() ([]models.Group, error)
+	ListGroupsForUser(userID string) ([]models.Group, error) // groups the user owns or belongs to
+	GetGroup(id string) (models.Group, error)
+	CreateGroup(group models.Group) error
+	UpdateGroup(group models.Group) error
+	DeleteGroup(id string) error
+}
+
+// AuditFilter narrows a listing of the audit log; zero fields match everything
+type AuditFilter struct {
+	RecipeID string
+	UserID   string
+	Action   models.AuditAction
+	Since    time.Time // inclusive
+	Until    time.Time // exclusive
+	Before   string    // only entries older than this entry ID, to page through the log
+	Limit    int       // most entries to return; 0 means no limit
+}
+
+// Match reports whether entry passes the filter, ignoring Before and Limit
+func (f AuditFilter) Match(entry models.AuditEntry) bool {
+	switch {
+	case f.RecipeID != "" && entry.RecipeID != f.RecipeID:
+		return false
+	case f.UserID != "" && entry.UserID != f.UserID:
+		return false
+	case f.Action != "" && entry.Action != f.Action:
+		return false
+	case !f.Since.IsZero() && entry.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !entry.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// AuditStore keeps the audit trail of recipe changes
+// It is append-only: there is deliberately no way to change or remove an entry
+type AuditStore interface {
+	AppendAudit(entry models.AuditEntry) (models.AuditEntry, error) // assigns the ID
+	GetAudit(id string) (models.AuditEntry, error)
+	ListAudit(filter AuditFilter) ([]models.AuditEntry, error) // newest first
+}
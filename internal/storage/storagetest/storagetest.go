@@ -0,0 +1,443 @@
+// Package storagetest checks that a recipe store behaves the way the rest of the app expects
+//
+// Every backend runs the same suite from its own tests, so they can't drift apart:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.TestRecipeStore(t, func(t *testing.T) storage.ContextRecipeStore {
+//			return newStore(t) // a fresh, empty store, cleaned up by t.Cleanup
+//		})
+//	}
+//
+// The suite is the definition of a correct store:
+//   - Create refuses to replace a recipe, failing with storage.ErrAlreadyExists, and needs an ID
+//   - Get, Update and Delete of a missing recipe fail with storage.ErrNotFound; Update never creates
+//   - List and ListVisible return recipes ordered by ID
+//   - a recipe comes back exactly as it was saved, however large, and changing a recipe
+//     after saving it, or one that was read back, doesn't change the stored copy
+//   - a canceled context fails every call with context.Canceled and changes nothing
+//   - concurrent calls are safe, and only one of several racing Creates of an ID succeeds
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_recipe_app/internal/models"
+	"go_recipe_app/internal/storage"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// NewStore returns a new, empty store for one test
+type NewStore func(t *testing.T) storage.ContextRecipeStore
+
+// TestRecipeStore runs the conformance suite against stores made by newStore
+// Each check gets a store of its own
+func TestRecipeStore(t *testing.T, newStore NewStore) {
+	tests := []struct {
+		name string
+		test func(*testing.T, storage.ContextRecipeStore)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateDuplicate", testCreateDuplicate},
+		{"CreateWithoutID", testCreateWithoutID},
+		{"GetMissing", testGetMissing},
+		{"Update", testUpdate},
+		{"UpdateMissing", testUpdateMissing},
+		{"Delete", testDelete},
+		{"ListOrder", testListOrder},
+		{"ListVisible", testListVisible},
+		{"Isolation", testIsolation},
+		{"CanceledContext", testCanceledContext},
+		{"Concurrency", testConcurrency},
+		{"LargePayload", testLargePayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// Recipe returns a recipe with every field filled in, so a store that drops one is caught
+func Recipe(id string) models.Recipe {
+	return models.Recipe{
+		ID:          id,
+		OwnerID:     "user-1",
+		Visibility:  models.VisibilityGroup,
+		GroupID:     "group-1",
+		Title:       "Recipe " + id,
+		Description: "A recipe for testing stores",
+		PrepTime:    15 * time.Minute,
+		CookTime:    90 * time.Minute,
+		Servings:    4,
+		Ingredients: []models.Ingredient{
+			{ID: "ing-1", Name: "Flour", Amount: 2.25, Unit: "cups", Section: "For the dough", Position: 0},
+			{ID: "ing-2", Name: "Salt", Amount: 0.125, Unit: "tsp", Section: "For the dough", Position: 1},
+		},
+		Instructions: []models.Instruction{
+			{ID: "step-1", Step: "Mix everything", Section: "For the dough", Position: 0},
+			{ID: "step-2", Step: "Bake", Section: "Baking", Duration: 25 * time.Minute, Position: 1},
+		},
+	}
+}
+
+// ctx is for calls that aren't testing cancellation
+var ctx = context.Background()
+
+func mustCreate(t *testing.T, s storage.ContextRecipeStore, recipes ...models.Recipe) {
+	t.Helper()
+	for _, recipe := range recipes {
+		if err := s.Create(ctx, recipe); err != nil {
+			t.Fatalf("Create(%s) = %v", recipe.ID, err)
+		}
+	}
+}
+
+// assertStored checks Get returns exactly want
+func assertStored(t *testing.T, s storage.ContextRecipeStore, want models.Recipe) {
+	t.Helper()
+	got, err := s.Get(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("Get(%s) = %v", want.ID, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Get(%s) =\n%+v\nwant\n%+v", want.ID, got, want)
+	}
+}
+
+// assertMissing checks Get fails with storage.ErrNotFound
+func assertMissing(t *testing.T, s storage.ContextRecipeStore, id string) {
+	t.Helper()
+	if _, err := s.Get(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Get(%s) error = %v, want storage.ErrNotFound", id, err)
+	}
+}
+
+// ids lists the IDs of recipes, in order
+func ids(recipes []models.Recipe) []string {
+	list := make([]string, len(recipes))
+	for i, recipe := range recipes {
+		list[i] = recipe.ID
+	}
+	return list
+}
+
+func testCreateAndGet(t *testing.T, s storage.ContextRecipeStore) {
+	recipe := Recipe("recipe-1")
+	mustCreate(t, s, recipe)
+	assertStored(t, s, recipe)
+
+	// Empty and missing lists are kept apart, as JSON clients see them differently
+	bare := models.Recipe{ID: "recipe-2", Ingredients: []models.Ingredient{}}
+	mustCreate(t, s, bare)
+	assertStored(t, s, bare)
+}
+
+func testCreateDuplicate(t *testing.T, s storage.ContextRecipeStore) {
+	original := Recipe("recipe-1")
+	mustCreate(t, s, original)
+
+	again := Recipe("recipe-1")
+	again.Title = "Someone else's recipe"
+	if err := s.Create(ctx, again); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("second Create error = %v, want storage.ErrAlreadyExists", err)
+	}
+	assertStored(t, s, original)
+}
+
+func testCreateWithoutID(t *testing.T, s storage.ContextRecipeStore) {
+	if err := s.Create(ctx, Recipe("")); err == nil {
+		t.Error("Create succeeded without an ID")
+	}
+	recipes, err := s.List(ctx)
+	if err != nil || len(recipes) != 0 {
+		t.Errorf("List = %v, %v, want nothing stored", ids(recipes), err)
+	}
+}
+
+func testGetMissing(t *testing.T, s storage.ContextRecipeStore) {
+	assertMissing(t, s, "no-such-recipe")
+}
+
+func testUpdate(t *testing.T, s storage.ContextRecipeStore) {
+	recipe := Recipe("recipe-1")
+	mustCreate(t, s, recipe)
+
+	recipe.Title = "Updated"
+	recipe.Visibility = models.VisibilityPublic
+	recipe.GroupID = ""
+	recipe.Ingredients = recipe.Ingredients[:1]
+	recipe.Instructions = append(recipe.Instructions, models.Instruction{ID: "step-3", Step: "Serve", Position: 2})
+	if err := s.Update(ctx, recipe); err != nil {
+		t.Fatalf("Update = %v", err)
+	}
+	assertStored(t, s, recipe)
+}
+
+func testUpdateMissing(t *testing.T, s storage.ContextRecipeStore) {
+	if err := s.Update(ctx, Recipe("recipe-1")); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Update error = %v, want storage.ErrNotFound", err)
+	}
+	assertMissing(t, s, "recipe-1")
+}
+
+func testDelete(t *testing.T, s storage.ContextRecipeStore) {
+	keep, gone := Recipe("recipe-1"), Recipe("recipe-2")
+	mustCreate(t, s, keep, gone)
+
+	if err := s.Delete(ctx, gone.ID); err != nil {
+		t.Fatalf("Delete = %v", err)
+	}
+	assertMissing(t, s, gone.ID)
+	assertStored(t, s, keep)
+
+	if err := s.Delete(ctx, gone.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("second Delete error = %v, want storage.ErrNotFound", err)
+	}
+
+	// The ID is free to use again
+	mustCreate(t, s, gone)
+	assertStored(t, s, gone)
+}
+
+func testListOrder(t *testing.T, s storage.ContextRecipeStore) {
+	recipes, err := s.List(ctx)
+	if err != nil || len(recipes) != 0 {
+		t.Fatalf("List of an empty store = %v, %v", ids(recipes), err)
+	}
+
+	for _, id := range []string{"recipe-b", "recipe-10", "recipe-a", "recipe-2", "Recipe-c"} {
+		mustCreate(t, s, Recipe(id))
+	}
+	// Ordered by the bytes of the ID, the same as comparing Go strings
+	want := []string{"Recipe-c", "recipe-10", "recipe-2", "recipe-a", "recipe-b"}
+
+	recipes, err = s.List(ctx)
+	if err != nil {
+		t.Fatalf("List = %v", err)
+	}
+	if got := ids(recipes); !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+	if len(recipes) > 0 && !reflect.DeepEqual(recipes[0], Recipe("Recipe-c")) {
+		t.Errorf("List returned %+v, want the whole recipe", recipes[0])
+	}
+
+	recipes, err = s.ListVisible(ctx, models.Viewer{Admin: true})
+	if err != nil {
+		t.Fatalf("ListVisible = %v", err)
+	}
+	if got := ids(recipes); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListVisible = %v, want %v", got, want)
+	}
+}
+
+func testListVisible(t *testing.T, s storage.ContextRecipeStore) {
+	recipe := func(id, owner string, visibility models.Visibility, group string) models.Recipe {
+		r := Recipe(id)
+		r.OwnerID, r.Visibility, r.GroupID = owner, visibility, group
+		return r
+	}
+	mustCreate(t, s,
+		recipe("a-private", "alice", models.VisibilityPrivate, ""),
+		recipe("b-public", "alice", models.VisibilityPublic, ""),
+		recipe("c-family", "alice", models.VisibilityGroup, "family"),
+		recipe("d-bob", "bob", models.VisibilityPrivate, ""),
+		recipe("e-unowned", "", models.VisibilityPrivate, ""),
+	)
+
+	tests := []struct {
+		name   string
+		viewer models.Viewer
+		want   []string
+	}{
+		{"visitor", models.Viewer{}, []string{"b-public"}},
+		{"owner", models.Viewer{UserID: "alice"}, []string{"a-private", "b-public", "c-family"}},
+		{"group member", models.Viewer{UserID: "carol", Groups: map[string]models.GroupRole{"family": models.GroupViewer}}, []string{"b-public", "c-family"}},
+		{"other user", models.Viewer{UserID: "bob"}, []string{"b-public", "d-bob"}},
+		{"admin", models.Viewer{UserID: "root", Admin: true}, []string{"a-private", "b-public", "c-family", "d-bob", "e-unowned"}},
+	}
+	for _, tt := range tests {
+		recipes, err := s.ListVisible(ctx, tt.viewer)
+		if err != nil {
+			t.Fatalf("%s: ListVisible = %v", tt.name, err)
+		}
+		if got := ids(recipes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ListVisible = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testIsolation(t *testing.T, s storage.ContextRecipeStore) {
+	recipe := Recipe("recipe-1")
+	mustCreate(t, s, recipe)
+
+	// Changing the recipe that was saved...
+	recipe.Ingredients[0].Name = "changed after Create"
+	recipe.Instructions[0].Step = "changed after Create"
+	// ...or ones that were read back...
+	got, err := s.Get(ctx, recipe.ID)
+	if err != nil {
+		t.Fatalf("Get = %v", err)
+	}
+	got.Ingredients[1].Name = "changed after Get"
+	listed, err := s.List(ctx)
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("List = %v, %v", ids(listed), err)
+	}
+	listed[0].Instructions[1].Step = "changed after List"
+
+	// ...leaves the stored copy alone
+	assertStored(t, s, Recipe("recipe-1"))
+
+	// And the same after an update
+	updated := Recipe("recipe-1")
+	updated.Title = "Updated"
+	if err := s.Update(ctx, updated); err != nil {
+		t.Fatalf("Update = %v", err)
+	}
+	updated.Ingredients[0].Name = "changed after Update"
+	want := Recipe("recipe-1")
+	want.Title = "Updated"
+	assertStored(t, s, want)
+}
+
+func testCanceledContext(t *testing.T, s storage.ContextRecipeStore) {
+	existing := Recipe("recipe-1")
+	mustCreate(t, s, existing)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := map[string]func() error{
+		"List": func() error { _, err := s.List(canceled); return err },
+		"ListVisible": func() error {
+			_, err := s.ListVisible(canceled, models.Viewer{Admin: true})
+			return err
+		},
+		"Get":    func() error { _, err := s.Get(canceled, existing.ID); return err },
+		"Create": func() error { return s.Create(canceled, Recipe("recipe-2")) },
+		"Update": func() error {
+			changed := Recipe(existing.ID)
+			changed.Title = "changed"
+			return s.Update(canceled, changed)
+		},
+		"Delete": func() error { return s.Delete(canceled, existing.ID) },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s error = %v, want context.Canceled", name, err)
+		}
+	}
+
+	// Nothing changed
+	assertStored(t, s, existing)
+	assertMissing(t, s, "recipe-2")
+}
+
+func testConcurrency(t *testing.T, s storage.ContextRecipeStore) {
+	const writers = 20
+
+	// Writers create and update their own recipes while readers list them
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			recipe := Recipe(fmt.Sprintf("recipe-%02d", i))
+			if err := s.Create(ctx, recipe); err != nil {
+				errs <- fmt.Errorf("Create(%s) = %v", recipe.ID, err)
+				return
+			}
+			recipe.Title = "Updated"
+			if err := s.Update(ctx, recipe); err != nil {
+				errs <- fmt.Errorf("Update(%s) = %v", recipe.ID, err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := s.List(ctx); err != nil {
+				errs <- fmt.Errorf("List = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	recipes, err := s.List(ctx)
+	if err != nil {
+		t.Fatalf("List = %v", err)
+	}
+	if len(recipes) != writers {
+		t.Fatalf("List has %d recipes, want %d", len(recipes), writers)
+	}
+	for _, recipe := range recipes {
+		if recipe.Title != "Updated" {
+			t.Errorf("recipe %s has title %q, want the update", recipe.ID, recipe.Title)
+		}
+	}
+
+	// Racing to create the same ID, exactly one wins
+	var created, exists int
+	var mu sync.Mutex
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recipe := Recipe("contested")
+			recipe.Title = fmt.Sprintf("Writer %d", i)
+			err := s.Create(ctx, recipe)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				created++
+			case errors.Is(err, storage.ErrAlreadyExists):
+				exists++
+			default:
+				t.Errorf("Create = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if created != 1 || exists != writers-1 {
+		t.Errorf("racing Creates: %d succeeded and %d found it existed, want 1 and %d", created, exists, writers-1)
+	}
+}
+
+func testLargePayload(t *testing.T, s storage.ContextRecipeStore) {
+	recipe := Recipe("big")
+	recipe.Description = strings.Repeat("A long story about this recipe. ", 1<<15) // 1 MiB
+	recipe.Ingredients = make([]models.Ingredient, 2000)
+	for i := range recipe.Ingredients {
+		recipe.Ingredients[i] = models.Ingredient{
+			ID: fmt.Sprintf("ing-%d", i), Name: fmt.Sprintf("Ingredient %d ½ “quoted” 🍅", i),
+			Amount: float64(i) / 3, Unit: "g", Section: fmt.Sprintf("Section %d", i/100), Position: i,
+		}
+	}
+	recipe.Instructions = make([]models.Instruction, 500)
+	for i := range recipe.Instructions {
+		recipe.Instructions[i] = models.Instruction{
+			ID: fmt.Sprintf("step-%d", i), Step: strings.Repeat("Stir. ", 50),
+			Duration: time.Duration(i) * time.Second, Position: i,
+		}
+	}
+
+	mustCreate(t, s, recipe)
+	assertStored(t, s, recipe)
+
+	recipe.Description = "Shorter now"
+	recipe.Ingredients = recipe.Ingredients[:10]
+	if err := s.Update(ctx, recipe); err != nil {
+		t.Fatalf("Update = %v", err)
+	}
+	assertStored(t, s, recipe)
+}
@@ -0,0 +1,276 @@
+// Package timeline merges several recipes into one backward-scheduled cooking plan
+// Every recipe is timed to finish at the same serve time
+package timeline
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go_recipe_app/internal/models"
+)
+
+// DefaultStepDuration is used for steps with no timer when the recipe's
+// PrepTime and CookTime leave nothing to share out
+const DefaultStepDuration = 5 * time.Minute
+
+// MaxRecipes caps how many different recipes one plan can merge
+// Each one is a store read, so an unbounded list would let one request load the whole store
+const MaxRecipes = 20
+
+// ErrTooManyRecipes is returned by RecipeIDs for a request over MaxRecipes
+var ErrTooManyRecipes = fmt.Errorf("a timeline can merge at most %d recipes", MaxRecipes)
+
+// RecipeIDs drops repeated recipe IDs, keeping each where it first appears,
+// and rejects a list with more than MaxRecipes left over
+func RecipeIDs(ids []string) ([]string, error) {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+		if len(unique) > MaxRecipes {
+			return nil, ErrTooManyRecipes
+		}
+	}
+	return unique, nil
+}
+
+// Entry is one scheduled step
+type Entry struct {
+	RecipeID    string        `json:"recipe_id"`
+	RecipeTitle string        `json:"recipe_title"`
+	Step        int           `json:"step"` // 1-based step number within the recipe
+	Text        string        `json:"text"`
+	Start       time.Time     `json:"start"`
+	End         time.Time     `json:"end"`
+	Duration    time.Duration `json:"duration"`
+	Estimated   bool          `json:"estimated"` // true when the step had no timer of its own
+	Passive     bool          `json:"passive"`   // hands-off, can run alongside other work
+	Equipment   []string      `json:"equipment"`
+	Temperature string        `json:"temperature,omitempty"`
+}
+
+// Warning flags a problem with the plan, such as two dishes needing the oven at once
+type Warning struct {
+	Kind    string    `json:"kind"` // "oven", "hands-on" or "past"
+	Message string    `json:"message"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// Plan is the merged timeline for a meal
+type Plan struct {
+	ServeAt  time.Time `json:"serve_at"`
+	StartAt  time.Time `json:"start_at"`
+	Entries  []Entry   `json:"entries"`
+	Warnings []Warning `json:"warnings"`
+}
+
+// passivePattern matches steps that are hands-off, leaving the cook free to work on something else
+var passivePattern = regexp.MustCompile(`(?i)\b(?:bak|roast|simmer|rest|chill|refrigerat|marinat|rise|rising|proof|cool|soak|brais|freez|steep|stand|slow[ -]cook)\w*`)
+
+// equipmentPatterns tell us which equipment a step occupies
+var equipmentPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"oven", regexp.MustCompile(`(?i)\b(?:oven|bak|roast|broil|preheat)\w*`)},
+	{"stovetop", regexp.MustCompile(`(?i)\b(?:simmer\w*|boil\w*|fry|fried|saut[eé]\w*|sear\w*|skillet|saucepan|pot|pan|wok)\b`)},
+}
+
+var temperaturePattern = regexp.MustCompile(`(\d{2,3})\s*°?\s*([FC])\b`)
+
+// Build schedules every recipe backward from serveAt and merges the result
+// Steps are laid out in order so each recipe's last step ends exactly at serveAt
+func Build(recipes []models.Recipe, serveAt time.Time, now time.Time) Plan {
+	plan := Plan{
+		ServeAt:  serveAt,
+		StartAt:  serveAt,
+		Entries:  []Entry{},
+		Warnings: []Warning{},
+	}
+
+	for _, recipe := range recipes {
+		plan.Entries = append(plan.Entries, schedule(recipe, serveAt)...)
+	}
+
+	sort.SliceStable(plan.Entries, func(i, j int) bool {
+		return plan.Entries[i].Start.Before(plan.Entries[j].Start)
+	})
+	if len(plan.Entries) > 0 {
+		plan.StartAt = plan.Entries[0].Start
+	}
+
+	plan.Warnings = append(plan.Warnings, conflicts(plan.Entries)...)
+	if plan.StartAt.Before(now) {
+		plan.Warnings = append(plan.Warnings, Warning{
+			Kind:    "past",
+			Message: fmt.Sprintf("Cooking should have started at %s - pick a later serve time", plan.StartAt.Format("15:04")),
+			Start:   plan.StartAt,
+			End:     now,
+		})
+	}
+	return plan
+}
+
+// schedule lays out one recipe's steps ending at serveAt
+func schedule(recipe models.Recipe, serveAt time.Time) []Entry {
+	steps := recipe.OrderedInstructions()
+
+	// A recipe without steps still takes its prep and cook time
+	if len(steps) == 0 {
+		if recipe.PrepTime > 0 {
+			steps = append(steps, models.Instruction{Step: "Prep", Duration: recipe.PrepTime})
+		}
+		if recipe.CookTime > 0 {
+			steps = append(steps, models.Instruction{Step: "Cook", Duration: recipe.CookTime})
+		}
+	}
+
+	durations, estimated := stepDurations(recipe, steps)
+
+	entries := make([]Entry, len(steps))
+	end := serveAt
+	for i := len(steps) - 1; i >= 0; i-- {
+		text := steps[i].Step
+		start := end.Add(-durations[i])
+		entries[i] = Entry{
+			RecipeID:    recipe.ID,
+			RecipeTitle: recipe.Title,
+			Step:        i + 1,
+			Text:        text,
+			Start:       start,
+			End:         end,
+			Duration:    durations[i],
+			Estimated:   estimated[i],
+			Passive:     isPassive(text),
+			Equipment:   equipment(text),
+			Temperature: temperature(text),
+		}
+		end = start
+	}
+	return entries
+}
+
+// stepDurations works out how long each step takes
+// Steps with a timer use it; the rest share whatever is left of PrepTime+CookTime
+func stepDurations(recipe models.Recipe, steps []models.Instruction) ([]time.Duration, []bool) {
+	durations := make([]time.Duration, len(steps))
+	estimated := make([]bool, len(steps))
+
+	var known time.Duration
+	unknown := 0
+	for i, step := range steps {
+		durations[i] = step.Timer()
+		if durations[i] > 0 {
+			known += durations[i]
+		} else {
+			unknown++
+		}
+	}
+	if unknown == 0 {
+		return durations, estimated
+	}
+
+	share := DefaultStepDuration
+	if left := recipe.PrepTime + recipe.CookTime - known; left > 0 {
+		share = max(left/time.Duration(unknown), time.Minute)
+	}
+	for i := range durations {
+		if durations[i] == 0 {
+			durations[i] = share
+			estimated[i] = true
+		}
+	}
+	return durations, estimated
+}
+
+// conflicts looks for overlapping steps from different recipes that compete
+// for the oven or for the cook's hands
+func conflicts(entries []Entry) []Warning {
+	var warnings []Warning
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			a, b := entries[i], entries[j]
+			if a.RecipeID == b.RecipeID || !overlaps(a, b) {
+				continue
+			}
+			start, end := maxTime(a.Start, b.Start), minTime(a.End, b.End)
+
+			if uses(a, "oven") && uses(b, "oven") {
+				msg := fmt.Sprintf("Oven conflict %s-%s: %q step %d and %q step %d",
+					start.Format("15:04"), end.Format("15:04"), a.RecipeTitle, a.Step, b.RecipeTitle, b.Step)
+				if a.Temperature != "" && b.Temperature != "" && a.Temperature != b.Temperature {
+					msg += fmt.Sprintf(" need different temperatures (%s vs %s)", a.Temperature, b.Temperature)
+				}
+				warnings = append(warnings, Warning{Kind: "oven", Message: msg, Start: start, End: end})
+			}
+
+			if !a.Passive && !b.Passive {
+				warnings = append(warnings, Warning{
+					Kind: "hands-on",
+					Message: fmt.Sprintf("Two hands-on steps overlap %s-%s: %q step %d and %q step %d",
+						start.Format("15:04"), end.Format("15:04"), a.RecipeTitle, a.Step, b.RecipeTitle, b.Step),
+					Start: start,
+					End:   end,
+				})
+			}
+		}
+	}
+	return warnings
+}
+
+func overlaps(a, b Entry) bool {
+	return a.Start.Before(b.End) && b.Start.Before(a.End)
+}
+
+func uses(e Entry, equipment string) bool {
+	for _, eq := range e.Equipment {
+		if eq == equipment {
+			return true
+		}
+	}
+	return false
+}
+
+func isPassive(text string) bool {
+	return passivePattern.MatchString(text)
+}
+
+func equipment(text string) []string {
+	found := []string{}
+	for _, eq := range equipmentPatterns {
+		if eq.pattern.MatchString(text) {
+			found = append(found, eq.name)
+		}
+	}
+	return found
+}
+
+func temperature(text string) string {
+	m := temperaturePattern.FindStringSubmatch(text)
+	if m == nil {
+		return ""
+	}
+	return m[1] + "°" + strings.ToUpper(m[2])
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
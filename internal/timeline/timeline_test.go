@@ -0,0 +1,134 @@
+package timeline
+
+import (
+	"errors"
+	"fmt"
+	"go_recipe_app/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var serveAt = time.Date(2026, 11, 26, 18, 0, 0, 0, time.UTC)
+
+func TestBuildSchedulesBackwardFromServeTime(t *testing.T) {
+	recipe := models.Recipe{
+		ID:       "roast",
+		Title:    "Roast Chicken",
+		PrepTime: 20 * time.Minute,
+		CookTime: 90 * time.Minute,
+		Instructions: []models.Instruction{
+			{Step: "Season the chicken", Position: 0},
+			{Step: "Roast at 425°F for 1 hour 15 minutes", Position: 1},
+			{Step: "Rest for 15 minutes", Position: 2},
+		},
+	}
+
+	plan := Build([]models.Recipe{recipe}, serveAt, serveAt.Add(-24*time.Hour))
+
+	if len(plan.Entries) != 3 {
+		t.Fatalf("Wrong number of entries. Want 3, got %d", len(plan.Entries))
+	}
+
+	last := plan.Entries[2]
+	if !last.End.Equal(serveAt) {
+		t.Errorf("Last step should end at serve time. Want %v, got %v", serveAt, last.End)
+	}
+
+	// Season has no timer so it gets what's left of prep+cook: 110m - 90m
+	first := plan.Entries[0]
+	if first.Duration != 20*time.Minute || !first.Estimated {
+		t.Errorf("Wrong estimate for untimed step. Want 20m estimated, got %v estimated=%v", first.Duration, first.Estimated)
+	}
+	if want := serveAt.Add(-110 * time.Minute); !plan.StartAt.Equal(want) {
+		t.Errorf("Wrong start time. Want %v, got %v", want, plan.StartAt)
+	}
+
+	roast := plan.Entries[1]
+	if !roast.Passive || roast.Temperature != "425°F" || len(roast.Equipment) != 1 || roast.Equipment[0] != "oven" {
+		t.Errorf("Roast step misclassified: passive=%v temperature=%q equipment=%v", roast.Passive, roast.Temperature, roast.Equipment)
+	}
+	if first.Passive {
+		t.Error("Seasoning should be a hands-on step")
+	}
+}
+
+func TestBuildWarnsAboutOvenConflicts(t *testing.T) {
+	recipes := []models.Recipe{
+		{
+			ID:           "turkey",
+			Title:        "Turkey",
+			Instructions: []models.Instruction{{Step: "Roast at 325°F for 3 hours"}},
+		},
+		{
+			ID:           "pie",
+			Title:        "Pie",
+			Instructions: []models.Instruction{{Step: "Bake at 400°F for 45 minutes"}},
+		},
+		{
+			ID:           "potatoes",
+			Title:        "Potatoes",
+			Instructions: []models.Instruction{{Step: "Mash the potatoes for 10 minutes"}},
+		},
+	}
+
+	plan := Build(recipes, serveAt, serveAt.Add(-24*time.Hour))
+
+	var oven int
+	for _, w := range plan.Warnings {
+		switch w.Kind {
+		case "oven":
+			oven++
+		case "hands-on", "past":
+			t.Errorf("Unexpected warning: %s", w.Message)
+		}
+	}
+	if oven != 1 {
+		t.Errorf("Wrong number of oven conflicts. Want 1, got %d (%v)", oven, plan.Warnings)
+	}
+}
+
+func TestBuildWarnsWhenStartIsInThePast(t *testing.T) {
+	recipe := models.Recipe{
+		ID:           "stew",
+		Title:        "Stew",
+		Instructions: []models.Instruction{{Step: "Simmer for 2 hours"}},
+	}
+
+	plan := Build([]models.Recipe{recipe}, serveAt, serveAt.Add(-time.Hour))
+
+	if len(plan.Warnings) != 1 || plan.Warnings[0].Kind != "past" {
+		t.Errorf("Expected a single past-start warning, got %v", plan.Warnings)
+	}
+}
+
+func TestRecipeIDs(t *testing.T) {
+	many := func(n int) []string {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("recipe-%d", i)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name    string
+		ids     []string
+		want    []string
+		wantErr error
+	}{
+		{"none", nil, []string{}, nil},
+		{"repeats keep the first place", []string{"b", "a", "b", "a", "c"}, []string{"b", "a", "c"}, nil},
+		{"at the cap", many(MaxRecipes), many(MaxRecipes), nil},
+		{"repeats don't count toward the cap", append(many(MaxRecipes), many(MaxRecipes)...), many(MaxRecipes), nil},
+		{"over the cap", many(MaxRecipes + 1), nil, ErrTooManyRecipes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RecipeIDs(tt.ids)
+			if !errors.Is(err, tt.wantErr) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RecipeIDs = %v, %v; want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Serving the static files under names that change whenever their contents do
+
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// StaticPrefix is the URL path the static files are served under
+const StaticPrefix = "/static/"
+
+// hashLen is how many hex digits of the content hash go in a file name
+const hashLen = 10
+
+// Assets serves the static files
+// Each file is linked as name.<hash>.ext, e.g. /static/css/app.3f2a9c1b04.css, with the hash
+// taken from its contents. A browser can then cache it forever: a new version of the file
+// gets a new name, and pages link to that instead
+//
+// In development files are read from disk on every request, linked by their plain names
+// and never cached, so an edit shows up on the next reload
+type Assets struct {
+	files  fs.FS             // rooted at the static directory
+	dev    bool              // read files fresh and skip the hashing
+	hashed map[string]string // "css/app.css" -> "css/app.3f2a9c1b04.css"
+	plain  map[string]string // and back again
+}
+
+// NewAssets hashes every file under static in files
+func NewAssets(files fs.FS, dev bool) (*Assets, error) {
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		return nil, fmt.Errorf("could not open static files: %v", err)
+	}
+	a := &Assets{
+		files:  static,
+		dev:    dev,
+		hashed: make(map[string]string),
+		plain:  make(map[string]string),
+	}
+	if dev {
+		return a, nil
+	}
+
+	err = fs.WalkDir(static, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(static, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:])[:hashLen] + ext
+		a.hashed[name] = hashed
+		a.plain[hashed] = name
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read static files: %v", err)
+	}
+	return a, nil
+}
+
+// Path returns the URL to link a static file by, such as "css/app.css"
+// An unknown file is an error, so a typo in a template fails loudly instead of linking nowhere
+func (a *Assets) Path(name string) (string, error) {
+	if a.dev {
+		if _, err := fs.Stat(a.files, name); err != nil {
+			return "", fmt.Errorf("unknown static file %s", name)
+		}
+		return StaticPrefix + name, nil
+	}
+	hashed, ok := a.hashed[name]
+	if !ok {
+		return "", fmt.Errorf("unknown static file %s", name)
+	}
+	return StaticPrefix + hashed, nil
+}
+
+// Dev reports whether the files are being read from disk for development
+func (a *Assets) Dev() bool {
+	return a.dev
+}
+
+// FuncMap holds the template functions for linking static files:
+//
+//	{{asset "css/app.css"}}  the URL of a static file
+//	{{devMode}}              whether the app is running in development mode
+func (a *Assets) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"asset":   a.Path,
+		"devMode": a.Dev,
+	}
+}
+
+// ServeHTTP serves a static file; the router strips StaticPrefix first
+func (a *Assets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	plain, hashed := a.plain[name]
+	if hashed {
+		name = plain
+	}
+	if info, err := fs.Stat(a.files, name); err != nil || info.IsDir() {
+		// No directory listings
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case a.dev:
+		w.Header().Set("Cache-Control", "no-cache")
+	case hashed:
+		// The name changes with the contents, so this URL always means these bytes
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		// Linked by its plain name, e.g. from an old page; it may change under the same URL
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	http.ServeFileFS(w, r, a.files, name)
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAssets(t *testing.T) {
+	files := fstest.MapFS{
+		"static/css/app.css": {Data: []byte("body { margin: 0; }")},
+	}
+
+	assets, err := NewAssets(files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed, err := assets.Path("css/app.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^/static/css/app\.[0-9a-f]{10}\.css$`).MatchString(hashed) {
+		t.Errorf("Path = %q, want a hashed name", hashed)
+	}
+	if _, err := assets.Path("css/missing.css"); err == nil {
+		t.Error("Path found a file that doesn't exist")
+	}
+
+	// A change to the contents gives a new name
+	files["static/css/app.css"] = &fstest.MapFile{Data: []byte("body { margin: 1px; }")}
+	changed, err := NewAssets(files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := changed.Path("css/app.css"); other == hashed {
+		t.Errorf("Path = %q after the file changed, want a new name", other)
+	}
+
+	tests := []struct {
+		name       string
+		dev        bool
+		path       string
+		wantStatus int
+		wantCache  string
+	}{
+		{"hashed", false, strings.TrimPrefix(hashed, "/static"), http.StatusOK, "public, max-age=31536000, immutable"},
+		{"plain", false, "/css/app.css", http.StatusOK, "no-cache"},
+		{"stale hash", false, "/css/app.0000000000.css", http.StatusNotFound, ""},
+		{"directory", false, "/css/", http.StatusNotFound, ""},
+		{"dev", true, "/css/app.css", http.StatusOK, "no-cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assets
+			if tt.dev {
+				if a, err = NewAssets(files, true); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if tt.wantStatus == http.StatusOK {
+				body, _ := io.ReadAll(rec.Body)
+				if !strings.HasPrefix(string(body), "body {") {
+					t.Errorf("body = %q, want the stylesheet", body)
+				}
+			}
+		})
+	}
+}
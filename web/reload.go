@@ -0,0 +1,107 @@
+// Reloading open pages in development when a template or static file changes
+
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// LiveReloadPath is where pages listen for changes in development
+const LiveReloadPath = "/_dev/reload"
+
+// LiveReload watches the files for changes and tells open pages to reload
+// Check is called every so often to look for changes. Pages load js/live-reload.js, which
+// keeps a stream open to ServeHTTP and reloads when the files' version changes
+type LiveReload struct {
+	files    fs.FS
+	onChange func() error
+	version  atomic.Value // string summarising every file's name, size and modification time
+}
+
+// NewLiveReload starts watching files
+// onChange is called when they change, before pages are told to reload, so it can
+// re-parse the templates first
+func NewLiveReload(files fs.FS, onChange func() error) (*LiveReload, error) {
+	l := &LiveReload{files: files, onChange: onChange}
+	version, err := l.scan()
+	if err != nil {
+		return nil, err
+	}
+	l.version.Store(version)
+	return l, nil
+}
+
+// Check looks for changed, added or removed files, reporting whether there were any
+// Pages are told to reload even when onChange fails; its error is returned to be logged
+func (l *LiveReload) Check() (bool, error) {
+	version, err := l.scan()
+	if err != nil || version == l.version.Load().(string) {
+		return false, err
+	}
+	err = l.onChange()
+	l.version.Store(version)
+	return true, err
+}
+
+// scan sums up the files' names, sizes and modification times
+// Reading every file each time would be slow, and an edit always changes the modification time
+func (l *LiveReload) scan() (string, error) {
+	h := sha256.New()
+	err := fs.WalkDir(l.files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not scan web files: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:hashLen], nil
+}
+
+// ServeHTTP streams the files' version to a page as server-sent events
+// The version is sent as soon as the page connects and again whenever it changes.
+// The stream ends when the server's write timeout is reached, and the browser reconnects
+// and gets the version again, so a change made in between isn't missed
+func (l *LiveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+
+	// Ask the browser to reconnect after a second rather than the default three
+	fmt.Fprint(w, "retry: 1000\n\n")
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	sent := ""
+	for {
+		if version := l.version.Load().(string); version != sent {
+			fmt.Fprintf(w, "data: %s\n\n", version)
+			sent = version
+		} else {
+			// A comment line keeps writing, so a gone page or the write timeout ends the
+			// stream instead of it holding up a graceful shutdown
+			fmt.Fprint(w, ":\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return // the page went away or the write timeout passed
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
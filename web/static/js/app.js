@@ -0,0 +1,22 @@
+// Every state-changing request needs the CSRF token
+// fetch calls send it with csrfHeaders(); POST forms get a hidden field added here
+function csrfToken() {
+    return document.querySelector('meta[name="csrf-token"]').content;
+}
+
+function csrfHeaders(headers = {}) {
+    return Object.assign({'X-CSRF-Token': csrfToken()}, headers);
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    document.querySelectorAll('form').forEach(form => {
+        if (form.method.toLowerCase() !== 'post' || form.querySelector('input[name="csrf_token"]')) {
+            return;
+        }
+        const input = document.createElement('input');
+        input.type = 'hidden';
+        input.name = 'csrf_token';
+        input.value = csrfToken();
+        form.appendChild(input);
+    });
+});
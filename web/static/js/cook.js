@@ -0,0 +1,216 @@
+// The page passes the recipe in: its ID on the cook-mode element, and the steps as JSON
+const recipeID = document.querySelector('.cook-mode').dataset.recipeId;
+// Progress is kept per user, so visitors cook without it being saved
+const keepProgress = document.querySelector('.cook-mode').dataset.keepProgress === 'true';
+const steps = JSON.parse(document.getElementById('cook-steps').textContent) || [];
+const progressURL = `/recipes/${recipeID}/cook/progress`;
+
+// progress mirrors models.CookProgress; durations are in nanoseconds like the Go side
+let progress = {step: 0, timers: [], updated_at: null};
+let saving = false;
+const alerted = new Set();
+
+function formatClock(ms) {
+    const total = Math.ceil(ms / 1000);
+    const h = Math.floor(total / 3600);
+    const m = Math.floor((total % 3600) / 60);
+    const s = total % 60;
+    const pad = n => String(n).padStart(2, '0');
+    return (h > 0 ? h + ':' + pad(m) : m) + ':' + pad(s);
+}
+
+function timerFor(step) {
+    return progress.timers.find(t => t.step === step);
+}
+
+function remainingMs(timer) {
+    if (timer.running) {
+        return Math.max(0, new Date(timer.ends_at) - Date.now());
+    }
+    return timer.remaining / 1e6;
+}
+
+function render() {
+    if (steps.length === 0) {
+        return;
+    }
+    const step = steps[progress.step];
+    document.getElementById('cook-counter').textContent = `Step ${step.number} of ${steps.length}`;
+    document.getElementById('cook-section').textContent = step.section;
+    document.getElementById('cook-text').textContent = step.text;
+    document.getElementById('cook-next').textContent = progress.step === steps.length - 1 ? 'Finish' : 'Next →';
+    renderTimers();
+}
+
+function renderTimers() {
+    const step = steps[progress.step];
+    const timer = timerFor(progress.step);
+    const box = document.getElementById('cook-timer');
+    const display = document.getElementById('cook-timer-display');
+
+    if (!timer && step.timer === 0) {
+        box.style.display = 'none';
+    } else {
+        box.style.display = 'flex';
+        const ms = timer ? remainingMs(timer) : step.timer * 1000;
+        display.textContent = formatClock(ms);
+        display.classList.toggle('done', !!timer && ms === 0);
+        document.getElementById('cook-timer-toggle').textContent = timer && timer.running ? 'Pause' : 'Start';
+    }
+
+    // Timers on other steps keep going while you move on
+    const list = document.getElementById('cook-active-timers');
+    list.innerHTML = '';
+    progress.timers.filter(t => t.step !== progress.step).forEach(t => {
+        const item = document.createElement('li');
+        const ms = remainingMs(t);
+        item.textContent = `Step ${t.step + 1}: ${ms === 0 ? 'done!' : formatClock(ms)}${t.running ? '' : ' (paused)'}`;
+        item.onclick = () => goToStep(t.step);
+        list.appendChild(item);
+    });
+}
+
+function checkAlarms() {
+    let changed = false;
+    progress.timers.forEach(t => {
+        if (t.running && remainingMs(t) === 0) {
+            t.running = false;
+            t.remaining = 0;
+            changed = true;
+            const key = t.step + '@' + t.ends_at;
+            if (!alerted.has(key)) {
+                alerted.add(key);
+                alarm();
+            }
+        }
+    });
+    if (changed) {
+        save();
+    }
+}
+
+function alarm() {
+    try {
+        const ctx = new AudioContext();
+        const osc = ctx.createOscillator();
+        osc.frequency.value = 880;
+        osc.connect(ctx.destination);
+        osc.start();
+        osc.stop(ctx.currentTime + 1);
+    } catch (e) {
+        console.warn('Could not play timer alarm', e);
+    }
+    if (navigator.vibrate) {
+        navigator.vibrate([300, 100, 300]);
+    }
+}
+
+function goToStep(index) {
+    if (index < 0 || index >= steps.length) {
+        return;
+    }
+    progress.step = index;
+    render();
+    save();
+}
+
+function nextStep() {
+    if (progress.step === steps.length - 1) {
+        const reset = keepProgress ? fetch(progressURL, {method: 'DELETE', headers: csrfHeaders()}) : Promise.resolve();
+        reset.finally(() => {
+            window.location.href = `/recipes/${recipeID}`;
+        });
+        return;
+    }
+    goToStep(progress.step + 1);
+}
+
+function toggleTimer() {
+    let timer = timerFor(progress.step);
+    if (!timer) {
+        timer = {step: progress.step, running: false, remaining: steps[progress.step].timer * 1e9, ends_at: '0001-01-01T00:00:00Z'};
+        progress.timers.push(timer);
+    }
+
+    if (timer.running) {
+        timer.remaining = remainingMs(timer) * 1e6;
+        timer.running = false;
+        timer.ends_at = '0001-01-01T00:00:00Z';
+    } else if (timer.remaining > 0) {
+        timer.ends_at = new Date(Date.now() + timer.remaining / 1e6).toISOString();
+        timer.running = true;
+    }
+    renderTimers();
+    save();
+}
+
+function resetTimer() {
+    progress.timers = progress.timers.filter(t => t.step !== progress.step);
+    renderTimers();
+    save();
+}
+
+function save() {
+    if (!keepProgress) {
+        return;
+    }
+    saving = true;
+    fetch(progressURL, {
+        method: 'PUT',
+        headers: csrfHeaders({'Content-Type': 'application/json'}),
+        body: JSON.stringify(progress),
+    }).then(response => {
+        if (!response.ok) {
+            throw new Error(response.status);
+        }
+        return response.json();
+    }).then(saved => {
+        progress.updated_at = saved.updated_at;
+    }).catch(error => {
+        console.error('Error saving cook progress:', error);
+    }).finally(() => {
+        saving = false;
+    });
+}
+
+// Pick up changes made on another device
+function sync() {
+    if (!keepProgress || saving) {
+        return;
+    }
+    fetch(progressURL).then(response => response.json()).then(remote => {
+        if (!saving && (!progress.updated_at || new Date(remote.updated_at) > new Date(progress.updated_at))) {
+            progress = remote;
+            render();
+        }
+    }).catch(error => console.error('Error loading cook progress:', error));
+}
+
+// Keep the screen on while cooking, re-acquiring the lock when the tab comes back
+let wakeLock = null;
+async function keepAwake() {
+    if (!('wakeLock' in navigator) || document.visibilityState !== 'visible') {
+        return;
+    }
+    try {
+        wakeLock = await navigator.wakeLock.request('screen');
+    } catch (e) {
+        console.warn('Could not keep screen awake', e);
+    }
+}
+document.addEventListener('visibilitychange', () => {
+    keepAwake();
+    sync();
+});
+
+document.addEventListener('keydown', e => {
+    if (e.key === 'ArrowRight') nextStep();
+    if (e.key === 'ArrowLeft') goToStep(progress.step - 1);
+    if (e.key === ' ') { e.preventDefault(); toggleTimer(); }
+});
+
+render();
+sync();
+keepAwake();
+setInterval(() => { checkAlarms(); renderTimers(); }, 250);
+setInterval(sync, 5000);
@@ -0,0 +1,47 @@
+// Saving the edit form with fetch, so it can send PUT
+
+function handleSubmit(form) {
+    console.log("Form submission started");
+    syncSections(form);
+    const method = form._method.value;
+    console.log("Method:", method);
+
+    // Create FormData and log it
+    const formData = new FormData(form);
+    console.log("Form data before send:");
+    for (let pair of formData.entries()) {
+        console.log(pair[0] + ': ' + pair[1]);
+    }
+
+    fetch(form.action, {
+        method: method,
+        headers: csrfHeaders({
+            // Add this to ensure server knows it's form data
+            'Content-Type': 'application/x-www-form-urlencoded',
+        }),
+        // Convert FormData to URLSearchParams
+        body: new URLSearchParams(formData).toString()
+    }).then(response => {
+        console.log("Response status:", response.status);
+        if (response.status === 422) {
+            // The server sent the form back with the problems marked - show it in place of this one
+            return response.text().then(html => {
+                const page = new DOMParser().parseFromString(html, 'text/html');
+                document.querySelector('.edit-recipe').replaceWith(page.querySelector('.edit-recipe'));
+                window.scrollTo(0, 0);
+            });
+        }
+        if (!response.ok) {
+            return response.text().then(text => {
+                console.error('Error response:', text);
+                throw new Error(text);
+            });
+        }
+        // The form posts to the recipe's own page
+        window.location.href = form.getAttribute('action');
+    }).catch(error => {
+        console.error('Error:', error);
+        alert('Error updating recipe: ' + error);
+    });
+    return false;
+}
@@ -0,0 +1,10 @@
+// Development only: reload the page when a template or static file changes
+// The server sends the files' version when the stream connects and whenever it changes
+let liveReloadVersion = null;
+
+new EventSource('/_dev/reload').onmessage = event => {
+    if (liveReloadVersion !== null && event.data !== liveReloadVersion) {
+        location.reload();
+    }
+    liveReloadVersion = event.data;
+};
@@ -0,0 +1,74 @@
+// Adding and removing ingredient and instruction rows and sections on the create and edit forms
+
+// Markup for a new row, keyed by item kind
+const itemTemplates = {
+    ingredient: `
+        <input type="hidden" name="ingredient_sections[]">
+        <input type="text" name="ingredient_names[]" placeholder="Ingredient name" required>
+        <input type="number" name="ingredient_amounts[]" placeholder="Amount" step="0.01" required>
+        <input type="text" name="ingredient_units[]" placeholder="Unit" required>
+        <button type="button" onclick="removeItem(this, 'ingredient')">Remove</button>
+    `,
+    instruction: `
+        <input type="hidden" name="instruction_sections[]">
+        <textarea name="instructions[]" placeholder="Enter instruction step" required></textarea>
+        <input type="number" name="instruction_minutes[]" placeholder="Timer (min)" min="0" step="0.5">
+        <button type="button" onclick="removeItem(this, 'instruction')">Remove</button>
+    `,
+};
+
+function newItem(kind) {
+    const entry = document.createElement('div');
+    entry.className = kind + '-entry';
+    entry.innerHTML = itemTemplates[kind];
+    return entry;
+}
+
+function addItem(button, kind) {
+    const items = button.closest('.section-group').querySelector('.section-items');
+    items.appendChild(newItem(kind));
+}
+
+function removeItem(button, kind) {
+    const container = document.getElementById(kind + 's-container');
+    if (container.querySelectorAll('.' + kind + '-entry').length > 1) {
+        button.parentElement.remove();
+    } else {
+        alert('Recipe must have at least one ' + kind);
+    }
+}
+
+function addSection(kind) {
+    const container = document.getElementById(kind + 's-container');
+    const label = kind === 'ingredient' ? 'Ingredient' : 'Instruction';
+    const group = document.createElement('div');
+    group.className = 'section-group';
+    group.innerHTML = `
+        <input type="text" class="section-name" placeholder="Section name" required>
+        <div class="section-items"></div>
+        <button type="button" onclick="addItem(this, '${kind}')">Add ${label}</button>
+        <button type="button" onclick="removeSection(this, '${kind}')">Remove Section</button>
+    `;
+    group.querySelector('.section-items').appendChild(newItem(kind));
+    container.appendChild(group);
+}
+
+function removeSection(button, kind) {
+    const container = document.getElementById(kind + 's-container');
+    if (container.querySelectorAll('.section-group').length > 1) {
+        button.closest('.section-group').remove();
+    } else {
+        alert('Recipe must have at least one section');
+    }
+}
+
+// Copy each section's name into the hidden input of every row it contains
+function syncSections(form) {
+    form.querySelectorAll('.section-group').forEach(group => {
+        const name = group.querySelector('.section-name').value.trim();
+        group.querySelectorAll('input[name$="_sections[]"]').forEach(input => {
+            input.value = name;
+        });
+    });
+    return true;
+}
@@ -0,0 +1,27 @@
+function cookRecipe(id) {
+    window.location.href = `/recipes/${id}/cook`;
+}
+
+function editRecipe(id) {
+    window.location.href = `/recipes/${id}/edit`;
+}
+
+function deleteRecipe(id) {
+    if (!confirm('Are you sure you want to delete this recipe?')) {
+        return;
+    }
+
+    fetch(`/recipes/${id}`, {
+        method: 'DELETE',
+        headers: csrfHeaders(),
+    }).then(response => {
+        if (response.ok) {
+            window.location.href = '/recipes';
+        } else {
+            alert('Error deleting recipe');
+        }
+    }).catch(error => {
+        console.error('Error:', error);
+        alert('Error deleting recipe');
+    });
+}
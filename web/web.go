@@ -0,0 +1,29 @@
+// Package web holds the HTML templates and the static CSS and JavaScript files
+// They're built into the binary, so it runs from any directory without copying files next to it
+package web
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+)
+
+//go:embed templates static
+var embedded embed.FS
+
+// Files returns the templates and static files
+// Normally these are the copies built into the binary. Given a directory, as in development,
+// they're read from disk there instead, so edits show up without rebuilding
+func Files(dir string) fs.FS {
+	if dir != "" {
+		return os.DirFS(dir)
+	}
+	return embedded
+}
+
+// Templates returns the templates directory within files
+func Templates(files fs.FS) fs.FS {
+	// Sub only fails for an invalid path, and this one is fixed
+	templates, _ := fs.Sub(files, "templates")
+	return templates
+}